@@ -0,0 +1,115 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"knet_management/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type apiKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Role      string     `json:"role" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"` // Optional, never expires if omitted
+}
+
+func getAPIKeys(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, err := db.GetAPIKeys(c.Request.Context())
+		if err != nil {
+			abortDatabaseError(c, err, "Failed to get API keys")
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{
+			"api_keys": keys,
+			"total":    len(keys),
+		}, envelope{Data: keys, Pagination: completeList(len(keys))})
+	}
+}
+
+// createAPIKey returns the plaintext key once; only its hash is stored
+func createAPIKey(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req apiKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortError(c, http.StatusBadRequest, codeInvalidBody, "Invalid API key body. Required: name, role")
+			return
+		}
+
+		if !database.IsValidRole(req.Role) {
+			abortError(c, http.StatusBadRequest, codeInvalidBody, "Invalid role. Use: viewer, operator, admin, ingest-device")
+			return
+		}
+
+		if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+			abortError(c, http.StatusBadRequest, codeInvalidBody, "expires_at must be in the future")
+			return
+		}
+
+		key, plaintext, err := db.CreateAPIKey(c.Request.Context(), req.Name, req.Role, req.ExpiresAt)
+		if err != nil {
+			abortDatabaseError(c, err, "Failed to create API key")
+			return
+		}
+
+		result := gin.H{
+			"api_key": key,
+			"key":     plaintext,
+		}
+		respond(c, http.StatusCreated, result, envelope{Data: result})
+	}
+}
+
+// rotateAPIKey issues a new key with the same name and role and revokes the old one
+func rotateAPIKey(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortInvalidParam(c, "id", "Invalid API key id")
+			return
+		}
+
+		key, plaintext, err := db.RotateAPIKey(c.Request.Context(), id)
+		if err == sql.ErrNoRows {
+			abortError(c, http.StatusNotFound, codeNotFound, "Active API key not found")
+			return
+		}
+		if err != nil {
+			abortDatabaseError(c, err, "Failed to rotate API key")
+			return
+		}
+
+		result := gin.H{
+			"api_key": key,
+			"key":     plaintext,
+		}
+		respond(c, http.StatusCreated, result, envelope{Data: result})
+	}
+}
+
+func revokeAPIKey(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortInvalidParam(c, "id", "Invalid API key id")
+			return
+		}
+
+		key, err := db.RevokeAPIKey(c.Request.Context(), id)
+		if err == sql.ErrNoRows {
+			abortError(c, http.StatusNotFound, codeNotFound, "Active API key not found")
+			return
+		}
+		if err != nil {
+			abortDatabaseError(c, err, "Failed to revoke API key")
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"api_key": key}, envelope{Data: key})
+	}
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"knet_management/auth"
+	"knet_management/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthConfig controls request authentication
+type AuthConfig struct {
+	// AnonymousRole is granted to requests without credentials (e.g. viewer
+	// for the dashboard). Empty requires credentials on every /api route.
+	AnonymousRole string
+
+	// OIDC validates JWT bearer tokens from the SSO issuer; nil accepts API keys only
+	OIDC *auth.Verifier
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject string // API key name, token user, or "anonymous"
+	Role    string
+	Method  string // "api_key", "oidc" or "anonymous"
+	KeyID   int    // Set for API keys
+}
+
+const principalContextKey = "principal"
+
+// currentPrincipal returns the caller set by authenticate, if any
+func currentPrincipal(c *gin.Context) (*Principal, bool) {
+	value, ok := c.Get(principalContextKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := value.(*Principal)
+	return principal, ok
+}
+
+// requestCredential reads "X-API-Key: <key>" or "Authorization: Bearer <key or JWT>"
+func requestCredential(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return strings.TrimSpace(key)
+	}
+	authorization := c.GetHeader("Authorization")
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+	return ""
+}
+
+func abortUnauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="knet_management"`)
+	abortError(c, http.StatusUnauthorized, codeUnauthorized, message)
+}
+
+// authenticate resolves the request credential (API key or OIDC JWT) to a
+// Principal. Requests without credentials get the anonymous role if one is
+// configured; invalid credentials are always rejected.
+func authenticate(db *database.Database, cfg AuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		credential := requestCredential(c)
+
+		if credential == "" {
+			if cfg.AnonymousRole != "" {
+				c.Set(principalContextKey, &Principal{Subject: "anonymous", Role: cfg.AnonymousRole, Method: "anonymous"})
+			}
+			c.Next()
+			return
+		}
+
+		if !database.IsAPIKey(credential) {
+			authenticateBearerToken(c, cfg.OIDC, credential)
+			return
+		}
+
+		key, err := db.AuthenticateAPIKey(c.Request.Context(), credential)
+		if err == sql.ErrNoRows {
+			abortUnauthorized(c, "Invalid, expired or revoked API key")
+			return
+		}
+		if err != nil {
+			abortDatabaseError(c, err, "Failed to verify API key")
+			return
+		}
+
+		c.Set(principalContextKey, &Principal{Subject: key.Name, Role: key.Role, Method: "api_key", KeyID: key.ID})
+		c.Next()
+	}
+}
+
+func authenticateBearerToken(c *gin.Context, verifier *auth.Verifier, token string) {
+	if verifier == nil {
+		abortUnauthorized(c, "Unsupported credential. Use an API key")
+		return
+	}
+
+	identity, err := verifier.Verify(token)
+	if err != nil {
+		c.Error(err)
+		abortUnauthorized(c, "Invalid or expired bearer token")
+		return
+	}
+	if identity.Role == "" {
+		abortError(c, http.StatusForbidden, codeForbidden, "No role is mapped to this token")
+		return
+	}
+
+	c.Set(principalContextKey, &Principal{Subject: identity.Subject, Role: identity.Role, Method: "oidc"})
+	c.Next()
+}
+
+// roleGrants reports whether a caller with role have may use a route for
+// want. viewer < operator < admin; ingest-device only matches itself (and admin).
+func roleGrants(have, want string) bool {
+	rank := map[string]int{database.RoleViewer: 1, database.RoleOperator: 2, database.RoleAdmin: 3}
+
+	if have == database.RoleAdmin || have == want {
+		return true
+	}
+	if want == database.RoleIngestDevice || have == database.RoleIngestDevice {
+		return false
+	}
+	return rank[have] >= rank[want] && rank[want] > 0
+}
+
+// requireRole allows the request if the caller has any of roles
+func requireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			abortUnauthorized(c, "Authentication required")
+			return
+		}
+
+		for _, role := range roles {
+			if roleGrants(principal.Role, role) {
+				c.Next()
+				return
+			}
+		}
+
+		abortError(c, http.StatusForbidden, codeForbidden, "Role "+principal.Role+" is not allowed to access this endpoint")
+	}
+}
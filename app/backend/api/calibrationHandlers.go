@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"knet_management/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type calibrationRequest struct {
+	Sensor        string    `json:"sensor" binding:"required"`
+	Metric        string    `json:"metric" binding:"required"`
+	Offset        float64   `json:"offset"`
+	Slope         *float64  `json:"slope"` // Defaults to 1
+	EffectiveFrom time.Time `json:"effective_from" binding:"required"`
+	Note          string    `json:"note"`
+	Recompute     bool      `json:"recompute"` // Recalibrate stored readings from effective_from until now
+}
+
+func getSensorCalibrations(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		calibrations, err := db.GetSensorCalibrations(c.Request.Context(), c.Query("sensor"))
+		if err != nil {
+			abortDatabaseError(c, err, "Failed to get calibrations")
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{
+			"calibrations": calibrations,
+			"total":        len(calibrations),
+		}, envelope{Data: calibrations, Pagination: completeList(len(calibrations))})
+	}
+}
+
+func createSensorCalibration(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req calibrationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortError(c, http.StatusBadRequest, codeInvalidBody, "Invalid calibration body. Required: sensor, metric, effective_from (RFC3339)")
+			return
+		}
+
+		if !database.IsValidCalibrationTarget(req.Sensor, req.Metric) {
+			abortError(c, http.StatusBadRequest, codeInvalidBody, "Invalid sensor/metric. Use sensor: main, ac_outlet and metric: temperature, humidity")
+			return
+		}
+
+		slope := 1.0
+		if req.Slope != nil {
+			slope = *req.Slope
+		}
+		if slope == 0 {
+			abortError(c, http.StatusBadRequest, codeInvalidBody, "slope cannot be 0")
+			return
+		}
+
+		calibration := &database.SensorCalibration{
+			Sensor:        req.Sensor,
+			Metric:        req.Metric,
+			Offset:        req.Offset,
+			Slope:         slope,
+			EffectiveFrom: req.EffectiveFrom,
+			Note:          req.Note,
+		}
+
+		response := gin.H{"calibration": calibration}
+		meta := gin.H{"recomputed_count": nil}
+
+		// With recompute the calibration is only stored if the recompute succeeds
+		if req.Recompute {
+			updated, err := db.InsertSensorCalibrationAndRecalibrate(c.Request.Context(), calibration, time.Now())
+			if err != nil {
+				abortDatabaseError(c, err, "Failed to create calibration and recompute stored readings")
+				return
+			}
+			response["recomputed_count"] = updated
+			meta["recomputed_count"] = updated
+		} else if err := db.InsertSensorCalibration(c.Request.Context(), calibration); err != nil {
+			abortDatabaseError(c, err, "Failed to create calibration")
+			return
+		}
+
+		respond(c, http.StatusCreated, response, envelope{Data: calibration, Meta: meta})
+	}
+}
+
+// recalibrateSensorData recomputes calibrated values for a historical time range
+func recalibrateSensorData(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime, err := time.Parse(time.RFC3339, c.Query("start_time"))
+		if err != nil {
+			abortInvalidParam(c, "start_time", "Invalid start_time format. Use RFC3339 (ISO 8601)")
+			return
+		}
+
+		endTime, err := time.Parse(time.RFC3339, c.Query("end_time"))
+		if err != nil {
+			abortInvalidParam(c, "end_time", "Invalid end_time format. Use RFC3339 (ISO 8601)")
+			return
+		}
+
+		if startTime.After(endTime) {
+			abortInvalidParam(c, "start_time", "start_time cannot be after end_time")
+			return
+		}
+
+		updated, err := db.RecalibrateRange(c.Request.Context(), startTime, endTime)
+		if err != nil {
+			abortDatabaseError(c, err, "Failed to recompute calibrated values")
+			return
+		}
+
+		result := gin.H{
+			"start_time":    startTime.Format(time.RFC3339),
+			"end_time":      endTime.Format(time.RFC3339),
+			"updated_count": updated,
+		}
+		respond(c, http.StatusOK, result, envelope{Data: result})
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"knet_management/database"
+)
+
+// A calibration posted with recompute is stored only if the recompute succeeds,
+// so a failed request can be retried without creating a duplicate
+func TestCreateCalibrationWithRecomputeIsAtomic(t *testing.T) {
+	tests := []struct {
+		name       string
+		execErr    error
+		wantStatus int
+		wantStored int
+	}{
+		{name: "recompute succeeds", wantStatus: http.StatusCreated, wantStored: 1},
+		{name: "recompute fails", execErr: errors.New("deadlock detected"), wantStatus: http.StatusInternalServerError, wantStored: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, stub := contractDatabase(time.Now())
+			stub.execErr = tt.execErr
+			router, err := SetupRoutes(db, nil, Config{
+				Auth: AuthConfig{AnonymousRole: database.RoleAdmin},
+				CORS: CORSConfig{AllowOrigins: []string{"*"}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body := `{"sensor": "main", "metric": "temperature", "offset": -0.5, "effective_from": "2026-01-01T00:00:00Z", "recompute": true}`
+			request := httptest.NewRequest(http.MethodPost, "/api/calibrations", strings.NewReader(body))
+			request.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("POST /api/calibrations = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			if stored := stub.committedMatching("INSERT INTO sensor_calibration"); len(stored) != tt.wantStored {
+				t.Errorf("stored %d calibrations, want %d", len(stored), tt.wantStored)
+			}
+		})
+	}
+}
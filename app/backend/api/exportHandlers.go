@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"knet_management/database"
+	"knet_management/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportTempSensorData streams a time range as CSV, NDJSON or Parquet.
+// There is no row limit; the response is written while rows are read.
+func exportTempSensorData(db *database.Database) gin.HandlerFunc {
+	exporter := service.NewTempSensorDataExporter(db)
+
+	return func(c *gin.Context) {
+		period := c.Query("time_period")
+		if period != "" && (c.Query("start_time") != "" || c.Query("end_time") != "") {
+			abortInvalidParam(c, "time_period", "Use either time_period or start_time/end_time, not both")
+			return
+		}
+		timeSpan, problems := resolveTimeRange(period, c.Query("start_time"), c.Query("end_time"), c.Query("timezone"), time.Now())
+		if len(problems) > 0 {
+			abortInvalidParams(c, problems)
+			return
+		}
+		startTime, endTime := timeSpan.start, timeSpan.end
+
+		format, err := service.ParseExportFormat(c.Query("format"))
+		if err != nil {
+			abortInvalidParam(c, "format", "Invalid format. Use: csv, ndjson, parquet")
+			return
+		}
+
+		resolution, err := service.ParseExportResolution(c.Query("resolution"))
+		if err != nil {
+			abortInvalidParam(c, "resolution", "Invalid resolution. Use: raw, 1m, 5m, 15m, 1h, 1d")
+			return
+		}
+
+		sensors, err := service.ParseExportSensors(c.Query("sensor"))
+		if err != nil {
+			abortInvalidParam(c, "sensor", "Invalid sensor. Use: main, ac_outlet")
+			return
+		}
+
+		metrics, err := service.ParseExportMetrics(c.Query("metric"))
+		if err != nil {
+			abortInvalidParam(c, "metric", "Invalid metric. Use: temperature, humidity, dew_point, absolute_humidity, heat_index, humidex")
+			return
+		}
+
+		qualityFilter, err := database.ParseQualityFilter(c.Query("exclude_flags"))
+		if err != nil {
+			abortInvalidParam(c, "exclude_flags", "Invalid exclude_flags. Use: outlier, interpolated, late, spooled, manual_edit or any")
+			return
+		}
+
+		filename := fmt.Sprintf("temp_sensor_data_%s_%s.%s", startTime.Format("20060102T150405"), endTime.Format("20060102T150405"), format)
+		c.Header("Content-Type", service.ExportContentType(format))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Status(http.StatusOK)
+
+		written, err := exporter.Export(c.Request.Context(), c.Writer, service.ExportOptions{
+			StartTime:  startTime,
+			EndTime:    endTime,
+			Format:     format,
+			Sensors:    sensors,
+			Metrics:    metrics,
+			Resolution: resolution,
+			Location:   timeSpan.rollupLocation(),
+			Filter:     qualityFilter,
+		})
+		if err != nil {
+			// Headers are already sent; the client sees a truncated body
+			slog.ErrorContext(c.Request.Context(), "Export failed", "records", written, "error", err)
+			c.Abort()
+			return
+		}
+	}
+}
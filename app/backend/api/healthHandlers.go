@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"knet_management/database"
+	"knet_management/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthConfig controls the /readyz checks
+type HealthConfig struct {
+	Collector        *service.TempSensorDataCollector // nil skips the collection and sensor checks
+	MaxCollectionAge time.Duration                    // Oldest acceptable reading; 0 means 3 collection intervals
+	DBPingTimeout    time.Duration
+}
+
+// Check results, from best to worst. degraded still answers 200.
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+	healthFail     = "fail"
+)
+
+func worseHealth(a, b string) string {
+	rank := map[string]int{healthOK: 0, healthDegraded: 1, healthFail: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+type databaseCheck struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type migrationsCheck struct {
+	Status         string   `json:"status"`
+	CurrentVersion string   `json:"current_version,omitempty"`
+	Pending        []string `json:"pending"`
+	Drift          []string `json:"drift"` // Modified or missing after being applied; reported, not failed
+	Error          string   `json:"error,omitempty"`
+}
+
+type collectionCheck struct {
+	Status          string     `json:"status"`
+	Message         string     `json:"message,omitempty"`
+	LastSuccess     *time.Time `json:"last_success"`
+	AgeSeconds      *float64   `json:"age_seconds"`
+	MaxAgeSeconds   float64    `json:"max_age_seconds"`
+	IntervalSeconds float64    `json:"interval_seconds"`
+}
+
+type sensorCheck struct {
+	Status string `json:"status"`
+	service.SensorHealth
+}
+
+// livez only tells Docker the process is serving requests; it does not touch
+// the database so a database outage does not restart the container
+func livez(started time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":         healthOK,
+			"uptime_seconds": int(time.Since(started).Seconds()),
+		})
+	}
+}
+
+// readyz checks the database, migrations, the age of the last stored reading
+// and every sensor. Any failed check answers 503.
+func readyz(db *database.Database, migrationManager *database.MigrationManager, cfg HealthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		status := healthOK
+		checks := gin.H{}
+
+		dbCheck := checkDatabase(c.Request.Context(), db, cfg.DBPingTimeout)
+		checks["database"] = dbCheck
+		status = worseHealth(status, dbCheck.Status)
+
+		// Migration status needs the database; skip it instead of waiting again
+		if dbCheck.Status == healthOK {
+			migrations := checkMigrations(c.Request.Context(), migrationManager, cfg.DBPingTimeout)
+			checks["migrations"] = migrations
+			status = worseHealth(status, migrations.Status)
+		}
+
+		if cfg.Collector != nil {
+			health := cfg.Collector.Health()
+			maxAge := cfg.MaxCollectionAge
+			if maxAge <= 0 {
+				maxAge = 3 * health.Interval
+			}
+
+			collection := checkCollection(health, maxAge, now)
+			checks["collection"] = collection
+			status = worseHealth(status, collection.Status)
+
+			sensors := gin.H{}
+			for id, sensor := range health.Sensors {
+				check := checkSensor(sensor, maxAge, now)
+				// Only the main sensor is required for a reading; the AC outlet
+				// sensor failing leaves its columns empty
+				if id != database.SensorMain && check.Status == healthFail {
+					check.Status = healthDegraded
+				}
+				sensors[id] = check
+				status = worseHealth(status, check.Status)
+			}
+			checks["sensors"] = sensors
+		}
+
+		code := http.StatusOK
+		if status == healthFail {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, gin.H{
+			"status":     status,
+			"checked_at": now,
+			"checks":     checks,
+		})
+	}
+}
+
+// probeContext bounds a readiness check by the DB ping timeout, 2s by default
+func probeContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func checkDatabase(ctx context.Context, db *database.Database, timeout time.Duration) databaseCheck {
+	ctx, cancel := probeContext(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := db.PingContext(ctx)
+	check := databaseCheck{Status: healthOK, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		check.Status = healthFail
+		check.Error = err.Error()
+	}
+	return check
+}
+
+func checkMigrations(ctx context.Context, migrationManager *database.MigrationManager, timeout time.Duration) migrationsCheck {
+	check := migrationsCheck{Status: healthOK, Pending: []string{}, Drift: []string{}}
+
+	ctx, cancel := probeContext(ctx, timeout)
+	defer cancel()
+
+	status, err := migrationManager.GetMigrationStatus(ctx)
+	if err != nil {
+		check.Status = healthFail
+		check.Error = err.Error()
+		return check
+	}
+
+	for _, s := range status {
+		switch {
+		case s.Applied && !s.Missing:
+			check.CurrentVersion = s.Version
+		case !s.Applied:
+			check.Pending = append(check.Pending, s.Version)
+		}
+		if s.Drift || s.Missing {
+			check.Drift = append(check.Drift, s.Version)
+		}
+	}
+	if len(check.Pending) > 0 {
+		check.Status = healthFail
+	}
+	return check
+}
+
+func checkCollection(health service.CollectorHealth, maxAge time.Duration, now time.Time) collectionCheck {
+	check := collectionCheck{
+		Status:          healthOK,
+		MaxAgeSeconds:   maxAge.Seconds(),
+		IntervalSeconds: health.Interval.Seconds(),
+	}
+
+	if health.LastSuccess.IsZero() {
+		// Give a fresh start one max age to store its first reading
+		if !health.StartedAt.IsZero() && now.Sub(health.StartedAt) < maxAge {
+			check.Status = healthDegraded
+			check.Message = "Waiting for the first reading"
+		} else {
+			check.Status = healthFail
+			check.Message = "No reading has been stored"
+		}
+		return check
+	}
+
+	age := now.Sub(health.LastSuccess).Seconds()
+	check.LastSuccess = &health.LastSuccess
+	check.AgeSeconds = &age
+	if now.Sub(health.LastSuccess) > maxAge {
+		check.Status = healthFail
+		check.Message = "Last reading is older than the maximum age"
+	}
+	return check
+}
+
+// checkSensor fails a sensor that has not answered within maxAge and
+// degrades one whose latest reads failed
+func checkSensor(sensor service.SensorHealth, maxAge time.Duration, now time.Time) sensorCheck {
+	check := sensorCheck{Status: healthOK, SensorHealth: sensor}
+	switch {
+	case sensor.ConsecutiveFailures == 0:
+	case sensor.LastSuccess != nil && now.Sub(*sensor.LastSuccess) <= maxAge:
+		check.Status = healthDegraded
+	default:
+		check.Status = healthFail
+	}
+	return check
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"knet_management/database"
+)
+
+// Probes and the status endpoint only read schema_version; upgrading it is
+// left to RunMigrations under the migration lock
+func TestMigrationStatusIsReadOnly(t *testing.T) {
+	db, stub := contractDatabase(time.Now())
+	router, err := SetupRoutes(db, database.NewMigrationManager(db), Config{
+		Auth: AuthConfig{AnonymousRole: database.RoleAdmin},
+		CORS: CORSConfig{AllowOrigins: []string{"*"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/readyz", "/api/migrations/status", "/api/v2/migrations/status"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != http.StatusOK && recorder.Code != http.StatusServiceUnavailable {
+			t.Errorf("GET %s = %d", path, recorder.Code)
+		}
+		if execs := atomic.LoadInt32(&stub.execs); execs != 0 {
+			t.Fatalf("GET %s ran %d statements, want none", path, execs)
+		}
+	}
+}
+
+// A slow migration status query fails the probe after DBPingTimeout
+func TestReadyzBoundsMigrationCheck(t *testing.T) {
+	db, stub := contractDatabase(time.Now())
+	stub.delay = time.Minute
+	router, err := SetupRoutes(db, database.NewMigrationManager(db), Config{
+		CORS:   CORSConfig{AllowOrigins: []string{"*"}},
+		Health: HealthConfig{DBPingTimeout: 50 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("/readyz took %v with a 50ms DB ping timeout", elapsed)
+	}
+
+	var body struct {
+		Checks struct {
+			Migrations migrationsCheck `json:"migrations"`
+		} `json:"checks"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusServiceUnavailable || body.Checks.Migrations.Status != healthFail || body.Checks.Migrations.Error == "" {
+		t.Errorf("/readyz = %d %s, want 503 with a failed migrations check", recorder.Code, recorder.Body)
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"knet_management/database"
+	"knet_management/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// importTempSensorData loads historical readings from a CSV or NDJSON body
+// (raw body or multipart "file" field) and returns a validation report
+func importTempSensorData(db *database.Database) gin.HandlerFunc {
+	importer := service.NewTempSensorDataImporter(db)
+
+	return func(c *gin.Context) {
+		format, err := service.ParseImportFormat(c.Query("format"))
+		if err != nil {
+			abortInvalidParam(c, "format", "Invalid format. Use: csv, ndjson")
+			return
+		}
+
+		mapping, err := service.ParseColumnMapping(c.Query("mapping"))
+		if err != nil {
+			abortInvalidParam(c, "mapping", "Invalid mapping. Use: field=column pairs, e.g. timestamp=Time,temperature=Temp")
+			return
+		}
+
+		location := time.UTC
+		if tz := c.Query("timezone"); tz != "" {
+			location, err = time.LoadLocation(tz)
+			if err != nil {
+				abortInvalidParam(c, "timezone", "Invalid timezone. Use an IANA name, e.g. Asia/Seoul")
+				return
+			}
+		}
+
+		batchSize := 0
+		if b := c.Query("batch_size"); b != "" {
+			if batchSize, err = strconv.Atoi(b); err != nil || batchSize <= 0 || batchSize > 5000 {
+				abortInvalidParam(c, "batch_size", "Invalid batch_size. Use 1-5000")
+				return
+			}
+		}
+
+		var body io.Reader = c.Request.Body
+		if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+			file, _, err := c.Request.FormFile("file")
+			if limit, tooLarge := isBodyTooLarge(err); tooLarge {
+				abortBodyTooLarge(c, limit)
+				return
+			}
+			if err != nil {
+				abortError(c, http.StatusBadRequest, codeInvalidBody, "Missing multipart field 'file'")
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+
+		report, err := importer.Import(c.Request.Context(), body, service.ImportOptions{
+			Format:          format,
+			ColumnMapping:   mapping,
+			Location:        location,
+			TimestampLayout: c.Query("timestamp_layout"),
+			BatchSize:       batchSize,
+			DryRun:          c.Query("dry_run") == "true",
+		})
+		if limit, tooLarge := isBodyTooLarge(err); tooLarge {
+			abortError(c, http.StatusRequestEntityTooLarge, codeBodyTooLarge,
+				fmt.Sprintf("Request body too large. Limit is %d bytes, rows before the limit were imported", limit), gin.H{"report": report})
+			return
+		}
+		if err != nil && database.IsQueryTimeout(err) {
+			c.Error(err)
+			abortError(c, http.StatusGatewayTimeout, codeQueryTimeout, "Import timed out, earlier batches were imported", gin.H{"report": report})
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			abortError(c, http.StatusUnprocessableEntity, codeImportFailed, "Import failed: "+err.Error(), gin.H{"report": report})
+			return
+		}
+
+		respond(c, http.StatusOK, report, envelope{Data: report})
+	}
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"knet_management/database"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig lists what browsers may call the API from other origins
+type CORSConfig struct {
+	AllowOrigins     []string // "*" allows every origin
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// corsMiddleware builds the gin-contrib/cors handler from cfg
+func corsMiddleware(cfg CORSConfig) (gin.HandlerFunc, error) {
+	corsConfig := cors.Config{
+		AllowMethods:     cfg.AllowMethods,
+		AllowHeaders:     cfg.AllowHeaders,
+		ExposeHeaders:    cfg.ExposeHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+	}
+
+	for _, origin := range cfg.AllowOrigins {
+		if origin == "*" {
+			corsConfig.AllowAllOrigins = true
+		}
+	}
+	if !corsConfig.AllowAllOrigins {
+		corsConfig.AllowOrigins = cfg.AllowOrigins
+	}
+	if corsConfig.AllowAllOrigins && cfg.AllowCredentials {
+		return nil, errors.New("CORS credentials cannot be allowed for all origins, list the origins instead")
+	}
+
+	if err := corsConfig.Validate(); err != nil {
+		return nil, err
+	}
+	return cors.New(corsConfig), nil
+}
+
+// RateLimitConfig is a token bucket per client: Burst requests at once,
+// refilled at RequestsPerSecond. A zero rate disables rate limiting.
+type RateLimitConfig struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps one token bucket per client key
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(cfg RateLimitConfig) *rateLimiter {
+	burst := cfg.Burst
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(cfg.RequestsPerSecond)))
+	}
+	return &rateLimiter{
+		rate:    cfg.RequestsPerSecond,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token for key. It returns the tokens left, or how long to
+// wait for the next token when the bucket is empty.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+		return false, 0, wait
+	}
+	bucket.tokens--
+	return true, int(bucket.tokens), 0
+}
+
+// sweep drops buckets that have refilled completely, so one-off clients do
+// not accumulate; callers hold l.mu
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// clientIPKey limits every request by client IP, before authentication, so
+// guessing keys or tokens is limited too. c.ClientIP only believes
+// X-Forwarded-For from the trusted proxies.
+func clientIPKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// principalKey limits authenticated requests by API key or token subject, so
+// clients sharing an IP keep their own bucket. Must run after authenticate;
+// anonymous requests are only limited by IP.
+func principalKey(c *gin.Context) string {
+	if principal, ok := currentPrincipal(c); ok {
+		switch principal.Method {
+		case "api_key":
+			return "key:" + strconv.Itoa(principal.KeyID)
+		case "oidc":
+			return "sub:" + principal.Subject
+		}
+	}
+	return ""
+}
+
+// rateLimit answers 429 with Retry-After once the bucket of the request's
+// key is empty. Requests key returns "" for are not limited.
+func rateLimit(cfg RateLimitConfig, key func(*gin.Context) string) gin.HandlerFunc {
+	if cfg.RequestsPerSecond <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	limiter := newRateLimiter(cfg)
+	return func(c *gin.Context) {
+		client := key(c)
+		if client == "" {
+			c.Next()
+			return
+		}
+		allowed, remaining, wait := limiter.allow(client, time.Now())
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(int(limiter.burst)))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if !allowed {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			abortError(c, http.StatusTooManyRequests, codeRateLimited, "Rate limit exceeded", gin.H{"retry_after": retryAfter})
+			return
+		}
+		c.Next()
+	}
+}
+
+// limitBody rejects request bodies larger than maxBytes with 413. Bodies
+// without Content-Length (chunked) are cut off by http.MaxBytesReader, which
+// handlers see as a *http.MaxBytesError.
+func limitBody(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBytes {
+			abortBodyTooLarge(c, maxBytes)
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
+}
+
+func abortBodyTooLarge(c *gin.Context, maxBytes int64) {
+	abortError(c, http.StatusRequestEntityTooLarge, codeBodyTooLarge, fmt.Sprintf("Request body too large. Limit is %d bytes", maxBytes))
+}
+
+// isBodyTooLarge reports whether err comes from a body cut off by limitBody
+func isBodyTooLarge(err error) (int64, bool) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return maxBytesErr.Limit, true
+	}
+	return 0, false
+}
+
+// abortDatabaseError records err for the access log and answers 504 when the
+// query ran out of time, otherwise 500 with message
+func abortDatabaseError(c *gin.Context, err error, message string) {
+	c.Error(err)
+	if database.IsQueryTimeout(err) {
+		abortError(c, http.StatusGatewayTimeout, codeQueryTimeout, message+": query timed out")
+		return
+	}
+	abortError(c, http.StatusInternalServerError, codeInternal, message)
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testRouter(t *testing.T, cfg Config) http.Handler {
+	t.Helper()
+	if cfg.CORS.AllowOrigins == nil {
+		cfg.CORS.AllowOrigins = []string{"*"}
+	}
+	r, err := SetupRoutes(nil, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// statusesOf sends n requests with a bad bearer token and returns the statuses
+func statusesOf(router http.Handler, n int, forwardedFor func(i int) string) []int {
+	statuses := make([]int, n)
+	for i := range statuses {
+		request := httptest.NewRequest(http.MethodGet, "/api/temp/latest", nil) // RemoteAddr 192.0.2.1
+		request.Header.Set("Authorization", "Bearer not-a-token")
+		if forwardedFor != nil {
+			request.Header.Set("X-Forwarded-For", forwardedFor(i))
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		statuses[i] = recorder.Code
+	}
+	return statuses
+}
+
+func TestRateLimitBeforeAuthentication(t *testing.T) {
+	rotating := func(i int) string { return "203.0.113." + string(rune('0'+i)) }
+
+	tests := []struct {
+		name         string
+		proxies      []string
+		forwardedFor func(int) string
+		want         []int
+	}{
+		{
+			name: "rejected credentials are limited",
+			want: []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusTooManyRequests},
+		},
+		{
+			name:         "X-Forwarded-For from an untrusted peer is ignored",
+			forwardedFor: rotating,
+			want:         []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusTooManyRequests},
+		},
+		{
+			name:         "X-Forwarded-For from a trusted proxy names the client",
+			proxies:      []string{"192.0.2.0/24"},
+			forwardedFor: rotating,
+			want:         []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := testRouter(t, Config{
+				RateLimit:      RateLimitConfig{RequestsPerSecond: 0.001, Burst: 2},
+				TrustedProxies: tt.proxies,
+			})
+			got := statusesOf(router, len(tt.want), tt.forwardedFor)
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("statuses = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidTrustedProxies(t *testing.T) {
+	if _, err := SetupRoutes(nil, nil, Config{CORS: CORSConfig{AllowOrigins: []string{"*"}}, TrustedProxies: []string{"not-an-ip"}}); err == nil {
+		t.Error("SetupRoutes accepted an invalid trusted proxy")
+	}
+}
@@ -0,0 +1,554 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"knet_management/database"
+	"knet_management/openapi"
+	"knet_management/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Spec is the OpenAPI document of every route SetupRoutes serves. Response
+// schemas are generated from the Go types the handlers return; SetupRoutes
+// refuses to start when the routes and the document disagree, and
+// "main openapi check" validates live responses against it.
+func Spec() *openapi.Document {
+	s := newSpecBuilder()
+
+	s.add(http.MethodGet, "/health", &openapi.Operation{
+		OperationID: "getHealth",
+		Summary:     "Legacy liveness check; use /livez or /readyz",
+		Tags:        []string{"health"},
+		Security:    public,
+		Responses: map[string]*openapi.Response{
+			"200": jsonResponse("Serving", openapi.Object(map[string]*openapi.Schema{"status": openapi.Enum("healthy")}, "status")),
+		},
+	})
+	s.add(http.MethodGet, "/livez", &openapi.Operation{
+		OperationID: "getLivez",
+		Summary:     "Liveness probe; does not check the database",
+		Tags:        []string{"health"},
+		Security:    public,
+		Responses: map[string]*openapi.Response{
+			"200": jsonResponse("Serving", openapi.Object(map[string]*openapi.Schema{
+				"status":         openapi.Enum(healthOK),
+				"uptime_seconds": {Type: "integer"},
+			}, "status", "uptime_seconds")),
+		},
+	})
+	readiness := s.gen.Define("Readiness", openapi.Object(map[string]*openapi.Schema{
+		"status":     openapi.Enum(healthOK, healthDegraded, healthFail),
+		"checked_at": {Type: "string", Format: "date-time"},
+		"checks": openapi.Object(map[string]*openapi.Schema{
+			"database":   s.gen.Named("DatabaseCheck", databaseCheck{}),
+			"migrations": s.gen.Named("MigrationsCheck", migrationsCheck{}),
+			"collection": s.gen.Named("CollectionCheck", collectionCheck{}),
+			"sensors":    {Type: "object", AdditionalProperties: s.gen.Named("SensorCheck", sensorCheck{})},
+		}, "database"),
+	}, "status", "checked_at", "checks"))
+	s.add(http.MethodGet, "/readyz", &openapi.Operation{
+		OperationID: "getReadyz",
+		Summary:     "Readiness probe: database, migrations, collection age and sensors",
+		Tags:        []string{"health"},
+		Security:    public,
+		Responses: map[string]*openapi.Response{
+			"200": jsonResponse("Ready (ok or degraded)", readiness),
+			"503": jsonResponse("A check failed", readiness),
+		},
+	})
+	s.add(http.MethodGet, "/openapi.json", &openapi.Operation{
+		OperationID: "getOpenAPI",
+		Summary:     "This document",
+		Tags:        []string{"meta"},
+		Security:    public,
+		Responses: map[string]*openapi.Response{
+			"200": jsonResponse("OpenAPI 3 document", &openapi.Schema{Type: "object"}),
+		},
+	})
+
+	s.addAPI("/api", 1)
+	s.addAPI("/api/v2", 2)
+
+	return s.document()
+}
+
+// public marks operations that need no credentials
+var public = []openapi.SecurityRequirement{{}}
+
+type specBuilder struct {
+	gen   *openapi.Generator
+	paths map[string]openapi.PathItem
+}
+
+func newSpecBuilder() *specBuilder {
+	return &specBuilder{gen: openapi.NewGenerator(), paths: make(map[string]openapi.PathItem)}
+}
+
+func (s *specBuilder) add(method, path string, op *openapi.Operation) {
+	item, ok := s.paths[path]
+	if !ok {
+		item = openapi.PathItem{}
+		s.paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+func (s *specBuilder) document() *openapi.Document {
+	return &openapi.Document{
+		OpenAPI: "3.0.3",
+		Info: openapi.Info{
+			Title:   "knet_management backend",
+			Version: "2",
+			Description: "Server room temperature and humidity API. /api (v1) keeps its original per-endpoint shapes; " +
+				"/api/v2 wraps data in {data, meta, pagination} and answers errors as RFC 7807 problems.",
+		},
+		Paths: s.paths,
+		Components: openapi.Components{
+			Schemas: s.gen.Schemas(),
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				"apiKey": {Type: "apiKey", In: "header", Name: "X-API-Key", Description: "API key (knet_...)"},
+				"bearer": {Type: "http", Scheme: "bearer", Description: "API key or OIDC JWT from the SSO issuer"},
+			},
+		},
+		// The empty requirement covers AUTH_ANONYMOUS_ROLE
+		Security: []openapi.SecurityRequirement{{"apiKey": {}}, {"bearer": {}}, {}},
+	}
+}
+
+// route is one operation of /api and /api/v2
+type route struct {
+	method, path, id, summary, tag, role string
+	params                               []openapi.Parameter
+	body                                 *openapi.RequestBody
+	status                               string
+	v1                                   *openapi.Schema // v1 body
+	data                                 *openapi.Schema // v2 envelope data
+	meta                                 *openapi.Schema // v2 envelope meta; nil is an empty object
+	paginated                            bool            // v2 pagination is an object, otherwise null
+	files                                []string        // Content types of a file response instead of JSON
+}
+
+func (s *specBuilder) addAPI(prefix string, version int) {
+	for _, r := range s.apiRoutes(version) {
+		op := &openapi.Operation{
+			OperationID: r.id,
+			Summary:     r.summary,
+			Description: "Role: " + r.role,
+			Tags:        []string{r.tag},
+			Parameters:  r.params,
+			RequestBody: r.body,
+			Responses:   map[string]*openapi.Response{"default": s.errorResponse(version)},
+		}
+		if version == 2 {
+			op.OperationID += "V2"
+		}
+
+		switch {
+		case len(r.files) > 0:
+			content := map[string]openapi.MediaType{}
+			for _, contentType := range r.files {
+				content[contentType] = openapi.MediaType{Schema: &openapi.Schema{Type: "string", Format: "binary"}}
+			}
+			op.Responses[r.status] = &openapi.Response{Description: "File stream", Content: content}
+		case version == 1:
+			op.Responses[r.status] = jsonResponse("Success", r.v1)
+		default:
+			op.Responses[r.status] = jsonResponse("Success", s.envelope(r.data, r.meta, r.paginated))
+		}
+
+		s.add(r.method, prefix+r.path, op)
+	}
+}
+
+// errorResponse is {"error": ...} on v1 and a problem on v2
+func (s *specBuilder) errorResponse(version int) *openapi.Response {
+	if version == 1 {
+		return jsonResponse("Error", s.gen.Define("Error", openapi.Object(map[string]*openapi.Schema{
+			"error":       {Type: "string"},
+			"retry_after": {Type: "integer", Description: "Seconds, on 429"},
+			"report":      s.gen.SchemaOf(service.ImportReport{}),
+		}, "error")))
+	}
+
+	codes := make([]string, 0, len(problemTitles))
+	for code := range problemTitles {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	problem := s.gen.Define("Problem", openapi.Object(map[string]*openapi.Schema{
+		"type":           {Type: "string", Description: problemTypePrefix + "<code>"},
+		"title":          {Type: "string"},
+		"status":         {Type: "integer"},
+		"detail":         {Type: "string"},
+		"instance":       {Type: "string"},
+		"code":           openapi.Enum(codes...),
+		"request_id":     {Type: "string"},
+		"invalid_params": openapi.ArrayOf(s.gen.Named("InvalidParam", invalidParam{})),
+		"retry_after":    {Type: "integer", Description: "Seconds, on rate_limited"},
+		"report":         s.gen.SchemaOf(service.ImportReport{}),
+	}, "type", "title", "status", "detail", "instance", "code"))
+
+	return &openapi.Response{
+		Description: "RFC 7807 problem",
+		Content:     map[string]openapi.MediaType{problemContentType: {Schema: problem}},
+	}
+}
+
+func (s *specBuilder) envelope(data, meta *openapi.Schema, paginated bool) *openapi.Schema {
+	if meta == nil {
+		meta = openapi.Object(map[string]*openapi.Schema{})
+	}
+	page := &openapi.Schema{Type: "object", Nullable: true, Enum: []interface{}{nil}, Description: "Always null for single resources"}
+	if paginated {
+		page = s.gen.Named("Pagination", pagination{})
+	}
+	return openapi.Object(map[string]*openapi.Schema{
+		"data":       data,
+		"meta":       meta,
+		"pagination": page,
+	}, "data", "meta", "pagination")
+}
+
+func (s *specBuilder) apiRoutes(version int) []route {
+	point := s.gen.SchemaOf(database.TempSensorData{})
+	nullablePoint := s.gen.SchemaOf(database.NullableTempSensorData{})
+	calibration := s.gen.SchemaOf(database.SensorCalibration{})
+	apiKey := s.gen.SchemaOf(database.APIKey{})
+	migration := s.gen.SchemaOf(database.MigrationStatus{})
+	importReport := s.gen.SchemaOf(service.ImportReport{})
+	periodStats := openapi.ArrayOf(s.gen.SchemaOf(database.PeriodStats{}))
+
+	rangeUpdate := s.gen.Define("RangeUpdate", openapi.Object(map[string]*openapi.Schema{
+		"start_time":    {Type: "string", Format: "date-time"},
+		"end_time":      {Type: "string", Format: "date-time"},
+		"updated_count": {Type: "integer"},
+	}, "start_time", "end_time", "updated_count"))
+	issuedKey := s.gen.Define("IssuedAPIKey", openapi.Object(map[string]*openapi.Schema{
+		"api_key": apiKey,
+		"key":     {Type: "string", Description: "Plaintext key, returned only once"},
+	}, "api_key", "key"))
+
+	rfc3339Range := []openapi.Parameter{
+		queryParam("start_time", "RFC 3339", true, &openapi.Schema{Type: "string", Format: "date-time"}),
+		queryParam("end_time", "RFC 3339", true, &openapi.Schema{Type: "string", Format: "date-time"}),
+	}
+	keyID := openapi.Parameter{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer"}}
+
+	return []route{
+		{
+			method: http.MethodGet, path: "/temp/latest", id: "getLatestTempSensorData", tag: "temp", role: database.RoleViewer,
+			summary: "Latest reading of both sensors",
+			status:  "200", v1: point, data: nullablePoint,
+		},
+		{
+			method: http.MethodGet, path: "/temp/history", id: "getTempSensorDataHistory", tag: "temp", role: database.RoleViewer,
+			summary: "Readings by time range (sampled), cursor, term or offset",
+			params:  historyParams(version),
+			status:  "200", v1: s.historyV1(point, nullablePoint), data: openapi.ArrayOf(nullablePoint), meta: s.historyMeta(), paginated: true,
+		},
+		{
+			method: http.MethodGet, path: "/temp/stats", id: "getTempSensorStats", tag: "temp", role: database.RoleViewer,
+			summary: "Daily or weekly min, max, mean, threshold time, completeness and outliers per sensor",
+			params: append(timeRangeParams(),
+				queryParam("period", "Summary period; default daily. The range defaults to 7d", false, openapi.Enum("daily", "weekly")),
+			),
+			status: "200",
+			v1: s.statsRange("StatsV1", map[string]*openapi.Schema{
+				"stats":       periodStats,
+				"time_period": {Type: "string"},
+			}, "stats"),
+			data: periodStats,
+			meta: s.statsRange("StatsMeta", map[string]*openapi.Schema{
+				"time_period": {Type: "string", Nullable: true},
+			}),
+			paginated: true,
+		},
+		{
+			method: http.MethodGet, path: "/temp/export", id: "exportTempSensorData", tag: "temp", role: database.RoleViewer,
+			summary: "Stream a time range as CSV, NDJSON or Parquet",
+			params: append(timeRangeParams(),
+				queryParam("format", "", false, openapi.Enum(service.ExportFormatCSV, service.ExportFormatNDJSON, service.ExportFormatParquet)),
+				queryParam("resolution", "", false, openapi.Enum("raw", "1m", "5m", "15m", "1h", "1d")),
+				queryParam("sensor", "Comma separated: main, ac_outlet", false, &openapi.Schema{Type: "string"}),
+				queryParam("metric", "Comma separated: temperature, humidity, dew_point, absolute_humidity, heat_index, humidex", false, &openapi.Schema{Type: "string"}),
+				excludeFlagsParam,
+			),
+			status: "200",
+			files: []string{
+				service.ExportContentType(service.ExportFormatCSV),
+				service.ExportContentType(service.ExportFormatNDJSON),
+				service.ExportContentType(service.ExportFormatParquet),
+			},
+		},
+		{
+			method: http.MethodPost, path: "/temp/import", id: "importTempSensorData", tag: "temp",
+			role:    database.RoleOperator + " or " + database.RoleIngestDevice,
+			summary: "Load historical readings from CSV or NDJSON",
+			params: []openapi.Parameter{
+				queryParam("format", "", false, openapi.Enum("csv", "ndjson")),
+				queryParam("mapping", "field=column pairs", false, &openapi.Schema{Type: "string"}),
+				queryParam("timezone", "IANA name for timestamps without offset", false, &openapi.Schema{Type: "string"}),
+				queryParam("timestamp_layout", "Go time layout", false, &openapi.Schema{Type: "string"}),
+				queryParam("batch_size", "", false, &openapi.Schema{Type: "integer", Minimum: number(1), Maximum: number(5000)}),
+				queryParam("dry_run", "", false, &openapi.Schema{Type: "boolean"}),
+			},
+			body: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+				"text/csv":             {Schema: &openapi.Schema{Type: "string"}},
+				"application/x-ndjson": {Schema: &openapi.Schema{Type: "string"}},
+				"multipart/form-data": {Schema: openapi.Object(map[string]*openapi.Schema{
+					"file": {Type: "string", Format: "binary"},
+				}, "file")},
+			}},
+			status: "200", v1: importReport, data: importReport,
+		},
+		{
+			method: http.MethodGet, path: "/migrations/status", id: "getMigrationStatus", tag: "migrations", role: database.RoleOperator,
+			summary: "Applied and pending migrations with drift",
+			status:  "200",
+			v1: openapi.Object(map[string]*openapi.Schema{
+				"migrations": openapi.ArrayOf(migration),
+				"total":      {Type: "integer"},
+				"drift":      {Type: "integer"},
+			}, "migrations", "total", "drift"),
+			data:      openapi.ArrayOf(migration),
+			meta:      openapi.Object(map[string]*openapi.Schema{"drift": {Type: "integer"}}, "drift"),
+			paginated: true,
+		},
+		{
+			method: http.MethodPost, path: "/quality/redetect", id: "redetectQualityFlags", tag: "quality", role: database.RoleOperator,
+			summary: "Re-run outlier detection over a time range",
+			params:  rfc3339Range,
+			status:  "200", v1: rangeUpdate, data: rangeUpdate,
+		},
+		{
+			method: http.MethodGet, path: "/calibrations", id: "getSensorCalibrations", tag: "calibrations", role: database.RoleViewer,
+			summary: "Calibrations, newest first",
+			params:  []openapi.Parameter{queryParam("sensor", "", false, openapi.Enum(database.SensorMain, database.SensorACOutlet))},
+			status:  "200",
+			v1: openapi.Object(map[string]*openapi.Schema{
+				"calibrations": openapi.ArrayOf(calibration),
+				"total":        {Type: "integer"},
+			}, "calibrations", "total"),
+			data: openapi.ArrayOf(calibration), paginated: true,
+		},
+		{
+			method: http.MethodPost, path: "/calibrations", id: "createSensorCalibration", tag: "calibrations", role: database.RoleOperator,
+			summary: "Add a calibration, optionally recomputing stored readings",
+			body: jsonBody(s.gen.Define("CalibrationRequest", openapi.Object(map[string]*openapi.Schema{
+				"sensor":         openapi.Enum(database.SensorMain, database.SensorACOutlet),
+				"metric":         openapi.Enum(database.MetricTemperature, database.MetricHumidity),
+				"offset":         {Type: "number"},
+				"slope":          {Type: "number", Description: "Defaults to 1, cannot be 0"},
+				"effective_from": {Type: "string", Format: "date-time"},
+				"note":           {Type: "string"},
+				"recompute":      {Type: "boolean"},
+			}, "sensor", "metric", "effective_from"))),
+			status: "201",
+			v1: openapi.Object(map[string]*openapi.Schema{
+				"calibration":      calibration,
+				"recomputed_count": {Type: "integer", Description: "Only with recompute"},
+			}, "calibration"),
+			data: calibration,
+			meta: openapi.Object(map[string]*openapi.Schema{
+				"recomputed_count": {Type: "integer", Nullable: true},
+			}, "recomputed_count"),
+		},
+		{
+			method: http.MethodPost, path: "/calibrations/recompute", id: "recalibrateSensorData", tag: "calibrations", role: database.RoleOperator,
+			summary: "Recompute calibrated values of a time range",
+			params:  rfc3339Range,
+			status:  "200", v1: rangeUpdate, data: rangeUpdate,
+		},
+		{
+			method: http.MethodGet, path: "/keys", id: "getAPIKeys", tag: "keys", role: database.RoleAdmin,
+			summary: "API keys including revoked ones, newest first",
+			status:  "200",
+			v1: openapi.Object(map[string]*openapi.Schema{
+				"api_keys": openapi.ArrayOf(apiKey),
+				"total":    {Type: "integer"},
+			}, "api_keys", "total"),
+			data: openapi.ArrayOf(apiKey), paginated: true,
+		},
+		{
+			method: http.MethodPost, path: "/keys", id: "createAPIKey", tag: "keys", role: database.RoleAdmin,
+			summary: "Create an API key",
+			body: jsonBody(s.gen.Define("APIKeyRequest", openapi.Object(map[string]*openapi.Schema{
+				"name":       {Type: "string"},
+				"role":       openapi.Enum(database.RoleViewer, database.RoleOperator, database.RoleAdmin, database.RoleIngestDevice),
+				"expires_at": {Type: "string", Format: "date-time", Nullable: true},
+			}, "name", "role"))),
+			status: "201", v1: issuedKey, data: issuedKey,
+		},
+		{
+			method: http.MethodPost, path: "/keys/{id}/rotate", id: "rotateAPIKey", tag: "keys", role: database.RoleAdmin,
+			summary: "Issue a new key with the same name and role and revoke the old one",
+			params:  []openapi.Parameter{keyID},
+			status:  "201", v1: issuedKey, data: issuedKey,
+		},
+		{
+			method: http.MethodDelete, path: "/keys/{id}", id: "revokeAPIKey", tag: "keys", role: database.RoleAdmin,
+			summary: "Revoke an API key",
+			params:  []openapi.Parameter{keyID},
+			status:  "200",
+			v1:      openapi.Object(map[string]*openapi.Schema{"api_key": apiKey}, "api_key"),
+			data:    apiKey,
+		},
+	}
+}
+
+var excludeFlagsParam = queryParam("exclude_flags", "Comma separated: outlier, interpolated, late, spooled, manual_edit, or any", false, &openapi.Schema{Type: "string"})
+
+// timeRangeParams are the parameters resolved by resolveTimeRange
+func timeRangeParams() []openapi.Parameter {
+	timeValue := &openapi.Schema{Type: "string", Description: timeValueHelp}
+	return []openapi.Parameter{
+		queryParam("time_period", "Range ending now (6h, 90d, now-2w) or a calendar period: "+strings.Join(calendarPeriods, ", "), false, &openapi.Schema{Type: "string"}),
+		queryParam("start_time", "Time range, with end_time", false, timeValue),
+		queryParam("end_time", "Time range, with start_time", false, timeValue),
+		queryParam("timezone", "IANA name for calendar periods, dates and bucket alignment; default UTC", false, &openapi.Schema{Type: "string"}),
+	}
+}
+
+func historyParams(version int) []openapi.Parameter {
+	params := append(timeRangeParams(),
+		queryParam("limit", "Readings or sampled points", false, &openapi.Schema{Type: "integer", Minimum: number(1), Maximum: number(1000)}),
+		queryParam("offset", "Offset mode", false, &openapi.Schema{Type: "integer", Minimum: number(0)}),
+		queryParam("term", "Term mode: every term-th reading", false, &openapi.Schema{Type: "integer", Minimum: number(0)}),
+		queryParam("fill", "Empty time range slots", false, openapi.Enum(string(database.FillNull), string(database.FillPrevious), string(database.FillLinear))),
+		queryParam("pagination", "", false, openapi.Enum(historyModeOffset, historyModeCursor)),
+		queryParam("cursor", "Opaque cursor from next_cursor or prev_cursor", false, &openapi.Schema{Type: "string"}),
+		queryParam("include_aggregates", "", false, &openapi.Schema{Type: "boolean"}),
+		queryParam("aggregate_window", "± readings of the configurable aggregate", false, &openapi.Schema{Type: "integer", Minimum: number(1), Maximum: number(500)}),
+		queryParam("downsample", "Time range only. nearest: one reading per slot; lttb: Largest-Triangle-Three-Buckets; minmax: lowest and highest reading per bucket", false, openapi.Enum(database.DownsampleNearest, database.DownsampleLTTB, database.DownsampleMinMax)),
+		queryParam("downsample_metric", "Metric lttb and minmax select points by", false, openapi.Enum(database.AggregateMetrics...)),
+		queryParam("aggregate", "Per-bucket aggregates, time range only. Comma separated: mean, min, max, median, p1-p99, stddev, first, last, count, twa", false, &openapi.Schema{Type: "string"}),
+		queryParam("aggregate_metrics", "Comma separated: "+strings.Join(database.AggregateMetrics, ", "), false, &openapi.Schema{Type: "string"}),
+		excludeFlagsParam,
+	)
+	if version == 1 {
+		params = append(params, queryParam("version", "2 returns nullable points with gap markers", false, &openapi.Schema{Type: "integer", Enum: []interface{}{1, 2}}))
+	}
+	return params
+}
+
+// historyV1 is the union of the v1 history shapes; which fields are present
+// depends on the mode
+func (s *specBuilder) historyV1(point, nullablePoint *openapi.Schema) *openapi.Schema {
+	dateTime := &openapi.Schema{Type: "string", Format: "date-time"}
+	return s.gen.Define("HistoryV1", openapi.Object(map[string]*openapi.Schema{
+		"data":               {AnyOf: []*openapi.Schema{openapi.ArrayOf(point), openapi.ArrayOf(nullablePoint)}},
+		"limit":              {Type: "integer"},
+		"offset":             {Type: "integer"},
+		"term":               {Type: "integer"},
+		"time_period":        {Type: "string"},
+		"start_time":         dateTime,
+		"end_time":           dateTime,
+		"timezone":           {Type: "string"},
+		"bucket_size":        {Type: "string"},
+		"bucket_aggregation": s.bucketAggregationMeta(),
+		"downsample":         s.downsampleMeta(),
+		"total_count":        {Type: "integer"},
+		"returned_count":     {Type: "integer"},
+		"fill":               {Type: "string"},
+		"version":            {Type: "integer"},
+		"pagination":         openapi.Enum(historyModeCursor),
+		"next_cursor":        {Type: "string", Nullable: true},
+		"prev_cursor":        {Type: "string", Nullable: true},
+		"aggregation":        s.aggregationMeta(),
+	}, "data", "limit"))
+}
+
+func (s *specBuilder) historyMeta() *openapi.Schema {
+	return s.gen.Define("HistoryMeta", openapi.Object(map[string]*openapi.Schema{
+		"mode":               openapi.Enum(historyModeTimeRange, historyModeCursor, historyModeTerm, historyModeOffset),
+		"time_period":        {Type: "string", Nullable: true},
+		"start_time":         {Type: "string", Format: "date-time"},
+		"end_time":           {Type: "string", Format: "date-time"},
+		"timezone":           {Type: "string"},
+		"bucket_size":        {Type: "string", Nullable: true, Description: "Calendar bucket size when aligned to local midnight, e.g. 1h, 1d, 1mo"},
+		"bucket_aggregation": openapi.Nullable(s.bucketAggregationMeta()),
+		"downsample":         openapi.Nullable(s.downsampleMeta()),
+		"fill":               openapi.Enum(string(database.FillNull), string(database.FillPrevious), string(database.FillLinear)),
+		"term":               {Type: "integer"},
+		"exclude_flags":      openapi.ArrayOf(&openapi.Schema{Type: "string"}),
+		"aggregation":        s.aggregationMeta(),
+	}, "mode", "exclude_flags", "aggregation"))
+}
+
+// statsRange defines name as properties plus the range fields shared by the
+// v1 body and the v2 meta of /temp/stats
+func (s *specBuilder) statsRange(name string, properties map[string]*openapi.Schema, required ...string) *openapi.Schema {
+	dateTime := &openapi.Schema{Type: "string", Format: "date-time"}
+	properties["period"] = openapi.Enum("daily", "weekly")
+	properties["start_time"] = dateTime
+	properties["end_time"] = dateTime
+	properties["timezone"] = &openapi.Schema{Type: "string"}
+	properties["interval_seconds"] = &openapi.Schema{Type: "number", Description: "Collection interval the completeness is based on"}
+	required = append(required, "period", "time_period", "start_time", "end_time", "timezone", "interval_seconds")
+	return s.gen.Define(name, openapi.Object(properties, required...))
+}
+
+func (s *specBuilder) downsampleMeta() *openapi.Schema {
+	return s.gen.Define("DownsampleMeta", openapi.Object(map[string]*openapi.Schema{
+		"mode":   openapi.Enum(database.DownsampleLTTB, database.DownsampleMinMax),
+		"metric": openapi.Enum(database.AggregateMetrics...),
+	}, "mode", "metric"))
+}
+
+func (s *specBuilder) bucketAggregationMeta() *openapi.Schema {
+	names := openapi.ArrayOf(&openapi.Schema{Type: "string"})
+	return s.gen.Define("BucketAggregationMeta", openapi.Object(map[string]*openapi.Schema{
+		"functions": names,
+		"metrics":   names,
+	}, "functions", "metrics"))
+}
+
+func (s *specBuilder) aggregationMeta() *openapi.Schema {
+	return s.gen.Define("AggregationMeta", openapi.Object(map[string]*openapi.Schema{
+		"enabled":     {Type: "boolean"},
+		"window_size": {Type: "integer", Nullable: true},
+	}, "enabled", "window_size"))
+}
+
+func queryParam(name, description string, required bool, schema *openapi.Schema) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Required: required, Schema: schema}
+}
+
+func jsonBody(schema *openapi.Schema) *openapi.RequestBody {
+	return &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{"application/json": {Schema: schema}}}
+}
+
+func jsonResponse(description string, schema *openapi.Schema) *openapi.Response {
+	return &openapi.Response{Description: description, Content: map[string]openapi.MediaType{"application/json": {Schema: schema}}}
+}
+
+func number(n float64) *float64 {
+	return &n
+}
+
+// checkRoutes reports routes that are served but not documented or documented
+// but not served
+func checkRoutes(r *gin.Engine, spec *openapi.Document) []string {
+	var served []openapi.Route
+	for _, info := range r.Routes() {
+		served = append(served, openapi.Route{Method: info.Method, Path: info.Path})
+	}
+	return spec.CompareRoutes(served)
+}
+
+// serveSpec answers GET /openapi.json with the document encoded once
+func serveSpec(spec *openapi.Document) (gin.HandlerFunc, error) {
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", encoded)
+	}, nil
+}
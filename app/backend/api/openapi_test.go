@@ -0,0 +1,197 @@
+package api
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"knet_management/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contractDatabase answers the queries of every handler with one plausible row
+// (three readings for temp_sensor_data) so success responses can be checked
+func contractDatabase(now time.Time) (*database.Database, *stubDB) {
+	var readings [][]driver.Value
+	for i, minutes := range []int{50, 30, 10} {
+		at := now.Add(-time.Duration(minutes) * time.Minute).In(time.Local)
+		readings = append(readings, []driver.Value{int64(i + 1), 23.5, 45.0, 19.0, nil, at, []byte("{}"), 23.5, 45.0, 19.0, nil})
+	}
+
+	return newStubDatabase(
+		stubQuery{match: "information_schema.columns", columns: make([]string, 2), rows: [][]driver.Value{{true, true}}},
+		stubQuery{match: "SELECT COUNT(*)", columns: []string{"count"}, rows: [][]driver.Value{{int64(len(readings))}}},
+		stubQuery{match: "SELECT id FROM temp_sensor_data", columns: []string{"id"}, rows: [][]driver.Value{{int64(len(readings))}}},
+		stubQuery{match: "id, temperature, humidity, ac_outlet_temperature", columns: make([]string, 11), rows: readings},
+		stubQuery{match: "SELECT temperature, humidity, quality_flags", columns: make([]string, 3), rows: [][]driver.Value{{23.5, 45.0, []byte("{}")}}},
+		stubQuery{match: "SELECT temperature, humidity, ac_outlet_temperature", columns: make([]string, 5), rows: [][]driver.Value{{23.5, 45.0, 19.0, 60.0, []byte("{}")}, {23.7, 44.0, nil, nil, []byte("{}")}}},
+		stubQuery{match: "id, name, role, key_prefix", columns: make([]string, 9), rows: [][]driver.Value{{int64(1), "dashboard", database.RoleViewer, "knet_abcdefgh", now, nil, now, nil, nil}}},
+		stubQuery{match: "RETURNING name, role, expires_at", columns: make([]string, 3), rows: [][]driver.Value{{"dashboard", database.RoleViewer, nil}}},
+		stubQuery{match: "COALESCE(note, '')", columns: make([]string, 8), rows: [][]driver.Value{{int64(1), database.SensorMain, database.MetricTemperature, -0.5, 1.0, now, "", now}}},
+		stubQuery{match: "DISTINCT ON (sensor, metric)", columns: make([]string, 6), rows: [][]driver.Value{{int64(1), database.SensorMain, database.MetricTemperature, -0.5, 1.0, now}}},
+		stubQuery{match: "RETURNING id, created_at", columns: make([]string, 2), rows: [][]driver.Value{{int64(2), now}}},
+		stubQuery{match: "RETURNING id", columns: []string{"id"}, rows: [][]driver.Value{{int64(4)}}},
+		stubQuery{match: "SELECT version, COALESCE(description, '')", columns: make([]string, 4), rows: [][]driver.Value{{"001_initial_schema", "Initial schema", now, ""}}},
+		stubQuery{match: "SELECT version FROM schema_version", columns: []string{"version"}, rows: [][]driver.Value{{"001_initial_schema"}}},
+	)
+}
+
+// contractRequest is one request of TestResponsesMatchSpec
+type contractRequest struct {
+	method      string
+	route       string // gin route the request matches
+	id          string // Replaces :id; 1 when empty
+	query       string
+	contentType string
+	body        string
+}
+
+func contractRequests(now time.Time) []contractRequest {
+	end := now.UTC().Truncate(time.Second)
+	start := end.Add(-time.Hour)
+	timeRange := "start_time=" + start.Format(time.RFC3339) + "&end_time=" + end.Format(time.RFC3339)
+	importCSV := "timestamp,temperature,humidity\n" + start.Format(time.RFC3339) + ",23.5,50.1\nnot-a-time,1,2\n"
+	calibration := fmt.Sprintf(`{"sensor": "main", "metric": "temperature", "offset": -0.5, "effective_from": %q, "recompute": true}`, start.Format(time.RFC3339))
+
+	requests := []contractRequest{
+		{method: http.MethodGet, route: "/health"},
+		{method: http.MethodGet, route: "/livez"},
+		{method: http.MethodGet, route: "/readyz"},
+		{method: http.MethodGet, route: "/openapi.json"},
+	}
+	for _, prefix := range []string{"/api", "/api/v2"} {
+		requests = append(requests,
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/latest"},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/history"},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/history", query: "limit=5&offset=1"},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/history", query: "limit=5&term=2"},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/history", query: "limit=2&pagination=cursor"},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/history", query: "limit=20&time_period=1d&include_aggregates=true"},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/history", query: "limit=10&fill=linear&" + timeRange},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/history", query: "limit=24&time_period=today&timezone=Asia/Seoul"},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/history", query: "limit=12&time_period=1d&aggregate=mean,p95,count,twa&aggregate_metrics=temperature,ac_outlet_dew_point"},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/history", query: "time_period=1d&downsample=lttb&downsample_metric=temperature"},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/history", query: "time_period=1d&downsample=minmax&aggregate=max"},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/history", query: "time_period=2x"},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/stats"},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/stats", query: "period=weekly&time_period=last_month&timezone=Asia/Seoul"},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/stats", query: "period=monthly"},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/export", query: "format=ndjson&" + timeRange},
+			contractRequest{method: http.MethodGet, route: prefix + "/temp/export", query: "format=xml&" + timeRange},
+			contractRequest{method: http.MethodPost, route: prefix + "/temp/import", contentType: "text/csv", body: importCSV},
+			contractRequest{method: http.MethodPost, route: prefix + "/temp/import", query: "dry_run=true", contentType: "text/csv", body: importCSV},
+			contractRequest{method: http.MethodPost, route: prefix + "/temp/import", query: "format=xml", contentType: "text/csv"},
+			contractRequest{method: http.MethodGet, route: prefix + "/migrations/status"},
+			contractRequest{method: http.MethodPost, route: prefix + "/quality/redetect", query: timeRange},
+			contractRequest{method: http.MethodPost, route: prefix + "/quality/redetect"},
+			contractRequest{method: http.MethodGet, route: prefix + "/calibrations"},
+			contractRequest{method: http.MethodPost, route: prefix + "/calibrations", contentType: "application/json", body: calibration},
+			contractRequest{method: http.MethodPost, route: prefix + "/calibrations", contentType: "application/json", body: "{}"},
+			contractRequest{method: http.MethodPost, route: prefix + "/calibrations/recompute", query: timeRange},
+			contractRequest{method: http.MethodPost, route: prefix + "/calibrations/recompute", query: "start_time=yesterday"},
+			contractRequest{method: http.MethodGet, route: prefix + "/keys"},
+			contractRequest{method: http.MethodPost, route: prefix + "/keys", contentType: "application/json", body: `{"name": "dashboard", "role": "viewer"}`},
+			contractRequest{method: http.MethodPost, route: prefix + "/keys", contentType: "application/json", body: `{"name": "dashboard", "role": "root"}`},
+			contractRequest{method: http.MethodPost, route: prefix + "/keys/:id/rotate"},
+			contractRequest{method: http.MethodDelete, route: prefix + "/keys/:id"},
+			contractRequest{method: http.MethodDelete, route: prefix + "/keys/:id", id: "x"},
+		)
+	}
+	return requests
+}
+
+// Every documented route answers with a status, content type and body the
+// document describes, running the handlers against a stub database
+func TestResponsesMatchSpec(t *testing.T) {
+	now := time.Now()
+	db, _ := contractDatabase(now)
+	router, err := SetupRoutes(db, database.NewMigrationManager(db), Config{
+		Auth: AuthConfig{AnonymousRole: database.RoleAdmin},
+		CORS: CORSConfig{AllowOrigins: []string{"*"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := Spec()
+
+	exercised := make(map[string]bool)
+	for _, tc := range contractRequests(now) {
+		id := tc.id
+		if id == "" {
+			id = "1"
+		}
+		path := strings.ReplaceAll(tc.route, ":id", id)
+		if tc.query != "" {
+			path += "?" + tc.query
+		}
+		t.Run(tc.method+" "+path, func(t *testing.T) {
+			request := httptest.NewRequest(tc.method, path, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				request.Header.Set("Content-Type", tc.contentType)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			// The stub database does not fail, so a server error is a bug. /readyz
+			// answers 503 because the stub applied only the first migration.
+			if recorder.Code >= http.StatusInternalServerError && tc.route != "/readyz" {
+				t.Errorf("-> %d: %s", recorder.Code, recorder.Body.String())
+			}
+			exercised[tc.method+" "+tc.route] = true
+			for _, problem := range spec.ValidateResponse(tc.method, tc.route, recorder.Code, recorder.Header().Get("Content-Type"), recorder.Body.Bytes()) {
+				t.Errorf("-> %d: %s", recorder.Code, problem)
+			}
+		})
+	}
+
+	for _, route := range spec.Routes() {
+		if !exercised[route.Method+" "+route.Path] {
+			t.Errorf("%s %s is documented but not exercised", route.Method, route.Path)
+		}
+	}
+}
+
+func TestCheckRoutes(t *testing.T) {
+	r, err := SetupRoutes(nil, nil, Config{CORS: CORSConfig{AllowOrigins: []string{"*"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := Spec()
+	if problems := checkRoutes(r, spec); len(problems) > 0 {
+		t.Fatalf("checkRoutes = %v, want none", problems)
+	}
+
+	r.GET("/api/undocumented", func(c *gin.Context) {})
+	if problems := checkRoutes(r, spec); len(problems) != 1 || !strings.Contains(problems[0], "/api/undocumented") {
+		t.Errorf("checkRoutes with an undocumented route = %v", problems)
+	}
+}
+
+// ValidateResponse must reject what TestResponsesMatchSpec relies on it to catch
+func TestValidateResponseRejectsDrift(t *testing.T) {
+	spec := Spec()
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{name: "undocumented status", status: http.StatusCreated, contentType: "application/json", body: `{}`},
+		{name: "wrong content type", status: http.StatusOK, contentType: "text/plain", body: `{}`},
+		{name: "missing field", status: http.StatusOK, contentType: "application/json", body: `{"api_keys": []}`},
+		{name: "wrong type", status: http.StatusOK, contentType: "application/json", body: `{"api_keys": [], "total": "1"}`},
+		{name: "v1 error shape", status: http.StatusBadRequest, contentType: "application/json", body: `{"message": "bad"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if problems := spec.ValidateResponse(http.MethodGet, "/api/keys", tt.status, tt.contentType, []byte(tt.body)); len(problems) == 0 {
+				t.Error("ValidateResponse accepted the response")
+			}
+		})
+	}
+}
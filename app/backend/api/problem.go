@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"knet_management/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes of /api/v2 problem responses. Clients should switch on code;
+// title and detail are for people.
+const (
+	codeInvalidParameter = "invalid_parameter"
+	codeInvalidBody      = "invalid_body"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeBodyTooLarge     = "body_too_large"
+	codeImportFailed     = "import_failed"
+	codeRateLimited      = "rate_limited"
+	codeInternal         = "internal_error"
+	codeQueryTimeout     = "query_timeout"
+)
+
+var problemTitles = map[string]string{
+	codeInvalidParameter: "Invalid query or path parameter",
+	codeInvalidBody:      "Invalid request body",
+	codeUnauthorized:     "Authentication required",
+	codeForbidden:        "Not allowed",
+	codeNotFound:         "Not found",
+	codeBodyTooLarge:     "Request body too large",
+	codeImportFailed:     "Import failed",
+	codeRateLimited:      "Rate limit exceeded",
+	codeInternal:         "Internal server error",
+	codeQueryTimeout:     "Query timed out",
+}
+
+const (
+	problemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:knet:problem:"
+	apiV2Prefix        = "/api/v2/"
+)
+
+// invalidParam names one rejected parameter in a problem's invalid_params
+type invalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// isAPIv2 reports whether the request is for an /api/v2 route, which answers
+// errors as RFC 7807 problems and data in an envelope
+func isAPIv2(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.URL.Path, apiV2Prefix)
+}
+
+// abortError answers with status. v1 routes get {"error": detail} plus the
+// extension fields, as before; v2 routes get an application/problem+json body
+// with code and the extension fields as extension members.
+func abortError(c *gin.Context, status int, code, detail string, extensions ...gin.H) {
+	body := gin.H{}
+	for _, extension := range extensions {
+		for key, value := range extension {
+			body[key] = value
+		}
+	}
+
+	if !isAPIv2(c) {
+		body["error"] = detail
+		c.AbortWithStatusJSON(status, body)
+		return
+	}
+
+	body["type"] = problemTypePrefix + code
+	body["title"] = problemTitles[code]
+	body["status"] = status
+	body["detail"] = detail
+	body["instance"] = c.Request.URL.Path
+	body["code"] = code
+	if requestID := logging.RequestID(c.Request.Context()); requestID != "" {
+		body["request_id"] = requestID
+	}
+
+	// gin keeps a Content-Type that is already set
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(status, body)
+}
+
+// abortInvalidParams answers 400 invalid_parameter. The reasons are joined
+// into detail; v2 also lists them per parameter in invalid_params.
+func abortInvalidParams(c *gin.Context, params []invalidParam) {
+	reasons := make([]string, len(params))
+	for i, param := range params {
+		reasons[i] = param.Reason
+	}
+	detail := strings.Join(reasons, "; ")
+
+	if !isAPIv2(c) {
+		abortError(c, http.StatusBadRequest, codeInvalidParameter, detail)
+		return
+	}
+	abortError(c, http.StatusBadRequest, codeInvalidParameter, detail, gin.H{"invalid_params": params})
+}
+
+func abortInvalidParam(c *gin.Context, name, reason string) {
+	abortInvalidParams(c, []invalidParam{{Name: name, Reason: reason}})
+}
+
+// notFound answers unknown /api/v2 routes with a problem; other paths keep
+// gin's plain 404
+func notFound(c *gin.Context) {
+	if isAPIv2(c) {
+		abortError(c, http.StatusNotFound, codeNotFound, "No route for "+c.Request.Method+" "+c.Request.URL.Path)
+	}
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"knet_management/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redetectQualityFlags re-runs outlier detection over a historical time range
+func redetectQualityFlags(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime, err := time.Parse(time.RFC3339, c.Query("start_time"))
+		if err != nil {
+			abortInvalidParam(c, "start_time", "Invalid start_time format. Use RFC3339 (ISO 8601)")
+			return
+		}
+
+		endTime, err := time.Parse(time.RFC3339, c.Query("end_time"))
+		if err != nil {
+			abortInvalidParam(c, "end_time", "Invalid end_time format. Use RFC3339 (ISO 8601)")
+			return
+		}
+
+		if startTime.After(endTime) {
+			abortInvalidParam(c, "start_time", "start_time cannot be after end_time")
+			return
+		}
+
+		updated, err := db.RedetectQualityFlags(c.Request.Context(), startTime, endTime)
+		if err != nil {
+			abortDatabaseError(c, err, "Failed to re-run quality detection")
+			return
+		}
+
+		result := gin.H{
+			"start_time":    startTime.Format(time.RFC3339),
+			"end_time":      endTime.Format(time.RFC3339),
+			"updated_count": updated,
+		}
+		respond(c, http.StatusOK, result, envelope{Data: result})
+	}
+}
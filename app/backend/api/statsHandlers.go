@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"knet_management/database"
+	"knet_management/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatsConfig sets what /api/temp/stats reports
+type StatsConfig struct {
+	Thresholds []database.StatsThreshold
+	Interval   time.Duration                    // Collection interval for completeness
+	Collector  *service.TempSensorDataCollector // Its current interval wins over Interval; nil uses Interval
+}
+
+// Summary periods of /api/temp/stats and their bucket sizes
+var statsPeriods = map[string]string{
+	"daily":  "1d",
+	"weekly": "1w",
+}
+
+// Stats ranges are read into memory at once; a year at a 30s interval is
+// about a million readings
+const maxStatsRange = 366 * 24 * time.Hour
+
+// getTempSensorStats summarizes every day or week of a time range per sensor.
+// Periods start at local midnight (Monday for weeks) in the requested
+// timezone; the first and last period are cut to the range.
+func getTempSensorStats(db *database.Database, cfg StatsConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		period := c.DefaultQuery("period", "daily")
+		size, ok := statsPeriods[period]
+		if !ok {
+			abortInvalidParam(c, "period", "Invalid period. Use: daily, weekly")
+			return
+		}
+
+		timePeriod := c.Query("time_period")
+		if timePeriod != "" && (c.Query("start_time") != "" || c.Query("end_time") != "") {
+			abortInvalidParam(c, "time_period", "Use either time_period or start_time/end_time, not both")
+			return
+		}
+		if timePeriod == "" && c.Query("start_time") == "" && c.Query("end_time") == "" {
+			timePeriod = "7d"
+		}
+		timeSpan, problems := resolveTimeRange(timePeriod, c.Query("start_time"), c.Query("end_time"), c.Query("timezone"), time.Now())
+		if len(problems) > 0 {
+			abortInvalidParams(c, problems)
+			return
+		}
+		if timeSpan.end.Sub(timeSpan.start) > maxStatsRange {
+			abortInvalidParam(c, "time_period", "Stats ranges are limited to 366 days")
+			return
+		}
+
+		interval := cfg.Interval
+		if cfg.Collector != nil {
+			interval = cfg.Collector.Health().Interval
+		}
+
+		stats, err := db.GetTempSensorStats(c.Request.Context(), database.StatsOptions{
+			Periods:    database.CalendarBuckets(timeSpan.start, timeSpan.end, timeSpan.location, size),
+			Interval:   interval,
+			Thresholds: cfg.Thresholds,
+		})
+		if err != nil {
+			abortDatabaseError(c, err, "Failed to calculate stats")
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{
+			"stats":            stats,
+			"period":           period,
+			"time_period":      timeSpan.period,
+			"start_time":       timeSpan.start,
+			"end_time":         timeSpan.end,
+			"timezone":         timeSpan.location.String(),
+			"interval_seconds": interval.Seconds(),
+		}, envelope{
+			Data: stats,
+			Meta: gin.H{
+				"period":           period,
+				"time_period":      nullableString(timeSpan.period),
+				"start_time":       timeSpan.start,
+				"end_time":         timeSpan.end,
+				"timezone":         timeSpan.location.String(),
+				"interval_seconds": interval.Seconds(),
+			},
+			Pagination: completeList(len(stats)),
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"knet_management/database"
+)
+
+// stubQuery answers every query containing match with rows of columns
+type stubQuery struct {
+	match   string
+	columns []string
+	rows    [][]driver.Value
+}
+
+// stubDB is a database/sql connector that answers queries with canned rows,
+// so handlers run without PostgreSQL. Queries without a match return no rows;
+// statements succeed affecting one row, or fail with execErr, and are counted.
+// Queries take delay, or until their context ends. Every query and statement
+// is kept in committed once it takes effect: right away outside a transaction,
+// on commit inside one.
+type stubDB struct {
+	queries []stubQuery
+	execs   int32
+	execErr error
+	delay   time.Duration
+
+	mu        sync.Mutex
+	committed []string
+}
+
+func newStubDatabase(queries ...stubQuery) (*database.Database, *stubDB) {
+	stub := &stubDB{queries: queries}
+	return &database.Database{DB: sql.OpenDB(stub)}, stub
+}
+
+// committedMatching returns the committed queries and statements containing match
+func (s *stubDB) committedMatching(match string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var found []string
+	for _, query := range s.committed {
+		if strings.Contains(query, match) {
+			found = append(found, query)
+		}
+	}
+	return found
+}
+
+func (s *stubDB) Connect(context.Context) (driver.Conn, error) { return &stubConn{db: s}, nil }
+func (s *stubDB) Driver() driver.Driver                        { return stubDriver{s} }
+
+type stubDriver struct{ db *stubDB }
+
+func (d stubDriver) Open(string) (driver.Conn, error) { return &stubConn{db: d.db}, nil }
+
+// stubConn holds the queries of its open transaction until it ends
+type stubConn struct {
+	db      *stubDB
+	inTx    bool
+	pending []string
+}
+
+func (c *stubConn) Prepare(query string) (driver.Stmt, error) { return stubStmt{c, query}, nil }
+func (c *stubConn) Close() error                              { return nil }
+func (c *stubConn) Ping(context.Context) error                { return nil }
+
+func (c *stubConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	return stubTx{c}, nil
+}
+
+func (c *stubConn) QueryContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	select {
+	case <-time.After(c.db.delay):
+		return c.query(query), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *stubConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	return c.exec(query)
+}
+
+func (c *stubConn) record(query string) {
+	if c.inTx {
+		c.pending = append(c.pending, query)
+		return
+	}
+	c.db.mu.Lock()
+	c.db.committed = append(c.db.committed, query)
+	c.db.mu.Unlock()
+}
+
+func (c *stubConn) exec(query string) (driver.Result, error) {
+	atomic.AddInt32(&c.db.execs, 1)
+	if c.db.execErr != nil {
+		return nil, c.db.execErr
+	}
+	c.record(query)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *stubConn) query(query string) driver.Rows {
+	c.record(query)
+	for _, q := range c.db.queries {
+		if strings.Contains(query, q.match) {
+			return &stubRows{columns: q.columns, rows: q.rows}
+		}
+	}
+	return &stubRows{}
+}
+
+type stubStmt struct {
+	conn  *stubConn
+	query string
+}
+
+func (s stubStmt) Close() error  { return nil }
+func (s stubStmt) NumInput() int { return -1 }
+
+func (s stubStmt) Exec([]driver.Value) (driver.Result, error) { return s.conn.exec(s.query) }
+func (s stubStmt) Query([]driver.Value) (driver.Rows, error)  { return s.conn.query(s.query), nil }
+
+type stubTx struct{ conn *stubConn }
+
+func (tx stubTx) Commit() error {
+	tx.conn.db.mu.Lock()
+	tx.conn.db.committed = append(tx.conn.db.committed, tx.conn.pending...)
+	tx.conn.db.mu.Unlock()
+	return tx.Rollback()
+}
+
+func (tx stubTx) Rollback() error {
+	tx.conn.inTx, tx.conn.pending = false, nil
+	return nil
+}
+
+type stubRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *stubRows) Columns() []string { return r.columns }
+func (r *stubRows) Close() error      { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.next == len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
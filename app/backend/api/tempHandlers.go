@@ -1,68 +1,144 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"knet_management/database"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(db *database.Database) *gin.Engine {
+// Config holds the router settings
+type Config struct {
+	Auth      AuthConfig
+	CORS      CORSConfig
+	RateLimit RateLimitConfig
+	Health    HealthConfig
+	Stats     StatsConfig
+
+	TrustedProxies []string // IPs or CIDRs whose X-Forwarded-For is believed; none by default
+
+	MaxBodyBytes       int64 // Request body limit of JSON write endpoints
+	MaxImportBodyBytes int64 // Request body limit of POST /api/temp/import
+}
+
+func SetupRoutes(db *database.Database, migrationManager *database.MigrationManager, cfg Config) (*gin.Engine, error) {
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
+	r := gin.New()
+	r.Use(requestID(), accessLog(), recovery())
 
-	r.Use(cors.New(cors.Config{
-		AllowAllOrigins:  true,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
-		AllowHeaders:     []string{"*"},
-		ExposeHeaders:    []string{"*"},
-		AllowCredentials: false,
-		MaxAge:           12 * time.Hour,
-	}))
+	// nil trusts no proxy: ClientIP is the peer address unless a trusted
+	// proxy sent X-Forwarded-For
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
 
+	corsHandler, err := corsMiddleware(cfg.CORS)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CORS settings: %w", err)
+	}
+	r.Use(corsHandler)
+
+	// Probes are public. /health is kept for existing checks; use /readyz
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
+	r.GET("/livez", livez(time.Now()))
+	r.GET("/readyz", readyz(db, migrationManager, cfg.Health))
+
+	// Everything below requires a role (see auth.go) and is rate limited per
+	// client IP before authentication and per API key or user after it
+	secured := r.Group("", rateLimit(cfg.RateLimit, clientIPKey), authenticate(db, cfg.Auth), rateLimit(cfg.RateLimit, principalKey))
+	viewer := requireRole(database.RoleViewer)
+	operator := requireRole(database.RoleOperator)
+	ingest := requireRole(database.RoleOperator, database.RoleIngestDevice)
+	admin := requireRole(database.RoleAdmin)
+
+	body := limitBody(cfg.MaxBodyBytes)
+	importBody := limitBody(cfg.MaxImportBodyBytes)
+
+	// v1 keeps its per-endpoint shapes; v2 wraps data in an envelope and
+	// answers errors as RFC 7807 problems (see problem.go)
+	for _, api := range []struct {
+		group   *gin.RouterGroup
+		history gin.HandlerFunc
+	}{
+		{secured.Group("/api"), getTempSensorDataHistory(db)},
+		{secured.Group("/api/v2"), getTempSensorDataHistoryV2(db)},
+	} {
+		api.group.GET("/temp/latest", viewer, getLatestTempSensorData(db))
+
+		api.group.GET("/temp/history", viewer, api.history)
+		api.group.GET("/temp/stats", viewer, getTempSensorStats(db, cfg.Stats))
+
+		api.group.GET("/temp/export", viewer, exportTempSensorData(db))
+		api.group.POST("/temp/import", ingest, importBody, importTempSensorData(db))
+
+		api.group.GET("/migrations/status", operator, getMigrationStatus(migrationManager))
+
+		api.group.POST("/quality/redetect", operator, body, redetectQualityFlags(db))
+
+		api.group.GET("/calibrations", viewer, getSensorCalibrations(db))
+		api.group.POST("/calibrations", operator, body, createSensorCalibration(db))
+		api.group.POST("/calibrations/recompute", operator, body, recalibrateSensorData(db))
+
+		api.group.GET("/keys", admin, getAPIKeys(db))
+		api.group.POST("/keys", admin, body, createAPIKey(db))
+		api.group.POST("/keys/:id/rotate", admin, body, rotateAPIKey(db))
+		api.group.DELETE("/keys/:id", admin, body, revokeAPIKey(db))
+	}
+	r.NoRoute(notFound)
 
-	r.GET("/api/temp/latest", getLatestTempSensorData(db))
-
-	r.GET("/api/temp/history", getTempSensorDataHistory(db))
+	spec := Spec()
+	specHandler, err := serveSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the OpenAPI document: %w", err)
+	}
+	r.GET("/openapi.json", specHandler)
 
-	r.GET("/api/migrations/status", getMigrationStatus(db))
+	// A route added without documenting it (or the reverse) fails at startup
+	if problems := checkRoutes(r, spec); len(problems) > 0 {
+		return nil, fmt.Errorf("routes and OpenAPI document disagree:\n  - %s", strings.Join(problems, "\n  - "))
+	}
 
-	return r
+	return r, nil
 }
 
 func getLatestTempSensorData(db *database.Database) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		data, err := db.GetLatestTempSensorData()
+		data, err := db.GetLatestTempSensorData(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get latest data"})
+			abortDatabaseError(c, err, "Failed to get latest data")
 			return
 		}
-		c.JSON(http.StatusOK, data)
+		respond(c, http.StatusOK, data, envelope{Data: database.ToNullable([]database.TempSensorData{*data})[0]})
 	}
 }
 
-func getMigrationStatus(db *database.Database) gin.HandlerFunc {
+func getMigrationStatus(migrationManager *database.MigrationManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		migrationManager := database.NewMigrationManager(db)
-		migrationsDir := "/app/migrations"
-
-		status, err := migrationManager.GetMigrationStatus(migrationsDir)
+		status, err := migrationManager.GetMigrationStatus(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get migration status"})
+			abortDatabaseError(c, err, "Failed to get migration status")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		drift := 0
+		for _, s := range status {
+			if s.Drift || s.Missing {
+				drift++
+			}
+		}
+
+		respond(c, http.StatusOK, gin.H{
 			"migrations": status,
 			"total":      len(status),
-		})
+			"drift":      drift,
+		}, envelope{Data: status, Meta: gin.H{"drift": drift}, Pagination: completeList(len(status))})
 	}
 }
 
@@ -104,92 +180,121 @@ func getTempSensorDataHistory(db *database.Database) gin.HandlerFunc {
 			}
 		}
 
+		// Parse quality filter ("outlier,late" or "any")
+		qualityFilter, err := database.ParseQualityFilter(c.Query("exclude_flags"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exclude_flags. Use: outlier, interpolated, late, spooled, manual_edit or any"})
+			return
+		}
+
+		// Parse response version (2 = nullable values with explicit gap markers) and gap fill mode
+		responseVersion := 1
+		if v := c.Query("version"); v != "" {
+			if v != "1" && v != "2" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version. Use: 1, 2"})
+				return
+			}
+			responseVersion, _ = strconv.Atoi(v)
+		}
+
+		fillMode, err := database.ParseFillMode(c.Query("fill"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fill. Use: null, previous, linear"})
+			return
+		}
+
+		// Parse downsampling and per-bucket aggregates (time range modes only)
+		sampling, problems := parseHistorySampling(c)
+		if len(problems) > 0 {
+			abortInvalidParams(c, problems[:1])
+			return
+		}
+		sampling.limit, sampling.filter = limit, qualityFilter
+
+		// Parse cursor pagination parameters (opaque keyset cursor over timestamp, id)
+		var cursor *database.PageCursor
+		useCursor := c.Query("pagination") == "cursor"
+		if cursorStr := c.Query("cursor"); cursorStr != "" {
+			cursor, err = database.DecodeCursor(cursorStr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return
+			}
+			useCursor = true
+		}
+
 		// Parse time-based parameters
-		timePeriod := c.Query("time_period")  // "1d", "1w", "1m", "1y"
-		startTimeStr := c.Query("start_time") // ISO 8601 format
-		endTimeStr := c.Query("end_time")     // ISO 8601 format
+		timePeriod := c.Query("time_period")  // "6h", "90d", "now-2w", "last_month", ...
+		startTimeStr := c.Query("start_time") // RFC3339, date, now or now-<duration>
+		endTimeStr := c.Query("end_time")     // RFC3339, date, now or now-<duration>
 
 		var data []database.TempSensorData
-		var err error
 
 		// Determine which mode to use: time-based or traditional
 		if timePeriod != "" || (startTimeStr != "" && endTimeStr != "") {
-			// Time-based filtering mode
-			var startTime, endTime time.Time
-			now := time.Now()
-
-			if timePeriod != "" {
-				// Calculate time range based on period
-				endTime = now
-				switch timePeriod {
-				case "1d":
-					startTime = now.Add(-24 * time.Hour)
-				case "1w":
-					startTime = now.Add(-7 * 24 * time.Hour)
-				case "1m":
-					startTime = now.Add(-30 * 24 * time.Hour)
-				case "1y":
-					startTime = now.Add(-365 * 24 * time.Hour)
-				default:
-					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time_period. Use: 1d, 1w, 1m, 1y"})
-					return
-				}
-			} else {
-				// Parse custom start/end times
-				var parseErr error
-				startTime, parseErr = time.Parse(time.RFC3339, startTimeStr)
-				if parseErr != nil {
-					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_time format. Use RFC3339 (ISO 8601)"})
-					return
-				}
-
-				endTime, parseErr = time.Parse(time.RFC3339, endTimeStr)
-				if parseErr != nil {
-					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_time format. Use RFC3339 (ISO 8601)"})
-					return
-				}
-
-				if startTime.After(endTime) {
-					c.JSON(http.StatusBadRequest, gin.H{"error": "start_time cannot be after end_time"})
-					return
-				}
+			// Time-based filtering mode; time_period wins over start_time/end_time
+			timeSpan, problems := resolveTimeRange(timePeriod, startTimeStr, endTimeStr, c.Query("timezone"), time.Now())
+			if len(problems) > 0 {
+				abortInvalidParams(c, problems)
+				return
 			}
+			startTime, endTime := timeSpan.start, timeSpan.end
 
-			// Use time-based sampling to get exactly 'limit' data points
-			data, err = db.GetTempSensorDataWithTimeIntervals(startTime, endTime, limit)
+			// Sample 'limit' points, in local calendar buckets for timezones and calendar periods
+			var bucketSize string
+			data, bucketSize, err = sampleTimeRange(c.Request.Context(), db, timeSpan, sampling)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sensor data with time range"})
+				abortDatabaseError(c, err, "Failed to get sensor data with time range")
 				return
 			}
 
+			data = database.FillGaps(data, fillMode)
+
 			// Always apply aggregation (default ±3 for main display + configurable if requested)
 			if includeAggregates {
-				data, err = db.GetTempSensorDataWithAggregation(data, aggregateWindowSize)
+				data, err = db.GetTempSensorDataWithAggregation(c.Request.Context(), data, aggregateWindowSize)
 			} else {
 				// Still calculate default aggregated values (±3) for main display only
-				data, err = db.GetTempSensorDataWithAggregation(data, 0) // 0 means skip configurable aggregation
+				data, err = db.GetTempSensorDataWithAggregation(c.Request.Context(), data, 0) // 0 means skip configurable aggregation
 			}
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate aggregated values"})
+				abortDatabaseError(c, err, "Failed to calculate aggregated values")
 				return
 			}
 
 			// Get total count for metadata
-			totalCount, countErr := db.GetDataCountInTimeRange(startTime, endTime)
+			totalCount, countErr := db.GetDataCountInTimeRange(c.Request.Context(), startTime, endTime, qualityFilter)
 			if countErr != nil {
 				totalCount = len(data) // fallback
 			}
 
 			response := gin.H{
-				"data":           data,
+				"data":           historyResponseData(data, responseVersion),
 				"limit":          limit,
 				"offset":         0,
 				"term":           0,
-				"time_period":    timePeriod,
+				"time_period":    timeSpan.period,
 				"start_time":     startTime.Format(time.RFC3339),
 				"end_time":       endTime.Format(time.RFC3339),
+				"timezone":       timeSpan.location.String(),
 				"total_count":    totalCount,
 				"returned_count": len(data),
+				"fill":           fillMode,
+			}
+
+			if bucketSize != "" {
+				response["bucket_size"] = bucketSize
+			}
+			downsample, bucketAggregation := samplingMeta(sampling)
+			if downsample != nil {
+				response["downsample"] = downsample
+			}
+			if bucketAggregation != nil {
+				response["bucket_aggregation"] = bucketAggregation
+			}
+
+			if responseVersion == 2 {
+				response["version"] = 2
 			}
 
 			// Add aggregation metadata if used
@@ -202,33 +307,81 @@ func getTempSensorDataHistory(db *database.Database) gin.HandlerFunc {
 
 			c.JSON(http.StatusOK, response)
 
+		} else if sampling.aggregation.Enabled() || sampling.downsample != database.DownsampleNearest {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "aggregate and downsample only apply to time_period or start_time/end_time ranges"})
+		} else if useCursor {
+			// Cursor-based mode
+			page, err := db.GetTempSensorDataPage(c.Request.Context(), cursor, limit, qualityFilter)
+			if err != nil {
+				abortDatabaseError(c, err, "Failed to get sensor data page")
+				return
+			}
+			data = page.Data
+
+			// Always apply aggregation (default ±3 for main display + configurable if requested)
+			if includeAggregates {
+				data, err = db.GetTempSensorDataWithAggregation(c.Request.Context(), data, aggregateWindowSize)
+			} else {
+				// Still calculate default aggregated values (±3) for main display only
+				data, err = db.GetTempSensorDataWithAggregation(c.Request.Context(), data, 0) // 0 means skip configurable aggregation
+			}
+			if err != nil {
+				abortDatabaseError(c, err, "Failed to calculate aggregated values")
+				return
+			}
+
+			response := gin.H{
+				"data":        historyResponseData(data, responseVersion),
+				"limit":       limit,
+				"pagination":  "cursor",
+				"next_cursor": encodeCursor(page.NextCursor),
+				"prev_cursor": encodeCursor(page.PrevCursor),
+			}
+
+			if responseVersion == 2 {
+				response["version"] = 2
+			}
+
+			// Add aggregation metadata if used
+			if includeAggregates {
+				response["aggregation"] = gin.H{
+					"enabled":     true,
+					"window_size": aggregateWindowSize,
+				}
+			}
+
+			c.JSON(http.StatusOK, response)
 		} else if term > 0 {
 			// Traditional term-based mode
-			data, err = db.GetTempSensorDataWithTerm(limit, term)
+			data, err = db.GetTempSensorDataWithTerm(c.Request.Context(), limit, term, qualityFilter)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sensor data with term"})
+				abortDatabaseError(c, err, "Failed to get sensor data with term")
 				return
 			}
 
 			// Always apply aggregation (default ±3 for main display + configurable if requested)
 			if includeAggregates {
-				data, err = db.GetTempSensorDataWithAggregation(data, aggregateWindowSize)
+				data, err = db.GetTempSensorDataWithAggregation(c.Request.Context(), data, aggregateWindowSize)
 			} else {
 				// Still calculate default aggregated values (±3) for main display only
-				data, err = db.GetTempSensorDataWithAggregation(data, 0) // 0 means skip configurable aggregation
+				data, err = db.GetTempSensorDataWithAggregation(c.Request.Context(), data, 0) // 0 means skip configurable aggregation
 			}
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate aggregated values"})
+				abortDatabaseError(c, err, "Failed to calculate aggregated values")
 				return
 			}
 
 			response := gin.H{
-				"data":   data,
+				"data":   historyResponseData(data, responseVersion),
 				"limit":  limit,
 				"offset": offset,
 				"term":   term,
 			}
 
+			if responseVersion == 2 {
+				response["version"] = 2
+			}
+
 			// Add aggregation metadata if used
 			if includeAggregates {
 				response["aggregation"] = gin.H{
@@ -240,31 +393,35 @@ func getTempSensorDataHistory(db *database.Database) gin.HandlerFunc {
 			c.JSON(http.StatusOK, response)
 		} else {
 			// Traditional offset-based mode
-			data, err = db.GetTempSensorData(limit, offset)
+			data, err = db.GetTempSensorData(c.Request.Context(), limit, offset, qualityFilter)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sensor data"})
+				abortDatabaseError(c, err, "Failed to get sensor data")
 				return
 			}
 
 			// Always apply aggregation (default ±3 for main display + configurable if requested)
 			if includeAggregates {
-				data, err = db.GetTempSensorDataWithAggregation(data, aggregateWindowSize)
+				data, err = db.GetTempSensorDataWithAggregation(c.Request.Context(), data, aggregateWindowSize)
 			} else {
 				// Still calculate default aggregated values (±3) for main display only
-				data, err = db.GetTempSensorDataWithAggregation(data, 0) // 0 means skip configurable aggregation
+				data, err = db.GetTempSensorDataWithAggregation(c.Request.Context(), data, 0) // 0 means skip configurable aggregation
 			}
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate aggregated values"})
+				abortDatabaseError(c, err, "Failed to calculate aggregated values")
 				return
 			}
 
 			response := gin.H{
-				"data":   data,
+				"data":   historyResponseData(data, responseVersion),
 				"limit":  limit,
 				"offset": offset,
 				"term":   term,
 			}
 
+			if responseVersion == 2 {
+				response["version"] = 2
+			}
+
 			// Add aggregation metadata if used
 			if includeAggregates {
 				response["aggregation"] = gin.H{
@@ -277,3 +434,20 @@ func getTempSensorDataHistory(db *database.Database) gin.HandlerFunc {
 		}
 	}
 }
+
+// historyResponseData returns v1 points (gaps as ID 0 with zero values)
+// or v2 points (gaps as nulls with gap: true)
+func historyResponseData(data []database.TempSensorData, version int) interface{} {
+	if version == 2 {
+		return database.ToNullable(data)
+	}
+	return data
+}
+
+// encodeCursor returns the opaque cursor string, or nil (JSON null) when there is no page
+func encodeCursor(cursor *database.PageCursor) interface{} {
+	if cursor == nil {
+		return nil
+	}
+	return cursor.Encode()
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"knet_management/database"
+)
+
+// include_aggregates covers the derived metrics of both sensors; AC outlet
+// readings without values are left out of its series
+func TestHistoryAggregatesDerivedMetricsOfBothSensors(t *testing.T) {
+	db, _ := contractDatabase(time.Now())
+	router, err := SetupRoutes(db, nil, Config{
+		Auth: AuthConfig{AnonymousRole: database.RoleViewer},
+		CORS: CORSConfig{AllowOrigins: []string{"*"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/api/temp/history", "/api/v2/temp/history"} {
+		t.Run(path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path+"?limit=3&include_aggregates=true", nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d: %s", path, recorder.Code, recorder.Body.String())
+			}
+
+			var response struct {
+				Data []struct {
+					Aggregated map[string]*database.MetricAggregates `json:"aggregated"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Data) == 0 || response.Data[0].Aggregated == nil {
+				t.Fatalf("no aggregated values in %s", recorder.Body.String())
+			}
+
+			aggregated := response.Data[0].Aggregated
+			for metric, wantCount := range map[string]int{
+				"dew_point": 2, "absolute_humidity": 2, "heat_index": 2, "humidex": 2,
+				"ac_outlet_dew_point": 1, "ac_outlet_absolute_humidity": 1, "ac_outlet_heat_index": 1, "ac_outlet_humidex": 1,
+			} {
+				if aggregated[metric] == nil || aggregated[metric].Count != wantCount {
+					t.Errorf("aggregated[%q] = %+v, want a count of %d", metric, aggregated[metric], wantCount)
+				}
+			}
+		})
+	}
+}
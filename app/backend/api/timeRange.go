@@ -0,0 +1,295 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"knet_management/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Calendar periods accepted by time_period. They are resolved in the requested
+// timezone; "this" periods end now, "last" periods and yesterday are complete.
+var calendarPeriods = []string{"today", "yesterday", "this_week", "last_week", "this_month", "last_month", "this_year", "last_year"}
+
+var timePeriodHelp = "Invalid time_period. Use a duration such as 6h, 90d or now-2w (units: s, min, h, d, w, m, y) or a calendar period: " +
+	strings.Join(calendarPeriods, ", ")
+
+const timeValueHelp = "Use RFC3339 (ISO 8601), a date (2006-01-02), now or now-<duration>"
+
+// timeRange is a resolved time_period or start_time/end_time
+type timeRange struct {
+	start    time.Time
+	end      time.Time
+	location *time.Location
+	period   string // Normalized time_period, "" for start_time/end_time
+	calendar bool   // Calendar period (today, last_month, ...)
+	timezone bool   // timezone was requested
+}
+
+// aligned reports whether sampling uses buckets aligned to local midnight:
+// for calendar periods and whenever a timezone was requested
+func (r timeRange) aligned() bool {
+	return r.calendar || r.timezone
+}
+
+// rollupLocation is the location export rollups align to, nil for UTC truncation
+func (r timeRange) rollupLocation() *time.Location {
+	if !r.aligned() {
+		return nil
+	}
+	return r.location
+}
+
+// resolveTimeRange resolves time_period, or start_time and end_time when
+// period is empty, in timezone (an IANA name, UTC when empty). Relative days,
+// weeks, months and years count calendar days in that timezone, so "1d" across
+// a daylight saving change ends at the same wall clock time it started.
+func resolveTimeRange(period, startValue, endValue, timezone string, now time.Time) (timeRange, []invalidParam) {
+	r := timeRange{location: time.UTC, timezone: timezone != ""}
+	var problems []invalidParam
+	if r.timezone {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return r, []invalidParam{{Name: "timezone", Reason: "Invalid timezone. Use an IANA name, e.g. Asia/Seoul"}}
+		}
+		r.location = location
+	}
+	now = now.In(r.location)
+
+	if period != "" {
+		r.period = strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(period))
+		if start, end, ok := calendarPeriod(r.period, now); ok {
+			r.start, r.end, r.calendar = start, end, true
+			return r, nil
+		}
+
+		r.period = strings.TrimSpace(strings.ToLower(period))
+		offset, err := parseRelativeOffset(strings.TrimPrefix(r.period, "now-"))
+		if err != nil {
+			return r, []invalidParam{{Name: "time_period", Reason: timePeriodHelp}}
+		}
+		r.start, r.end = offset.before(now), now
+		return r, nil
+	}
+
+	var err error
+	if r.start, err = parseTimeValue(startValue, r, now); err != nil {
+		problems = append(problems, invalidParam{Name: "start_time", Reason: "Invalid start_time format. " + timeValueHelp})
+	}
+	endTime, endErr := parseTimeValue(endValue, r, now)
+	if endErr != nil {
+		problems = append(problems, invalidParam{Name: "end_time", Reason: "Invalid end_time format. " + timeValueHelp})
+	}
+	r.end = endTime
+	if err == nil && endErr == nil && r.start.After(r.end) {
+		problems = append(problems, invalidParam{Name: "start_time", Reason: "start_time cannot be after end_time"})
+	}
+	return r, problems
+}
+
+// calendarPeriod resolves a calendar period relative to now, in now's location.
+// Weeks start on Monday.
+func calendarPeriod(period string, now time.Time) (time.Time, time.Time, bool) {
+	year, month, day := now.Date()
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	}
+	monday := day - (int(now.Weekday())+6)%7
+
+	switch period {
+	case "today":
+		return date(year, month, day), now, true
+	case "yesterday":
+		return date(year, month, day-1), date(year, month, day), true
+	case "this_week":
+		return date(year, month, monday), now, true
+	case "last_week":
+		return date(year, month, monday-7), date(year, month, monday), true
+	case "this_month":
+		return date(year, month, 1), now, true
+	case "last_month":
+		return date(year, month-1, 1), date(year, month, 1), true
+	case "this_year":
+		return date(year, 1, 1), now, true
+	case "last_year":
+		return date(year-1, 1, 1), date(year, 1, 1), true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// parseTimeValue parses start_time or end_time: RFC3339, a date (local
+// midnight), now or now-<duration>. RFC3339 values keep their offset unless a
+// timezone was requested.
+func parseTimeValue(value string, r timeRange, now time.Time) (time.Time, error) {
+	switch {
+	case value == "now":
+		return now, nil
+	case strings.HasPrefix(value, "now-"):
+		offset, err := parseRelativeOffset(strings.TrimPrefix(value, "now-"))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return offset.before(now), nil
+	case len(value) == len(time.DateOnly):
+		return time.ParseInLocation(time.DateOnly, value, r.location)
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if r.timezone {
+		t = t.In(r.location)
+	}
+	return t, nil
+}
+
+// relativeOffset is a duration such as "90d" or "1w12h". Calendar units are
+// kept apart from the clock units so they can follow the calendar.
+type relativeOffset struct {
+	years, months, days int
+	clock               time.Duration
+}
+
+func (o relativeOffset) before(t time.Time) time.Time {
+	return t.AddDate(-o.years, -o.months, -o.days).Add(-o.clock)
+}
+
+// parseRelativeOffset parses one or more <number><unit> parts. Units are s,
+// min, h, d, w, m (months, also mo) and y. "m" stays months, as in the
+// original 1m time_period.
+func parseRelativeOffset(value string) (relativeOffset, error) {
+	var offset relativeOffset
+	if value == "" {
+		return offset, errors.New("empty duration")
+	}
+
+	for value != "" {
+		digits := len(value) - len(strings.TrimLeft(value, "0123456789"))
+		units := len(value[digits:]) - len(strings.TrimLeft(value[digits:], "abcdefghijklmnopqrstuvwxyz"))
+		if digits == 0 || units == 0 {
+			return offset, errors.New("expected <number><unit>")
+		}
+		n, err := strconv.Atoi(value[:digits])
+		if err != nil || n > 100000 {
+			return offset, errors.New("duration out of range")
+		}
+
+		switch value[digits : digits+units] {
+		case "s":
+			offset.clock += time.Duration(n) * time.Second
+		case "min":
+			offset.clock += time.Duration(n) * time.Minute
+		case "h":
+			offset.clock += time.Duration(n) * time.Hour
+		case "d":
+			offset.days += n
+		case "w":
+			offset.days += 7 * n
+		case "m", "mo":
+			offset.months += n
+		case "y":
+			offset.years += n
+		default:
+			return offset, errors.New("unknown unit " + value[digits:digits+units])
+		}
+		value = value[digits+units:]
+	}
+
+	if offset == (relativeOffset{}) {
+		return offset, errors.New("duration must be positive")
+	}
+	return offset, nil
+}
+
+// historySampling selects how a time range is reduced to points
+type historySampling struct {
+	limit       int
+	filter      database.QualityFilter
+	aggregation database.BucketAggregation
+	downsample  string // database.Downsample*
+	metric      string // Metric lttb and minmax select points by
+}
+
+// parseHistorySampling parses the downsample, downsample_metric, aggregate and
+// aggregate_metrics parameters. Combinations that only apply to time ranges
+// are left to the caller.
+func parseHistorySampling(c *gin.Context) (historySampling, []invalidParam) {
+	var s historySampling
+	var problems []invalidParam
+	var err error
+
+	if s.aggregation.Functions, err = database.ParseAggregateFunctions(c.Query("aggregate")); err != nil {
+		problems = append(problems, invalidParam{Name: "aggregate", Reason: aggregateHelp})
+	}
+	if s.aggregation.Metrics, err = database.ParseAggregateMetrics(c.Query("aggregate_metrics")); err != nil {
+		problems = append(problems, invalidParam{Name: "aggregate_metrics", Reason: aggregateMetricsHelp})
+	}
+	if s.downsample, err = database.ParseDownsample(c.Query("downsample")); err != nil {
+		s.downsample = database.DownsampleNearest
+		problems = append(problems, invalidParam{Name: "downsample", Reason: "Invalid downsample. Use: nearest, lttb, minmax"})
+	}
+	if s.metric, err = database.ParseDownsampleMetric(c.Query("downsample_metric")); err != nil {
+		problems = append(problems, invalidParam{Name: "downsample_metric", Reason: strings.Replace(aggregateMetricsHelp, "aggregate_metrics", "downsample_metric", 1)})
+	}
+	if s.downsample == database.DownsampleLTTB && s.aggregation.Enabled() {
+		problems = append(problems, invalidParam{Name: "aggregate", Reason: "aggregate needs time buckets; use downsample=nearest or minmax"})
+	}
+	return s, problems
+}
+
+// sampleTimeRange reduces r to at most s.limit points and returns the bucket
+// size. Aligned ranges use calendar buckets, other bucketed modes even ones.
+// minmax returns up to two points per bucket, so it uses half as many.
+// Plain nearest-point sampling keeps its original slots.
+func sampleTimeRange(ctx context.Context, db *database.Database, r timeRange, s historySampling) ([]database.TempSensorData, string, error) {
+	if s.downsample == database.DownsampleLTTB {
+		data, err := db.GetTempSensorDataLTTB(ctx, r.start, r.end, s.limit, s.filter, s.metric)
+		return data, "", err
+	}
+	if s.downsample == database.DownsampleNearest && !r.aligned() && !s.aggregation.Enabled() {
+		data, err := db.GetTempSensorDataWithTimeIntervals(ctx, r.start, r.end, s.limit, s.filter)
+		return data, "", err
+	}
+
+	count := s.limit
+	if s.downsample == database.DownsampleMinMax && count > 1 {
+		count /= 2
+	}
+	var buckets []database.Bucket
+	var size string
+	if r.aligned() {
+		buckets, size = database.AlignedBuckets(r.start, r.end, r.location, count)
+	} else {
+		buckets = database.EvenBuckets(r.start, r.end, count)
+		size = (r.end.Sub(r.start) / time.Duration(count)).Round(time.Second).String()
+	}
+
+	if s.downsample == database.DownsampleMinMax {
+		data, err := db.GetTempSensorDataMinMax(ctx, buckets, s.filter, s.metric, s.aggregation)
+		return data, size, err
+	}
+	data, err := db.GetTempSensorDataInBuckets(ctx, buckets, s.filter, s.aggregation)
+	return data, size, err
+}
+
+// samplingMeta describes non-default sampling in history responses
+func samplingMeta(s historySampling) (downsample gin.H, aggregation gin.H) {
+	if s.downsample != database.DownsampleNearest {
+		downsample = gin.H{"mode": s.downsample, "metric": s.metric}
+	}
+	if s.aggregation.Enabled() {
+		aggregation = gin.H{"functions": s.aggregation.Functions, "metrics": s.aggregation.Metrics}
+	}
+	return downsample, aggregation
+}
+
+const (
+	aggregateHelp        = "Invalid aggregate. Use: mean, min, max, median, p1-p99 (e.g. p5, p95), stddev, first, last, count, twa"
+	aggregateMetricsHelp = "Invalid aggregate_metrics. Use: temperature, humidity, dew_point, absolute_humidity, heat_index, humidex, optionally prefixed with ac_outlet_"
+)
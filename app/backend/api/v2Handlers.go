@@ -0,0 +1,304 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"knet_management/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// envelope is the body of every successful /api/v2 JSON response. meta and
+// pagination are always present; pagination is null for single resources.
+type envelope struct {
+	Data       interface{} `json:"data"`
+	Meta       gin.H       `json:"meta"`
+	Pagination *pagination `json:"pagination"`
+}
+
+// pagination describes a list. Fields that do not apply to the list's mode
+// are null rather than made up.
+type pagination struct {
+	Limit         *int    `json:"limit"`
+	Offset        *int    `json:"offset"`
+	ReturnedCount int     `json:"returned_count"`
+	TotalCount    *int    `json:"total_count"`
+	NextCursor    *string `json:"next_cursor"`
+	PrevCursor    *string `json:"prev_cursor"`
+}
+
+// completeList is the pagination of a list that is always returned whole
+func completeList(count int) *pagination {
+	return &pagination{ReturnedCount: count, TotalCount: &count}
+}
+
+// respond writes v1 on /api routes and v2 on /api/v2 routes, for handlers
+// shared by both versions
+func respond(c *gin.Context, status int, v1 interface{}, v2 envelope) {
+	if !isAPIv2(c) {
+		c.JSON(status, v1)
+		return
+	}
+	if v2.Meta == nil {
+		v2.Meta = gin.H{}
+	}
+	c.JSON(status, v2)
+}
+
+// History modes, chosen by the parameters given
+const (
+	historyModeTimeRange = "time_range" // time_period or start_time/end_time: limit points sampled over the range
+	historyModeCursor    = "cursor"     // pagination=cursor or cursor: keyset pages, newest first
+	historyModeTerm      = "term"       // term: every term-th reading, newest first
+	historyModeOffset    = "offset"     // Default: newest readings after offset
+)
+
+type historyQuery struct {
+	mode              string
+	limit             int
+	offset            int
+	term              int
+	cursor            *database.PageCursor
+	timeRange         timeRange
+	fill              database.FillMode
+	filter            database.QualityFilter
+	includeAggregates bool
+	aggregateWindow   int
+	sampling          historySampling
+}
+
+// parseHistoryQuery validates every parameter and reports all problems at
+// once. Unlike v1 nothing is silently clamped or ignored.
+func parseHistoryQuery(c *gin.Context, now time.Time) (historyQuery, []invalidParam) {
+	q := historyQuery{limit: 150, aggregateWindow: 100}
+	var problems []invalidParam
+	reject := func(name, reason string) {
+		problems = append(problems, invalidParam{Name: name, Reason: reason})
+	}
+	intParam := func(name string, target *int, min, max int) {
+		value, ok := c.GetQuery(name)
+		if !ok {
+			return
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < min || parsed > max {
+			reject(name, name+" must be an integer from "+strconv.Itoa(min)+" to "+strconv.Itoa(max))
+			return
+		}
+		*target = parsed
+	}
+
+	intParam("limit", &q.limit, 1, 1000)
+	intParam("offset", &q.offset, 0, 1<<31-1)
+	intParam("term", &q.term, 0, 1<<31-1)
+	intParam("aggregate_window", &q.aggregateWindow, 1, 500)
+
+	switch value := c.Query("include_aggregates"); value {
+	case "", "false":
+	case "true":
+		q.includeAggregates = true
+	default:
+		reject("include_aggregates", "include_aggregates must be true or false")
+	}
+
+	var err error
+	if q.filter, err = database.ParseQualityFilter(c.Query("exclude_flags")); err != nil {
+		reject("exclude_flags", "Invalid exclude_flags. Use: outlier, interpolated, late, spooled, manual_edit or any")
+	}
+	fill, fillErr := database.ParseFillMode(c.Query("fill"))
+	if fillErr != nil {
+		reject("fill", "Invalid fill. Use: null, previous, linear")
+	}
+	q.fill = fill
+
+	var samplingProblems []invalidParam
+	q.sampling, samplingProblems = parseHistorySampling(c)
+	problems = append(problems, samplingProblems...)
+	if _, ok := c.GetQuery("aggregate_metrics"); ok && c.Query("aggregate") == "" {
+		reject("aggregate_metrics", "aggregate_metrics requires aggregate")
+	}
+	if _, ok := c.GetQuery("downsample_metric"); ok && (c.Query("downsample") == "" || c.Query("downsample") == database.DownsampleNearest) {
+		reject("downsample_metric", "downsample_metric requires downsample=lttb or minmax")
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		if q.cursor, err = database.DecodeCursor(value); err != nil {
+			reject("cursor", "Invalid cursor")
+		}
+	}
+	switch value := c.Query("pagination"); value {
+	case "", historyModeOffset, historyModeCursor:
+	default:
+		reject("pagination", "Invalid pagination. Use: offset, cursor")
+	}
+
+	period := c.Query("time_period")
+	startValue, hasStart := c.GetQuery("start_time")
+	endValue, hasEnd := c.GetQuery("end_time")
+	_, hasTimezone := c.GetQuery("timezone")
+
+	switch {
+	case period != "" && (hasStart || hasEnd):
+		reject("time_period", "Use either time_period or start_time/end_time, not both")
+	case hasStart != hasEnd:
+		if !hasEnd {
+			reject("end_time", "end_time is required with start_time")
+		} else {
+			reject("start_time", "start_time is required with end_time")
+		}
+	case period != "" || hasStart:
+		q.mode = historyModeTimeRange
+		var rangeProblems []invalidParam
+		q.timeRange, rangeProblems = resolveTimeRange(period, startValue, endValue, c.Query("timezone"), now)
+		problems = append(problems, rangeProblems...)
+	case hasTimezone:
+		reject("timezone", "timezone only applies to time_period or start_time/end_time ranges")
+	}
+
+	if q.mode == "" {
+		switch {
+		case c.Query("cursor") != "" || c.Query("pagination") == historyModeCursor:
+			q.mode = historyModeCursor
+		case q.term > 0:
+			q.mode = historyModeTerm
+		default:
+			q.mode = historyModeOffset
+		}
+	}
+
+	// Parameters of another mode would be ignored; say so instead
+	conflicts := map[string][]string{
+		historyModeTimeRange: {"offset", "term", "cursor", "pagination"},
+		historyModeCursor:    {"offset", "term"},
+		historyModeTerm:      {"offset", "cursor"},
+		historyModeOffset:    {},
+	}
+	for _, name := range conflicts[q.mode] {
+		if _, ok := c.GetQuery(name); ok {
+			reject(name, name+" cannot be used in "+q.mode+" mode")
+		}
+	}
+	if q.mode != historyModeTimeRange {
+		if _, ok := c.GetQuery("fill"); ok && fillErr == nil {
+			reject("fill", "fill only applies to time_period or start_time/end_time ranges")
+		}
+		if q.sampling.aggregation.Enabled() {
+			reject("aggregate", "aggregate only applies to time_period or start_time/end_time ranges")
+		}
+		if q.sampling.downsample != database.DownsampleNearest {
+			reject("downsample", "downsample only applies to time_period or start_time/end_time ranges")
+		}
+	}
+
+	q.sampling.limit, q.sampling.filter = q.limit, q.filter
+	return q, problems
+}
+
+// getTempSensorDataHistoryV2 serves the same readings as v1 history in one
+// envelope for every mode. Points always use nullable values with gap markers.
+func getTempSensorDataHistoryV2(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, problems := parseHistoryQuery(c, time.Now())
+		if len(problems) > 0 {
+			abortInvalidParams(c, problems)
+			return
+		}
+
+		ctx := c.Request.Context()
+		page := &pagination{Limit: &q.limit}
+		meta := gin.H{"mode": q.mode}
+
+		var data []database.TempSensorData
+		var err error
+		switch q.mode {
+		case historyModeTimeRange:
+			var bucketSize string
+			data, bucketSize, err = sampleTimeRange(ctx, db, q.timeRange, q.sampling)
+			if err != nil {
+				abortDatabaseError(c, err, "Failed to get sensor data with time range")
+				return
+			}
+			data = database.FillGaps(data, q.fill)
+
+			if totalCount, countErr := db.GetDataCountInTimeRange(ctx, q.timeRange.start, q.timeRange.end, q.filter); countErr == nil {
+				page.TotalCount = &totalCount
+			}
+
+			meta["time_period"] = nullableString(q.timeRange.period)
+			meta["start_time"] = q.timeRange.start.Format(time.RFC3339)
+			meta["end_time"] = q.timeRange.end.Format(time.RFC3339)
+			meta["timezone"] = q.timeRange.location.String()
+			meta["bucket_size"] = nullableString(bucketSize)
+			meta["downsample"], meta["bucket_aggregation"] = samplingMeta(q.sampling)
+			meta["fill"] = q.fill
+
+		case historyModeCursor:
+			var result *database.Page
+			result, err = db.GetTempSensorDataPage(ctx, q.cursor, q.limit, q.filter)
+			if err != nil {
+				abortDatabaseError(c, err, "Failed to get sensor data page")
+				return
+			}
+			data = result.Data
+			if result.NextCursor != nil {
+				next := result.NextCursor.Encode()
+				page.NextCursor = &next
+			}
+			if result.PrevCursor != nil {
+				prev := result.PrevCursor.Encode()
+				page.PrevCursor = &prev
+			}
+
+		case historyModeTerm:
+			data, err = db.GetTempSensorDataWithTerm(ctx, q.limit, q.term, q.filter)
+			if err != nil {
+				abortDatabaseError(c, err, "Failed to get sensor data with term")
+				return
+			}
+			meta["term"] = q.term
+
+		default:
+			data, err = db.GetTempSensorData(ctx, q.limit, q.offset, q.filter)
+			if err != nil {
+				abortDatabaseError(c, err, "Failed to get sensor data")
+				return
+			}
+			page.Offset = &q.offset
+		}
+
+		// The ±3 default aggregate is always added; the configurable window only on request
+		window := 0
+		if q.includeAggregates {
+			window = q.aggregateWindow
+		}
+		data, err = db.GetTempSensorDataWithAggregation(ctx, data, window)
+		if err != nil {
+			abortDatabaseError(c, err, "Failed to calculate aggregated values")
+			return
+		}
+
+		aggregation := gin.H{"enabled": q.includeAggregates, "window_size": nil}
+		if q.includeAggregates {
+			aggregation["window_size"] = q.aggregateWindow
+		}
+		meta["aggregation"] = aggregation
+		excludeFlags := append([]string{}, q.filter.ExcludeFlags...)
+		if q.filter.ExcludeAll {
+			excludeFlags = []string{"any"}
+		}
+		meta["exclude_flags"] = excludeFlags
+
+		page.ReturnedCount = len(data)
+		c.JSON(http.StatusOK, envelope{Data: database.ToNullable(data), Meta: meta, Pagination: page})
+	}
+}
+
+// nullableString is nil for "", so meta reports absent values as null
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"knet_management/database"
+)
+
+// runAPIKeys manages API keys, e.g. to create the first admin key
+//
+//	main apikeys create -name ops -role admin [-expires 2027-01-01T00:00:00Z]
+//	main apikeys list
+//	main apikeys rotate <id>
+//	main apikeys revoke <id>
+func runAPIKeys(args []string) {
+	if len(args) == 0 {
+		fatal("Usage: main apikeys create|list|rotate <id>|revoke <id>")
+	}
+
+	action, args := args[0], args[1:]
+
+	switch action {
+	case "create":
+		fs := flag.NewFlagSet("apikeys create", flag.ExitOnError)
+		name := fs.String("name", "", "Key name (required)")
+		role := fs.String("role", "", "Role: viewer, operator, admin, ingest-device (required)")
+		expires := fs.String("expires", "", "Expiry time (RFC3339), never expires if empty")
+		fs.Parse(args)
+
+		if *name == "" || !database.IsValidRole(*role) {
+			fatal("Usage: main apikeys create -name <name> -role viewer|operator|admin|ingest-device")
+		}
+
+		var expiresAt *time.Time
+		if *expires != "" {
+			t, err := time.Parse(time.RFC3339, *expires)
+			if err != nil {
+				fatal("Invalid -expires, use RFC3339", "error", err)
+			}
+			expiresAt = &t
+		}
+
+		db := connectDatabase(loadConfig().Database)
+		defer db.Close()
+
+		key, plaintext, err := db.CreateAPIKey(context.Background(), *name, *role, expiresAt)
+		if err != nil {
+			fatal("Failed to create API key", "error", err)
+		}
+		printNewAPIKey(key, plaintext)
+	case "list":
+		db := connectDatabase(loadConfig().Database)
+		defer db.Close()
+
+		keys, err := db.GetAPIKeys(context.Background())
+		if err != nil {
+			fatal("Failed to get API keys", "error", err)
+		}
+
+		formatTime := func(t *time.Time) string {
+			if t == nil {
+				return "-"
+			}
+			return t.Format(time.RFC3339)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tROLE\tPREFIX\tCREATED\tEXPIRES\tLAST USED\tREVOKED")
+		for _, key := range keys {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Role, key.KeyPrefix,
+				key.CreatedAt.Format(time.RFC3339), formatTime(key.ExpiresAt), formatTime(key.LastUsedAt), formatTime(key.RevokedAt))
+		}
+		w.Flush()
+	case "rotate", "revoke":
+		if len(args) != 1 {
+			fatal("Usage: main apikeys " + action + " <id>")
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			fatal("Invalid API key id", "id", args[0])
+		}
+
+		db := connectDatabase(loadConfig().Database)
+		defer db.Close()
+
+		if action == "rotate" {
+			key, plaintext, err := db.RotateAPIKey(context.Background(), id)
+			if err == sql.ErrNoRows {
+				fatal("No active API key with this id", "id", id)
+			}
+			if err != nil {
+				fatal("Failed to rotate API key", "id", id, "error", err)
+			}
+			printNewAPIKey(key, plaintext)
+			return
+		}
+
+		key, err := db.RevokeAPIKey(context.Background(), id)
+		if err == sql.ErrNoRows {
+			fatal("No active API key with this id", "id", id)
+		}
+		if err != nil {
+			fatal("Failed to revoke API key", "id", id, "error", err)
+		}
+		fmt.Printf("Revoked API key %d (%s, %s)\n", key.ID, key.Name, key.Role)
+	default:
+		fatal("Unknown apikeys action. Use: create, list, rotate, revoke", "action", action)
+	}
+}
+
+func printNewAPIKey(key *database.APIKey, plaintext string) {
+	fmt.Printf("Created API key %d (%s, %s)\n", key.ID, key.Name, key.Role)
+	fmt.Printf("Key (shown only once): %s\n", plaintext)
+}
@@ -0,0 +1,217 @@
+// Package auth validates OIDC-issued JWT bearer tokens and maps their
+// claims to the API roles.
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"knet_management/database"
+)
+
+const (
+	defaultRoleClaim       = "roles"
+	defaultJWKSRefresh     = time.Hour
+	defaultJWKSMinRefresh  = time.Minute
+	discoveryHTTPTimeout   = 10 * time.Second
+	openIDConfigurationURL = "/.well-known/openid-configuration"
+)
+
+// Role precedence when a token maps to several roles; the highest wins
+var rolePrecedence = map[string]int{
+	database.RoleIngestDevice: 1,
+	database.RoleViewer:       2,
+	database.RoleOperator:     3,
+	database.RoleAdmin:        4,
+}
+
+// OIDCConfig configures bearer token validation
+type OIDCConfig struct {
+	IssuerURL string // Must equal the iss claim
+	Audience  string // Required aud value (usually the client id); empty skips the check
+	JWKSURL   string // Optional; discovered from the issuer when empty
+
+	// RoleClaim is the claim holding roles or groups (string or list), default "roles".
+	// RoleMapping maps claim values to API roles; values that already are
+	// role names map to themselves.
+	RoleClaim   string
+	RoleMapping map[string]string
+
+	JWKSRefresh time.Duration // Maximum age of cached keys, default 1h
+
+	// JWKSMinRefresh is the minimum time between two fetches, so tokens with
+	// unknown kids or an unreachable issuer cannot make every request hit the
+	// issuer. Default 1m; negative disables the limit.
+	JWKSMinRefresh time.Duration
+}
+
+// Identity is a verified token mapped to an API role
+type Identity struct {
+	Subject string
+	Role    string // Empty if no claim value maps to a role
+	Claims  Claims
+}
+
+// Verifier validates JWTs issued by one OIDC issuer
+type Verifier struct {
+	cfg    OIDCConfig
+	client *http.Client
+
+	mu               sync.Mutex
+	jwks             *jwksCache // Created on first use, after discovery
+	discoveryErr     error
+	discoveryAttempt time.Time
+}
+
+// NewVerifier creates a verifier. Discovery and key fetching happen lazily
+// on the first token, so an unreachable issuer does not block startup.
+func NewVerifier(cfg OIDCConfig) (*Verifier, error) {
+	if cfg.IssuerURL == "" {
+		return nil, fmt.Errorf("OIDC issuer URL is required")
+	}
+	if cfg.RoleClaim == "" {
+		cfg.RoleClaim = defaultRoleClaim
+	}
+	if cfg.JWKSRefresh <= 0 {
+		cfg.JWKSRefresh = defaultJWKSRefresh
+	}
+	if cfg.JWKSMinRefresh == 0 {
+		cfg.JWKSMinRefresh = defaultJWKSMinRefresh
+	}
+	for value, role := range cfg.RoleMapping {
+		if _, ok := rolePrecedence[role]; !ok {
+			return nil, fmt.Errorf("role mapping %s=%s: unknown role", value, role)
+		}
+	}
+
+	return &Verifier{
+		cfg:    cfg,
+		client: &http.Client{Timeout: discoveryHTTPTimeout},
+	}, nil
+}
+
+// ParseRoleMapping parses "sso-admins=admin,sso-ops=operator"
+func ParseRoleMapping(value string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if strings.TrimSpace(value) == "" {
+		return mapping, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid role mapping %q, expected claim_value=role", pair)
+		}
+		mapping[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return mapping, nil
+}
+
+// Verify checks the token signature and claims and maps it to a role
+func (v *Verifier) Verify(token string) (*Identity, error) {
+	header, claims, signingInput, signature, err := parseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := signingHash[header.Alg]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlg, header.Alg)
+	}
+
+	jwks, err := v.keySet()
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwks.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, signingInput, signature); err != nil {
+		return nil, err
+	}
+
+	if err := validateClaims(claims, v.cfg.IssuerURL, v.cfg.Audience, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return &Identity{
+		Subject: subject(claims),
+		Role:    v.mapRole(claims),
+		Claims:  claims,
+	}, nil
+}
+
+// subject prefers a human readable name for logs and audit
+func subject(claims Claims) string {
+	for _, name := range []string{"preferred_username", "email", "sub"} {
+		if value := claims.String(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// mapRole returns the highest role any claim value maps to
+func (v *Verifier) mapRole(claims Claims) string {
+	best := ""
+	for _, value := range claims.Strings(v.cfg.RoleClaim) {
+		role, ok := v.cfg.RoleMapping[value]
+		if !ok {
+			if _, isRole := rolePrecedence[value]; !isRole {
+				continue
+			}
+			role = value
+		}
+		if rolePrecedence[role] > rolePrecedence[best] {
+			best = role
+		}
+	}
+	return best
+}
+
+// keySet discovers the JWKS URL once and returns the key cache
+func (v *Verifier) keySet() (*jwksCache, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.jwks != nil {
+		return v.jwks, nil
+	}
+	if v.discoveryErr != nil && time.Since(v.discoveryAttempt) < v.cfg.JWKSMinRefresh {
+		return nil, v.discoveryErr
+	}
+
+	v.discoveryAttempt = time.Now()
+	jwksURL, err := v.discoverJWKSURL()
+	if err != nil {
+		v.discoveryErr = err
+		return nil, err
+	}
+
+	v.jwks = newJWKSCache(jwksURL, v.client, v.cfg.JWKSRefresh, v.cfg.JWKSMinRefresh)
+	return v.jwks, nil
+}
+
+// discoverJWKSURL reads jwks_uri from the issuer's discovery document
+func (v *Verifier) discoverJWKSURL() (string, error) {
+	if v.cfg.JWKSURL != "" {
+		return v.cfg.JWKSURL, nil
+	}
+
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	url := strings.TrimSuffix(v.cfg.IssuerURL, "/") + openIDConfigurationURL
+	if err := getJSON(v.client, url, &discovery); err != nil {
+		return "", fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	if discovery.Issuer != v.cfg.IssuerURL {
+		return "", fmt.Errorf("OIDC discovery returned issuer %q, expected %q", discovery.Issuer, v.cfg.IssuerURL)
+	}
+	if discovery.JWKSURI == "" {
+		return "", fmt.Errorf("OIDC discovery document has no jwks_uri")
+	}
+	return discovery.JWKSURI, nil
+}
@@ -0,0 +1,259 @@
+package auth_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"knet_management/auth"
+	"knet_management/auth/oidctest"
+	"knet_management/database"
+)
+
+func newIssuer(t *testing.T) *oidctest.Issuer {
+	t.Helper()
+	server, issuer, err := oidctest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	return issuer
+}
+
+func newVerifier(t *testing.T, cfg auth.OIDCConfig) *auth.Verifier {
+	t.Helper()
+	verifier, err := auth.NewVerifier(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return verifier
+}
+
+// countJWKS serves the issuer's JWKS and counts the fetches
+func countJWKS(t *testing.T, issuer *oidctest.Issuer) (string, *int32) {
+	t.Helper()
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		issuer.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/jwks", &fetches
+}
+
+func mustSign(t *testing.T, issuer *oidctest.Issuer, claims auth.Claims) string {
+	t.Helper()
+	token, err := issuer.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// withHeader replaces the header of a signed token, keeping its claims and
+// signature, and re-signs with sign when it is not nil
+func withHeader(t *testing.T, token string, header map[string]string, sign func(signingInput string) []byte) string {
+	t.Helper()
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	signingInput := base64.RawURLEncoding.EncodeToString(encoded) + "." + parts[1]
+	signature := parts[2]
+	if sign != nil {
+		signature = base64.RawURLEncoding.EncodeToString(sign(signingInput))
+	}
+	return signingInput + "." + signature
+}
+
+func kidOf(t *testing.T, token string) string {
+	t.Helper()
+	raw, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header struct {
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		t.Fatal(err)
+	}
+	return header.Kid
+}
+
+func TestVerify(t *testing.T) {
+	issuer := newIssuer(t)
+	verifier := newVerifier(t, auth.OIDCConfig{IssuerURL: issuer.URL(), Audience: "knet"})
+
+	now := time.Now()
+	claims := func(overrides auth.Claims) auth.Claims {
+		c := auth.Claims{"iss": issuer.URL(), "sub": "alice", "aud": "knet", "exp": now.Add(time.Hour).Unix(), "roles": []string{database.RoleViewer}}
+		for name, value := range overrides {
+			if value == nil {
+				delete(c, name)
+				continue
+			}
+			c[name] = value
+		}
+		return c
+	}
+
+	valid := mustSign(t, issuer, claims(nil))
+	kid := kidOf(t, valid)
+
+	// Key confusion: HMAC with the published JWKS as the secret
+	response, err := http.Get(issuer.URL() + "/jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs256 := withHeader(t, valid, map[string]string{"alg": "HS256", "typ": "JWT", "kid": kid}, func(signingInput string) []byte {
+		mac := hmac.New(sha256.New, jwks)
+		mac.Write([]byte(signingInput))
+		return mac.Sum(nil)
+	})
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "valid", token: valid},
+		{name: "audience in a list", token: mustSign(t, issuer, claims(auth.Claims{"aud": []string{"other", "knet"}}))},
+		{name: "expired", token: mustSign(t, issuer, claims(auth.Claims{"exp": now.Add(-2 * time.Minute).Unix()})), wantErr: auth.ErrTokenExpired},
+		{name: "expired within clock skew", token: mustSign(t, issuer, claims(auth.Claims{"exp": now.Add(-30 * time.Second).Unix()}))},
+		{name: "missing exp", token: mustSign(t, issuer, claims(auth.Claims{"exp": nil})), wantErr: auth.ErrTokenExpired},
+		{name: "not yet valid", token: mustSign(t, issuer, claims(auth.Claims{"nbf": now.Add(time.Hour).Unix()})), wantErr: auth.ErrTokenNotYetValid},
+		{name: "wrong issuer", token: mustSign(t, issuer, claims(auth.Claims{"iss": "https://sso.example.com"})), wantErr: auth.ErrInvalidIssuer},
+		{name: "wrong audience", token: mustSign(t, issuer, claims(auth.Claims{"aud": "other"})), wantErr: auth.ErrInvalidAudience},
+		{name: "missing audience", token: mustSign(t, issuer, claims(auth.Claims{"aud": nil})), wantErr: auth.ErrInvalidAudience},
+		{name: "alg none", token: withHeader(t, valid, map[string]string{"alg": "none", "kid": kid}, func(string) []byte { return nil }), wantErr: auth.ErrUnsupportedAlg},
+		{name: "HS256", token: hs256, wantErr: auth.ErrUnsupportedAlg},
+		{name: "ES256 with an RSA key", token: withHeader(t, valid, map[string]string{"alg": "ES256", "kid": kid}, nil), wantErr: auth.ErrUnsupportedAlg},
+		{name: "RS384 signature over RS256", token: withHeader(t, valid, map[string]string{"alg": "RS384", "kid": kid}, nil), wantErr: auth.ErrInvalidSignature},
+		{name: "tampered claims", token: strings.Join([]string{strings.Split(valid, ".")[0], strings.Split(mustSign(t, issuer, claims(auth.Claims{"sub": "mallory"})), ".")[1], strings.Split(valid, ".")[2]}, "."), wantErr: auth.ErrInvalidSignature},
+		{name: "malformed", token: "not.a-token", wantErr: auth.ErrMalformedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := verifier.Verify(tt.token)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if identity.Subject != "alice" || identity.Role != database.RoleViewer {
+				t.Errorf("identity = %s/%s, want alice/viewer", identity.Subject, identity.Role)
+			}
+		})
+	}
+}
+
+// A token with an unknown kid refreshes the JWKS once, and not again before
+// JWKSMinRefresh has passed
+func TestVerifyRefreshesJWKSForUnknownKid(t *testing.T) {
+	issuer := newIssuer(t)
+	jwksURL, fetches := countJWKS(t, issuer)
+	const minRefresh = 200 * time.Millisecond
+	verifier := newVerifier(t, auth.OIDCConfig{IssuerURL: issuer.URL(), JWKSURL: jwksURL, JWKSMinRefresh: minRefresh})
+
+	token := func() string {
+		token, err := issuer.Token("alice", "", []string{database.RoleViewer}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	verify := func(token string, wantFetches int32) error {
+		t.Helper()
+		_, err := verifier.Verify(token)
+		if got := atomic.LoadInt32(fetches); got != wantFetches {
+			t.Fatalf("JWKS fetched %d times, want %d", got, wantFetches)
+		}
+		return err
+	}
+
+	if err := verify(token(), 1); err != nil {
+		t.Fatalf("first token: %v", err)
+	}
+
+	// Rotated right after the first fetch: the new kid waits for the limit
+	if err := issuer.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	rotated := token()
+	if err := verify(rotated, 1); !errors.Is(err, auth.ErrUnknownKey) {
+		t.Fatalf("rotated key within JWKSMinRefresh: error = %v, want %v", err, auth.ErrUnknownKey)
+	}
+
+	time.Sleep(minRefresh)
+	if err := verify(rotated, 2); err != nil {
+		t.Fatalf("rotated key after JWKSMinRefresh: %v", err)
+	}
+
+	// A kid the issuer never published cannot force another fetch
+	unknown := withHeader(t, rotated, map[string]string{"alg": "RS256", "kid": "attacker"}, nil)
+	for i := 0; i < 3; i++ {
+		if err := verify(unknown, 2); !errors.Is(err, auth.ErrUnknownKey) {
+			t.Fatalf("unknown kid: error = %v, want %v", err, auth.ErrUnknownKey)
+		}
+	}
+}
+
+func TestVerifyRolePrecedence(t *testing.T) {
+	issuer := newIssuer(t)
+
+	tests := []struct {
+		name    string
+		claim   string // RoleClaim; "roles" when empty
+		mapping map[string]string
+		claims  auth.Claims
+		want    string
+	}{
+		{name: "highest role wins", claims: auth.Claims{"roles": []string{database.RoleViewer, database.RoleAdmin, database.RoleOperator}}, want: database.RoleAdmin},
+		{name: "viewer over ingest device", claims: auth.Claims{"roles": []string{database.RoleIngestDevice, database.RoleViewer}}, want: database.RoleViewer},
+		{name: "single string", claims: auth.Claims{"roles": database.RoleOperator}, want: database.RoleOperator},
+		{name: "mapped group", mapping: map[string]string{"sso-ops": database.RoleOperator}, claims: auth.Claims{"roles": []string{"sso-ops", database.RoleViewer}}, want: database.RoleOperator},
+		{name: "mapping before role names", mapping: map[string]string{database.RoleAdmin: database.RoleViewer}, claims: auth.Claims{"roles": []string{database.RoleAdmin}}, want: database.RoleViewer},
+		{name: "unknown values", claims: auth.Claims{"roles": []string{"sso-users", "root"}}, want: ""},
+		{name: "other claim", claim: "groups", mapping: map[string]string{"sso-admins": database.RoleAdmin}, claims: auth.Claims{"groups": []string{"sso-admins"}}, want: database.RoleAdmin},
+		{name: "roles ignored with another claim", claim: "groups", claims: auth.Claims{"roles": []string{database.RoleAdmin}}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := newVerifier(t, auth.OIDCConfig{IssuerURL: issuer.URL(), RoleClaim: tt.claim, RoleMapping: tt.mapping})
+			claims := auth.Claims{"iss": issuer.URL(), "sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+			for name, value := range tt.claims {
+				claims[name] = value
+			}
+
+			identity, err := verifier.Verify(mustSign(t, issuer, claims))
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if identity.Role != tt.want {
+				t.Errorf("role = %q, want %q", identity.Role, tt.want)
+			}
+		})
+	}
+}
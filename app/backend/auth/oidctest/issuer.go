@@ -0,0 +1,193 @@
+// Package oidctest is a local stand-in OIDC issuer for trying out and
+// checking bearer token authentication without a real SSO. It serves the
+// discovery document and JWKS and signs RS256 tokens. Never use it in production.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"knet_management/auth"
+)
+
+// Issuer signs tokens and serves /.well-known/openid-configuration and /jwks
+type Issuer struct {
+	url string
+
+	mu      sync.Mutex
+	keys    []*signingKey // Newest first; all are published in the JWKS
+	counter int
+}
+
+type signingKey struct {
+	kid string
+	key *rsa.PrivateKey
+}
+
+// NewIssuer creates an issuer whose iss claim and endpoints use url
+func NewIssuer(url string) (*Issuer, error) {
+	issuer := &Issuer{url: strings.TrimSuffix(url, "/")}
+	if err := issuer.RotateKey(); err != nil {
+		return nil, err
+	}
+	return issuer, nil
+}
+
+// NewServer starts an issuer on a random local port. Close the server when done.
+func NewServer() (*httptest.Server, *Issuer, error) {
+	server := httptest.NewUnstartedServer(nil)
+	issuer, err := NewIssuer("http://" + server.Listener.Addr().String())
+	if err != nil {
+		server.Close()
+		return nil, nil, err
+	}
+	server.Config.Handler = issuer
+	server.Start()
+	return server, issuer, nil
+}
+
+// URL is the issuer URL, to be used as the OIDC issuer in the backend config
+func (i *Issuer) URL() string {
+	return i.url
+}
+
+// RotateKey adds a new signing key; later tokens use it while tokens signed
+// with older keys stay valid as long as those keys are published
+func (i *Issuer) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.counter++
+	i.keys = append([]*signingKey{{kid: fmt.Sprintf("test-key-%d", i.counter), key: key}}, i.keys...)
+	return nil
+}
+
+// DropOldKeys stops publishing every key but the newest
+func (i *Issuer) DropOldKeys() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.keys = i.keys[:1]
+}
+
+// Token signs a token for subject with the given roles claim, valid for ttl.
+// audience may be empty.
+func (i *Issuer) Token(subject, audience string, roles []string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := auth.Claims{
+		"iss":                i.url,
+		"sub":                subject,
+		"preferred_username": subject,
+		"iat":                now.Unix(),
+		"exp":                now.Add(ttl).Unix(),
+		"roles":              roles,
+	}
+	if audience != "" {
+		claims["aud"] = audience
+	}
+	return i.Sign(claims)
+}
+
+// Sign signs arbitrary claims with the newest key (RS256)
+func (i *Issuer) Sign(claims auth.Claims) (string, error) {
+	i.mu.Lock()
+	key := i.keys[0]
+	i.mu.Unlock()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": key.kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// ServeHTTP serves discovery, JWKS and, for manual testing, GET
+// /token?sub=alice&roles=admin,viewer&aud=knet&ttl=1h
+func (i *Issuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                i.url,
+			"jwks_uri":                              i.url + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	case "/jwks":
+		writeJSON(w, i.jwks())
+	case "/token":
+		query := r.URL.Query()
+		ttl := time.Hour
+		if value := query.Get("ttl"); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				http.Error(w, "invalid ttl", http.StatusBadRequest)
+				return
+			}
+			ttl = parsed
+		}
+		var roles []string
+		if value := query.Get("roles"); value != "" {
+			roles = strings.Split(value, ",")
+		}
+		subject := query.Get("sub")
+		if subject == "" {
+			subject = "dev"
+		}
+
+		token, err := i.Token(subject, query.Get("aud"), roles, ttl)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"access_token": token, "token_type": "Bearer", "expires_in": int(ttl.Seconds())})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (i *Issuer) jwks() auth.JWKS {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	set := auth.JWKS{Keys: []auth.JWK{}}
+	for _, k := range i.keys {
+		set.Keys = append(set.Keys, auth.JWK{
+			Kty: "RSA",
+			Kid: k.kid,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(k.key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.key.E)).Bytes()),
+		})
+	}
+	return set
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
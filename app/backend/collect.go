@@ -0,0 +1,27 @@
+package main
+
+import "knet_management/service"
+
+// runCollect collects a single reading from the sensors and exits
+//
+//	main collect once
+func runCollect(args []string) {
+	if len(args) == 0 || args[0] != "once" {
+		fatal("Usage: main collect once")
+	}
+
+	cfg := loadConfig()
+	if err := cfg.RequireSensors(); err != nil {
+		fatal("Failed to load configuration", "error", err)
+	}
+
+	db := connectDatabase(cfg.Database)
+	defer db.Close()
+
+	sensorURL, acOutletSensorURL := sensorURLs(cfg)
+	collector := service.NewTempSensorDataCollector(sensorURL, acOutletSensorURL, db)
+
+	if err := collector.CollectTempData(); err != nil {
+		fatal("Data collection failed", "error", err)
+	}
+}
@@ -0,0 +1,406 @@
+// Package config loads the backend configuration from a YAML file with
+// environment variable overrides, validates it and watches it for changes.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"knet_management/auth"
+	"knet_management/database"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the whole backend configuration. Every field can be set in the
+// YAML file; the environment variables listed in applyEnv override the file.
+type Config struct {
+	Database   DatabaseConfig   `yaml:"database"`
+	Server     ServerConfig     `yaml:"server"`
+	Collection CollectionConfig `yaml:"collection"`
+	Sensors    []SensorConfig   `yaml:"sensors"`
+	Alerts     []AlertRule      `yaml:"alerts"`
+	Retention  RetentionConfig  `yaml:"retention"`
+	Auth       AuthConfig       `yaml:"auth"`
+	HTTP       HTTPConfig       `yaml:"http"`
+	Logging    LoggingConfig    `yaml:"logging"`
+	Health     HealthConfig     `yaml:"health"`
+	Stats      StatsConfig      `yaml:"stats"`
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+
+	Timeouts QueryTimeoutsConfig `yaml:"timeouts"`
+	Pool     PoolConfig          `yaml:"pool"`
+}
+
+// QueryTimeoutsConfig is the statement timeout of each query class; 0 disables it
+type QueryTimeoutsConfig struct {
+	Read      Duration `yaml:"read"`      // Latest reading, pages, counts, keys and calibrations
+	Aggregate Duration `yaml:"aggregate"` // History sampling and window aggregates
+	Write     Duration `yaml:"write"`     // Single inserts and key changes
+	Bulk      Duration `yaml:"bulk"`      // Recalibration, quality re-detection, retention and import batches
+	Export    Duration `yaml:"export"`    // A whole streaming export
+}
+
+func (t QueryTimeoutsConfig) QueryTimeouts() database.QueryTimeouts {
+	return database.QueryTimeouts{
+		Read:      t.Read.Duration(),
+		Aggregate: t.Aggregate.Duration(),
+		Write:     t.Write.Duration(),
+		Bulk:      t.Bulk.Duration(),
+		Export:    t.Export.Duration(),
+	}
+}
+
+// PoolConfig sizes the connection pool; 0 keeps the database/sql default
+type PoolConfig struct {
+	MaxOpenConns    int      `yaml:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime Duration `yaml:"conn_max_idle_time"`
+}
+
+func (p PoolConfig) PoolConfig() database.PoolConfig {
+	return database.PoolConfig{
+		MaxOpenConns:    p.MaxOpenConns,
+		MaxIdleConns:    p.MaxIdleConns,
+		ConnMaxLifetime: p.ConnMaxLifetime.Duration(),
+		ConnMaxIdleTime: p.ConnMaxIdleTime.Duration(),
+	}
+}
+
+// DSN returns the lib/pq connection string
+func (d DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		d.Host, d.Port, d.User, d.Password, d.Name, d.SSLMode)
+}
+
+type ServerConfig struct {
+	Port                 string `yaml:"port"`
+	MigrationsDir        string `yaml:"migrations_dir"`         // Empty uses the embedded migrations
+	MigrationDriftPolicy string `yaml:"migration_drift_policy"` // warn or fail
+}
+
+type CollectionConfig struct {
+	Interval Duration `yaml:"interval"`
+}
+
+// SensorConfig is one sensor endpoint. IDs are fixed by the storage schema:
+// main and ac_outlet.
+type SensorConfig struct {
+	ID  string `yaml:"id"`
+	URL string `yaml:"url"`
+}
+
+// AlertRule fires when a sensor metric stays above or below a threshold for For
+type AlertRule struct {
+	Name   string   `yaml:"name"`
+	Sensor string   `yaml:"sensor"`
+	Metric string   `yaml:"metric"` // temperature, humidity or a derived metric (dew_point, ...)
+	Above  *float64 `yaml:"above"`
+	Below  *float64 `yaml:"below"`
+	For    Duration `yaml:"for"`
+}
+
+// StatsConfig sets what /api/temp/stats reports
+type StatsConfig struct {
+	Thresholds []StatsThreshold `yaml:"thresholds"`
+}
+
+// StatsThreshold is a limit whose time above and below /api/temp/stats reports
+type StatsThreshold struct {
+	Sensor string   `yaml:"sensor"`
+	Metric string   `yaml:"metric"` // Same metrics as alert rules
+	Above  *float64 `yaml:"above"`
+	Below  *float64 `yaml:"below"`
+}
+
+func (s StatsConfig) StatsThresholds() []database.StatsThreshold {
+	thresholds := make([]database.StatsThreshold, len(s.Thresholds))
+	for i, t := range s.Thresholds {
+		thresholds[i] = database.StatsThreshold{Sensor: t.Sensor, Metric: t.Metric, Above: t.Above, Below: t.Below}
+	}
+	return thresholds
+}
+
+type RetentionConfig struct {
+	RawData Duration `yaml:"raw_data"` // Delete readings older than this; 0 keeps everything
+}
+
+type AuthConfig struct {
+	AnonymousRole string     `yaml:"anonymous_role"`
+	OIDC          OIDCConfig `yaml:"oidc"`
+}
+
+type OIDCConfig struct {
+	IssuerURL   string            `yaml:"issuer_url"` // Empty disables bearer tokens
+	Audience    string            `yaml:"audience"`
+	JWKSURL     string            `yaml:"jwks_url"`
+	RoleClaim   string            `yaml:"role_claim"`
+	RoleMapping map[string]string `yaml:"role_mapping"`
+	JWKSRefresh Duration          `yaml:"jwks_refresh"`
+}
+
+type HTTPConfig struct {
+	CORS               CORSConfig      `yaml:"cors"`
+	RateLimit          RateLimitConfig `yaml:"rate_limit"`
+	TrustedProxies     []string        `yaml:"trusted_proxies"` // IPs or CIDRs allowed to set X-Forwarded-For
+	MaxBodyBytes       int64           `yaml:"max_body_bytes"`
+	MaxImportBodyBytes int64           `yaml:"max_import_body_bytes"`
+}
+
+type CORSConfig struct {
+	AllowOrigins     []string `yaml:"allow_origins"`
+	AllowMethods     []string `yaml:"allow_methods"`
+	AllowHeaders     []string `yaml:"allow_headers"`
+	ExposeHeaders    []string `yaml:"expose_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAge           Duration `yaml:"max_age"`
+}
+
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"` // 0 disables rate limiting
+	Burst             int     `yaml:"burst"`
+}
+
+type LoggingConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn or error
+	Format string `yaml:"format"` // json or text
+}
+
+// HealthConfig sets when /readyz reports the service as not ready
+type HealthConfig struct {
+	MaxCollectionAge Duration `yaml:"max_collection_age"` // 0 means 3 collection intervals
+	DBPingTimeout    Duration `yaml:"db_ping_timeout"`
+}
+
+// Default returns the configuration used for everything the file and the
+// environment leave out
+func Default() *Config {
+	timeouts := database.DefaultQueryTimeouts()
+	return &Config{
+		Database: DatabaseConfig{
+			Port:    "5432",
+			SSLMode: "disable",
+			Timeouts: QueryTimeoutsConfig{
+				Read:      Duration(timeouts.Read),
+				Aggregate: Duration(timeouts.Aggregate),
+				Write:     Duration(timeouts.Write),
+				Bulk:      Duration(timeouts.Bulk),
+				Export:    Duration(timeouts.Export),
+			},
+			Pool: PoolConfig{
+				MaxOpenConns:    20,
+				MaxIdleConns:    5,
+				ConnMaxLifetime: Duration(30 * time.Minute),
+				ConnMaxIdleTime: Duration(5 * time.Minute),
+			},
+		},
+		Server: ServerConfig{
+			Port:                 "8080",
+			MigrationDriftPolicy: "warn",
+		},
+		Collection: CollectionConfig{Interval: Duration(30 * time.Second)},
+		HTTP: HTTPConfig{
+			CORS: CORSConfig{
+				AllowOrigins:  []string{"*"},
+				AllowMethods:  []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
+				AllowHeaders:  []string{"Origin", "Content-Type", "Authorization", "X-API-Key"},
+				ExposeHeaders: []string{"Content-Disposition", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-Request-ID"},
+				MaxAge:        Duration(12 * time.Hour),
+			},
+			RateLimit:          RateLimitConfig{RequestsPerSecond: 20, Burst: 40},
+			MaxBodyBytes:       1 << 20,   // 1 MiB
+			MaxImportBodyBytes: 100 << 20, // 100 MiB
+		},
+		Logging: LoggingConfig{Level: "info", Format: "json"},
+		Health:  HealthConfig{DBPingTimeout: Duration(2 * time.Second)},
+	}
+}
+
+// Load reads path (optional, "" uses defaults and environment only), applies
+// environment overrides and validates the result
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true) // Typos must not be silently ignored
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	var problems []string
+	cfg.applyEnv(&problems)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// Sensor returns the sensor with id, if configured
+func (c *Config) Sensor(id string) (SensorConfig, bool) {
+	for _, sensor := range c.Sensors {
+		if sensor.ID == id {
+			return sensor, true
+		}
+	}
+	return SensorConfig{}, false
+}
+
+// setSensorURL adds or replaces a sensor
+func (c *Config) setSensorURL(id, url string) {
+	for i := range c.Sensors {
+		if c.Sensors[i].ID == id {
+			c.Sensors[i].URL = url
+			return
+		}
+	}
+	c.Sensors = append(c.Sensors, SensorConfig{ID: id, URL: url})
+}
+
+// applyEnv overrides the file with the environment variables the backend has
+// always used, so existing docker compose setups keep working
+func (c *Config) applyEnv(problems *[]string) {
+	str := func(key string, target *string) {
+		if value, ok := os.LookupEnv(key); ok {
+			*target = value
+		}
+	}
+	list := func(key string, target *[]string) {
+		if value, ok := os.LookupEnv(key); ok && strings.TrimSpace(value) != "" {
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			*target = items
+		}
+	}
+	duration := func(key string, target *Duration) {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			d, err := ParseDuration(value)
+			if err != nil {
+				*problems = append(*problems, fmt.Sprintf("%s: %v", key, err))
+				return
+			}
+			*target = d
+		}
+	}
+	parse := func(key string, parseFn func(string) error) {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			if err := parseFn(value); err != nil {
+				*problems = append(*problems, fmt.Sprintf("%s: invalid value %q", key, value))
+			}
+		}
+	}
+
+	str("DB_HOST", &c.Database.Host)
+	str("DB_PORT", &c.Database.Port)
+	str("DB_USER", &c.Database.User)
+	str("DB_PASSWORD", &c.Database.Password)
+	str("DB_NAME", &c.Database.Name)
+	str("DB_SSLMODE", &c.Database.SSLMode)
+	duration("DB_TIMEOUT_READ", &c.Database.Timeouts.Read)
+	duration("DB_TIMEOUT_AGGREGATE", &c.Database.Timeouts.Aggregate)
+	duration("DB_TIMEOUT_WRITE", &c.Database.Timeouts.Write)
+	duration("DB_TIMEOUT_BULK", &c.Database.Timeouts.Bulk)
+	duration("DB_TIMEOUT_EXPORT", &c.Database.Timeouts.Export)
+	parse("DB_MAX_OPEN_CONNS", func(value string) (err error) {
+		c.Database.Pool.MaxOpenConns, err = strconv.Atoi(value)
+		return err
+	})
+	parse("DB_MAX_IDLE_CONNS", func(value string) (err error) {
+		c.Database.Pool.MaxIdleConns, err = strconv.Atoi(value)
+		return err
+	})
+	duration("DB_CONN_MAX_LIFETIME", &c.Database.Pool.ConnMaxLifetime)
+	duration("DB_CONN_MAX_IDLE_TIME", &c.Database.Pool.ConnMaxIdleTime)
+
+	str("SERVER_PORT", &c.Server.Port)
+	str("MIGRATIONS_DIR", &c.Server.MigrationsDir)
+	str("MIGRATION_DRIFT_POLICY", &c.Server.MigrationDriftPolicy)
+
+	duration("TEMP_COLLECTION_INTERVAL", &c.Collection.Interval)
+
+	// Sensor URLs from host/port/path, as in the original compose files
+	sensorFromEnv := func(id, prefix string) {
+		host, ok := os.LookupEnv(prefix + "_HOST")
+		if !ok {
+			return
+		}
+		port := os.Getenv(prefix + "_PORT")
+		if port == "" {
+			port = "80"
+		}
+		c.setSensorURL(id, fmt.Sprintf("http://%s:%s%s", host, port, os.Getenv(prefix+"_PATH")))
+	}
+	sensorFromEnv(database.SensorMain, "TEMP_SENSOR")
+	sensorFromEnv(database.SensorACOutlet, "AC_OUTLET_SENSOR")
+
+	duration("RETENTION_RAW_DATA", &c.Retention.RawData)
+
+	str("AUTH_ANONYMOUS_ROLE", &c.Auth.AnonymousRole)
+	str("OIDC_ISSUER_URL", &c.Auth.OIDC.IssuerURL)
+	str("OIDC_AUDIENCE", &c.Auth.OIDC.Audience)
+	str("OIDC_JWKS_URL", &c.Auth.OIDC.JWKSURL)
+	str("OIDC_ROLE_CLAIM", &c.Auth.OIDC.RoleClaim)
+	duration("OIDC_JWKS_REFRESH", &c.Auth.OIDC.JWKSRefresh)
+	parse("OIDC_ROLE_MAPPING", func(value string) (err error) {
+		c.Auth.OIDC.RoleMapping, err = auth.ParseRoleMapping(value)
+		return err
+	})
+
+	list("CORS_ALLOW_ORIGINS", &c.HTTP.CORS.AllowOrigins)
+	list("CORS_ALLOW_METHODS", &c.HTTP.CORS.AllowMethods)
+	list("CORS_ALLOW_HEADERS", &c.HTTP.CORS.AllowHeaders)
+	list("CORS_EXPOSE_HEADERS", &c.HTTP.CORS.ExposeHeaders)
+	parse("CORS_ALLOW_CREDENTIALS", func(value string) (err error) {
+		c.HTTP.CORS.AllowCredentials, err = strconv.ParseBool(value)
+		return err
+	})
+	parse("RATE_LIMIT_RPS", func(value string) (err error) {
+		c.HTTP.RateLimit.RequestsPerSecond, err = strconv.ParseFloat(value, 64)
+		return err
+	})
+	parse("RATE_LIMIT_BURST", func(value string) (err error) {
+		c.HTTP.RateLimit.Burst, err = strconv.Atoi(value)
+		return err
+	})
+	list("TRUSTED_PROXIES", &c.HTTP.TrustedProxies)
+	parse("MAX_BODY_BYTES", func(value string) (err error) {
+		c.HTTP.MaxBodyBytes, err = strconv.ParseInt(value, 10, 64)
+		return err
+	})
+	parse("MAX_IMPORT_BODY_BYTES", func(value string) (err error) {
+		c.HTTP.MaxImportBodyBytes, err = strconv.ParseInt(value, 10, 64)
+		return err
+	})
+
+	str("LOG_LEVEL", &c.Logging.Level)
+	str("LOG_FORMAT", &c.Logging.Format)
+
+	duration("HEALTH_MAX_COLLECTION_AGE", &c.Health.MaxCollectionAge)
+	duration("HEALTH_DB_PING_TIMEOUT", &c.Health.DBPingTimeout)
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"knet_management/database"
+	"knet_management/logging"
+)
+
+// ValidationError lists every problem found in the configuration, so all of
+// them can be fixed at once instead of one per restart
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// RequireSensors checks what collecting needs on top of validate: the main
+// sensor. Commands that only read the database do not need sensors.
+func (c *Config) RequireSensors() error {
+	if _, ok := c.Sensor(database.SensorMain); !ok {
+		return &ValidationError{Problems: []string{fmt.Sprintf("sensors: the %s sensor is required", database.SensorMain)}}
+	}
+	return nil
+}
+
+// AlertMetrics are the metrics an alert rule can watch
+var AlertMetrics = map[string]bool{
+	"temperature":       true,
+	"humidity":          true,
+	"dew_point":         true,
+	"absolute_humidity": true,
+	"heat_index":        true,
+	"humidex":           true,
+}
+
+// validate returns one message per problem, prefixed with the field path as
+// written in the YAML file
+func (c *Config) validate() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	for _, required := range []struct{ field, value string }{
+		{"database.host", c.Database.Host},
+		{"database.port", c.Database.Port},
+		{"database.user", c.Database.User},
+		{"database.name", c.Database.Name},
+	} {
+		if required.value == "" {
+			add("%s is required", required.field)
+		}
+	}
+	if c.Database.Port != "" {
+		if port, err := strconv.Atoi(c.Database.Port); err != nil || port <= 0 || port > 65535 {
+			add("database.port: %q is not a valid port", c.Database.Port)
+		}
+	}
+
+	for _, timeout := range []struct {
+		field string
+		value Duration
+	}{
+		{"database.timeouts.read", c.Database.Timeouts.Read},
+		{"database.timeouts.aggregate", c.Database.Timeouts.Aggregate},
+		{"database.timeouts.write", c.Database.Timeouts.Write},
+		{"database.timeouts.bulk", c.Database.Timeouts.Bulk},
+		{"database.timeouts.export", c.Database.Timeouts.Export},
+	} {
+		if timeout.value < 0 {
+			add("%s must not be negative", timeout.field)
+		}
+	}
+	pool := c.Database.Pool
+	if pool.MaxOpenConns < 0 || pool.MaxIdleConns < 0 {
+		add("database.pool: connection counts must not be negative")
+	}
+	if pool.MaxOpenConns > 0 && pool.MaxIdleConns > pool.MaxOpenConns {
+		add("database.pool.max_idle_conns (%d) must not exceed max_open_conns (%d)", pool.MaxIdleConns, pool.MaxOpenConns)
+	}
+	if pool.ConnMaxLifetime < 0 || pool.ConnMaxIdleTime < 0 {
+		add("database.pool: connection lifetimes must not be negative")
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port <= 0 || port > 65535 {
+		add("server.port: %q is not a valid port", c.Server.Port)
+	}
+	if _, err := database.ParseDriftPolicy(c.Server.MigrationDriftPolicy); err != nil {
+		add("server.migration_drift_policy: %v", err)
+	}
+
+	if c.Collection.Interval.Duration() <= 0 {
+		add("collection.interval must be positive")
+	}
+
+	seen := make(map[string]bool)
+	for i, sensor := range c.Sensors {
+		field := fmt.Sprintf("sensors[%d]", i)
+		if sensor.ID != database.SensorMain && sensor.ID != database.SensorACOutlet {
+			add("%s.id: unknown sensor %q. Use: %s, %s", field, sensor.ID, database.SensorMain, database.SensorACOutlet)
+		}
+		if seen[sensor.ID] {
+			add("%s.id: sensor %q is configured twice", field, sensor.ID)
+		}
+		seen[sensor.ID] = true
+
+		parsed, err := url.Parse(sensor.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			add("%s.url: %q is not an http(s) URL", field, sensor.URL)
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, rule := range c.Alerts {
+		field := fmt.Sprintf("alerts[%d]", i)
+		if rule.Name == "" {
+			add("%s.name is required", field)
+		} else if names[rule.Name] {
+			add("%s.name: alert %q is defined twice", field, rule.Name)
+		}
+		names[rule.Name] = true
+
+		if rule.Sensor != database.SensorMain && rule.Sensor != database.SensorACOutlet {
+			add("%s.sensor: unknown sensor %q", field, rule.Sensor)
+		}
+		if !AlertMetrics[rule.Metric] {
+			add("%s.metric: unknown metric %q. Use: temperature, humidity, dew_point, absolute_humidity, heat_index, humidex", field, rule.Metric)
+		}
+		if rule.Above == nil && rule.Below == nil {
+			add("%s: set above, below or both", field)
+		}
+		if rule.Above != nil && rule.Below != nil && *rule.Below >= *rule.Above {
+			add("%s: below (%g) must be lower than above (%g)", field, *rule.Below, *rule.Above)
+		}
+		if rule.For < 0 {
+			add("%s.for must not be negative", field)
+		}
+	}
+
+	for i, threshold := range c.Stats.Thresholds {
+		field := fmt.Sprintf("stats.thresholds[%d]", i)
+		if threshold.Sensor != database.SensorMain && threshold.Sensor != database.SensorACOutlet {
+			add("%s.sensor: unknown sensor %q", field, threshold.Sensor)
+		}
+		if !AlertMetrics[threshold.Metric] {
+			add("%s.metric: unknown metric %q. Use: temperature, humidity, dew_point, absolute_humidity, heat_index, humidex", field, threshold.Metric)
+		}
+		if threshold.Above == nil && threshold.Below == nil {
+			add("%s: set above, below or both", field)
+		}
+		if threshold.Above != nil && threshold.Below != nil && *threshold.Below >= *threshold.Above {
+			add("%s: below (%g) must be lower than above (%g)", field, *threshold.Below, *threshold.Above)
+		}
+	}
+
+	if c.Retention.RawData < 0 {
+		add("retention.raw_data must not be negative")
+	}
+
+	if c.Auth.AnonymousRole != "" && !database.IsValidRole(c.Auth.AnonymousRole) {
+		add("auth.anonymous_role: unknown role %q. Use: viewer, operator, admin, ingest-device", c.Auth.AnonymousRole)
+	}
+	if c.Auth.OIDC.IssuerURL == "" {
+		if c.Auth.OIDC.Audience != "" || c.Auth.OIDC.JWKSURL != "" || len(c.Auth.OIDC.RoleMapping) > 0 {
+			add("auth.oidc.issuer_url is required when other OIDC settings are set")
+		}
+	}
+	for claim, role := range c.Auth.OIDC.RoleMapping {
+		if !database.IsValidRole(role) {
+			add("auth.oidc.role_mapping.%s: unknown role %q", claim, role)
+		}
+	}
+	if c.Auth.OIDC.JWKSRefresh < 0 {
+		add("auth.oidc.jwks_refresh must not be negative")
+	}
+
+	for _, origin := range c.HTTP.CORS.AllowOrigins {
+		if origin == "*" && c.HTTP.CORS.AllowCredentials {
+			add("http.cors: allow_credentials cannot be used with origin \"*\", list the origins instead")
+		}
+	}
+	if c.HTTP.RateLimit.RequestsPerSecond < 0 {
+		add("http.rate_limit.requests_per_second must not be negative")
+	}
+	if c.HTTP.RateLimit.Burst < 0 {
+		add("http.rate_limit.burst must not be negative")
+	}
+	for _, proxy := range c.HTTP.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			add("http.trusted_proxies: %q is not an IP address or CIDR", proxy)
+		}
+	}
+	if c.HTTP.MaxBodyBytes <= 0 {
+		add("http.max_body_bytes must be positive")
+	}
+	if c.HTTP.MaxImportBodyBytes <= 0 {
+		add("http.max_import_body_bytes must be positive")
+	}
+
+	if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+		add("logging.level: %v", err)
+	}
+	if c.Logging.Format != "json" && c.Logging.Format != "text" {
+		add("logging.format: unknown format %q. Use: json, text", c.Logging.Format)
+	}
+
+	if c.Health.MaxCollectionAge < 0 {
+		add("health.max_collection_age must not be negative")
+	}
+	if c.Health.DBPingTimeout <= 0 {
+		add("health.db_ping_timeout must be positive")
+	}
+
+	return problems
+}
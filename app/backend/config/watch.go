@@ -0,0 +1,83 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Watch reloads path on SIGHUP and whenever its modification time or size
+// changes (checked every pollInterval), and passes every valid configuration
+// to onReload. Invalid files are logged and the running configuration is kept.
+// Polling works for bind-mounted files too, where inotify events are unreliable.
+// Without a path SIGHUP re-reads the environment, so the signal never falls
+// back to its default action of terminating the process.
+func Watch(path string, pollInterval time.Duration, onReload func(*Config)) (stop func()) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	done := make(chan struct{})
+
+	// No file to poll without a path; a nil channel never fires
+	var ticks <-chan time.Time
+	var ticker *time.Ticker
+	if path != "" {
+		ticker = time.NewTicker(pollInterval)
+		ticks = ticker.C
+	}
+
+	source := path
+	if source == "" {
+		source = "environment"
+	}
+
+	lastMod, lastSize := fileVersion(path)
+
+	reload := func(reason string) {
+		cfg, err := Load(path)
+		if err != nil {
+			slog.Error("Config reload rejected, keeping the running configuration", "source", source, "reason", reason, "error", err)
+			return
+		}
+		slog.Info("Config reloaded", "source", source, "reason", reason)
+		onReload(cfg)
+	}
+
+	go func() {
+		if ticker != nil {
+			defer ticker.Stop()
+		}
+		defer signal.Stop(hangup)
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-hangup:
+				lastMod, lastSize = fileVersion(path)
+				reload("SIGHUP")
+			case <-ticks:
+				mod, size := fileVersion(path)
+				if mod.IsZero() || (mod.Equal(lastMod) && size == lastSize) {
+					continue
+				}
+				lastMod, lastSize = mod, size
+				reload("file changed")
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// fileVersion returns the modification time and size of path; zero if it
+// cannot be read (e.g. while an editor replaces it)
+func fileVersion(path string) (time.Time, int64) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Without a config file SIGHUP re-reads the environment instead of
+// terminating the process
+func TestWatchReloadsEnvironmentOnSIGHUP(t *testing.T) {
+	for name, value := range map[string]string{"DB_HOST": "localhost", "DB_USER": "knet", "DB_NAME": "knet", "SERVER_PORT": "38444"} {
+		t.Setenv(name, value)
+	}
+
+	reloaded := make(chan *Config, 1)
+	stop := Watch("", time.Hour, func(cfg *Config) { reloaded <- cfg })
+	defer stop()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case cfg := <-reloaded:
+		if cfg.Server.Port != "38444" {
+			t.Errorf("reloaded server.port = %q, want the environment's 38444", cfg.Server.Port)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("SIGHUP did not reload the configuration")
+	}
+}
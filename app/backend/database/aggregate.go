@@ -0,0 +1,240 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Per-bucket aggregate functions. Percentiles are written pN (p5, p95, ...).
+const (
+	AggregateMean         = "mean"
+	AggregateMin          = "min"
+	AggregateMax          = "max"
+	AggregateMedian       = "median"
+	AggregateStddev       = "stddev" // Population standard deviation
+	AggregateFirst        = "first"
+	AggregateLast         = "last"
+	AggregateCount        = "count"
+	AggregateTimeWeighted = "twa" // Time-weighted average, trapezoids between readings
+)
+
+var aggregateFunctions = []string{
+	AggregateMean, AggregateMin, AggregateMax, AggregateMedian, AggregateStddev,
+	AggregateFirst, AggregateLast, AggregateCount, AggregateTimeWeighted,
+}
+
+// AggregateMetrics are the metrics bucket aggregates can be computed for.
+// AC outlet metrics carry the ac_outlet_ prefix, like the reading fields.
+var AggregateMetrics = []string{
+	MetricTemperature, MetricHumidity, MetricDewPoint, MetricAbsoluteHumidity, MetricHeatIndex, MetricHumidex,
+	"ac_outlet_" + MetricTemperature, "ac_outlet_" + MetricHumidity, "ac_outlet_" + MetricDewPoint,
+	"ac_outlet_" + MetricAbsoluteHumidity, "ac_outlet_" + MetricHeatIndex, "ac_outlet_" + MetricHumidex,
+}
+
+// defaultAggregateMetrics are the measured metrics of both sensors
+var defaultAggregateMetrics = []string{
+	MetricTemperature, MetricHumidity, "ac_outlet_" + MetricTemperature, "ac_outlet_" + MetricHumidity,
+}
+
+// BucketAggregation selects the aggregates GetTempSensorDataInBuckets adds to
+// every point. No functions means no aggregates.
+type BucketAggregation struct {
+	Functions []string
+	Metrics   []string
+}
+
+// ParseAggregateFunctions parses the comma separated aggregate parameter;
+// empty means no bucket aggregates
+func ParseAggregateFunctions(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var functions []string
+	for _, function := range strings.Split(value, ",") {
+		function = strings.TrimSpace(function)
+		if !isAggregateFunction(function) {
+			return nil, fmt.Errorf("unknown aggregate %q", function)
+		}
+		functions = appendUnique(functions, function)
+	}
+	return functions, nil
+}
+
+// ParseAggregateMetrics parses the comma separated aggregate_metrics
+// parameter, defaulting to temperature and humidity of both sensors
+func ParseAggregateMetrics(value string) ([]string, error) {
+	if value == "" {
+		return defaultAggregateMetrics, nil
+	}
+	var metrics []string
+	for _, metric := range strings.Split(value, ",") {
+		metric = strings.TrimSpace(metric)
+		if !containsString(AggregateMetrics, metric) {
+			return nil, fmt.Errorf("unknown aggregate metric %q", metric)
+		}
+		metrics = appendUnique(metrics, metric)
+	}
+	return metrics, nil
+}
+
+// Enabled reports whether any aggregate was requested
+func (a BucketAggregation) Enabled() bool {
+	return len(a.Functions) > 0
+}
+
+func isAggregateFunction(function string) bool {
+	if containsString(aggregateFunctions, function) {
+		return true
+	}
+	_, ok := percentileOf(function)
+	return ok
+}
+
+// percentileOf parses pN with N from 1 to 99
+func percentileOf(function string) (float64, bool) {
+	if !strings.HasPrefix(function, "p") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(function[1:])
+	if err != nil || n < 1 || n > 99 || function[1:] != strconv.Itoa(n) {
+		return 0, false
+	}
+	return float64(n), true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(values []string, value string) []string {
+	if containsString(values, value) {
+		return values
+	}
+	return append(values, value)
+}
+
+// aggregateMetricSource splits "ac_outlet_dew_point" into sensor and metric
+func aggregateMetricSource(name string) (string, string) {
+	if metric, ok := strings.CutPrefix(name, "ac_outlet_"); ok {
+		return SensorACOutlet, metric
+	}
+	return SensorMain, name
+}
+
+// aggregateBucket computes the requested aggregates over the readings of one
+// bucket (oldest first). Functions without readings are null, count is 0.
+func aggregateBucket(readings []TempSensorData, bucket Bucket, aggregation BucketAggregation) *BucketAggregates {
+	result := &BucketAggregates{
+		Start:   bucket.Start,
+		End:     bucket.End,
+		Metrics: make(map[string]map[string]*float64, len(aggregation.Metrics)),
+	}
+
+	for _, name := range aggregation.Metrics {
+		sensor, metric := aggregateMetricSource(name)
+		var values []float64
+		var times []time.Time
+		for i := range readings {
+			if value, ok := MetricValue(&readings[i], sensor, metric); ok {
+				values = append(values, value)
+				times = append(times, readings[i].Timestamp)
+			}
+		}
+
+		functions := make(map[string]*float64, len(aggregation.Functions))
+		for _, function := range aggregation.Functions {
+			functions[function] = aggregateValues(function, values, times)
+		}
+		result.Metrics[name] = functions
+	}
+	return result
+}
+
+func aggregateValues(function string, values []float64, times []time.Time) *float64 {
+	if function == AggregateCount {
+		count := float64(len(values))
+		return &count
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	var value float64
+	switch function {
+	case AggregateMean:
+		value = mean(values)
+	case AggregateMin:
+		value = values[0]
+		for _, v := range values[1:] {
+			value = math.Min(value, v)
+		}
+	case AggregateMax:
+		value = values[0]
+		for _, v := range values[1:] {
+			value = math.Max(value, v)
+		}
+	case AggregateMedian:
+		value = percentile(values, 50)
+	case AggregateStddev:
+		m := mean(values)
+		var sum float64
+		for _, v := range values {
+			sum += (v - m) * (v - m)
+		}
+		value = math.Sqrt(sum / float64(len(values)))
+	case AggregateFirst:
+		value = values[0]
+	case AggregateLast:
+		value = values[len(values)-1]
+	case AggregateTimeWeighted:
+		value = timeWeightedAverage(values, times)
+	default:
+		p, _ := percentileOf(function)
+		value = percentile(values, p)
+	}
+	return &value
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// percentile interpolates linearly between the closest ranks
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// timeWeightedAverage integrates the readings as straight lines between
+// consecutive timestamps, so a reading after a long gap does not count like
+// one in a dense run. A single reading (or equal timestamps) is its mean.
+func timeWeightedAverage(values []float64, times []time.Time) float64 {
+	var area, span float64
+	for i := 1; i < len(values); i++ {
+		dt := times[i].Sub(times[i-1]).Seconds()
+		area += (values[i] + values[i-1]) / 2 * dt
+		span += dt
+	}
+	if span == 0 {
+		return mean(values)
+	}
+	return area / span
+}
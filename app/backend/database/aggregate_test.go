@@ -0,0 +1,95 @@
+package database
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAggregateValues(t *testing.T) {
+	start := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+	at := func(seconds ...int) []time.Time {
+		times := make([]time.Time, len(seconds))
+		for i, s := range seconds {
+			times[i] = start.Add(time.Duration(s) * time.Second)
+		}
+		return times
+	}
+	unsorted := []float64{40, 15, 50, 35, 20} // Sorted: 15 20 35 40 50
+
+	tests := []struct {
+		name     string
+		function string
+		values   []float64
+		times    []time.Time
+		want     *float64
+	}{
+		{name: "median of odd count", function: AggregateMedian, values: unsorted, want: float(35)},
+		{name: "median of even count", function: AggregateMedian, values: []float64{4, 1, 3, 2}, want: float(2.5)},
+		{name: "percentile on a rank", function: "p25", values: unsorted, want: float(20)},
+		{name: "percentile between ranks", function: "p40", values: unsorted, want: float(29)}, // Rank 1.6: 20 + 0.6 * (35-20)
+		{name: "p1", function: "p1", values: unsorted, want: float(15.2)},                      // Rank 0.04
+		{name: "p99", function: "p99", values: unsorted, want: float(49.6)},                    // Rank 3.96
+		{name: "percentile of one value", function: "p95", values: []float64{7}, want: float(7)},
+		{name: "stddev is the population one", function: AggregateStddev, values: []float64{2, 4, 4, 4, 5, 5, 7, 9}, want: float(2)},
+		{name: "stddev of one value", function: AggregateStddev, values: []float64{3}, want: float(0)},
+		{name: "mean", function: AggregateMean, values: unsorted, want: float(32)},
+		{name: "min", function: AggregateMin, values: unsorted, want: float(15)},
+		{name: "max", function: AggregateMax, values: unsorted, want: float(50)},
+		{name: "first", function: AggregateFirst, values: unsorted, want: float(40)},
+		{name: "last", function: AggregateLast, values: unsorted, want: float(20)},
+		{name: "twa of even spacing is the trapezoid mean", function: AggregateTimeWeighted, values: []float64{0, 10, 20}, times: at(0, 10, 20), want: float(10)},
+		// (10+20)/2*60 + (20+20)/2*180 over 240s; the plain mean is 16.67
+		{name: "twa of uneven spacing", function: AggregateTimeWeighted, values: []float64{10, 20, 20}, times: at(0, 60, 240), want: float(18.75)},
+		{name: "twa after a long gap", function: AggregateTimeWeighted, values: []float64{10, 10, 40}, times: at(0, 290, 300), want: float(10.5)},
+		{name: "twa of one reading", function: AggregateTimeWeighted, values: []float64{21}, times: at(0), want: float(21)},
+		{name: "twa of equal timestamps", function: AggregateTimeWeighted, values: []float64{20, 22}, times: at(5, 5), want: float(21)},
+		{name: "count", function: AggregateCount, values: unsorted, want: float(5)},
+		{name: "count of no values", function: AggregateCount, want: float(0)},
+		{name: "mean of no values", function: AggregateMean, want: nil},
+		{name: "percentile of no values", function: "p50", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := aggregateValues(tt.function, tt.values, tt.times)
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("%s = %v, want null", tt.function, *got)
+			case tt.want != nil && got == nil:
+				t.Errorf("%s = null, want %v", tt.function, *tt.want)
+			case tt.want != nil && math.Abs(*got-*tt.want) > 1e-9:
+				t.Errorf("%s = %v, want %v", tt.function, *got, *tt.want)
+			}
+		})
+	}
+
+	if unsorted[0] != 40 || unsorted[4] != 20 {
+		t.Errorf("percentile sorted its input: %v", unsorted)
+	}
+}
+
+func TestPercentileOf(t *testing.T) {
+	tests := []struct {
+		function string
+		want     float64
+		ok       bool
+	}{
+		{function: "p1", want: 1, ok: true},
+		{function: "p95", want: 95, ok: true},
+		{function: "p99", want: 99, ok: true},
+		{function: "p0"},
+		{function: "p100"},
+		{function: "p05"},
+		{function: "p"},
+		{function: "p9.5"},
+		{function: "median"},
+	}
+
+	for _, tt := range tests {
+		got, ok := percentileOf(tt.function)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("percentileOf(%q) = %v, %v, want %v, %v", tt.function, got, ok, tt.want, tt.ok)
+		}
+	}
+}
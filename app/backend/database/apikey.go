@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefix marks our API keys so they can be told apart from JWTs and
+// found by secret scanners
+const apiKeyPrefix = "knet_"
+
+// apiKeyDisplayLength is how much of the key is kept in key_prefix
+const apiKeyDisplayLength = len(apiKeyPrefix) + 8
+
+const apiKeyColumns = `id, name, role, key_prefix, created_at, expires_at, last_used_at, revoked_at, rotated_from`
+
+// IsValidRole reports whether role is one of the API key roles
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleViewer, RoleOperator, RoleAdmin, RoleIngestDevice:
+		return true
+	}
+	return false
+}
+
+// IsAPIKey reports whether a credential looks like one of our API keys
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, apiKeyPrefix)
+}
+
+// hashAPIKey returns the hex SHA-256 of a key. Keys are 256-bit random, so a
+// plain hash is enough; a slow password hash would only slow down every request.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateAPIKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func scanAPIKey(row rowScanner, key *APIKey) error {
+	return row.Scan(&key.ID, &key.Name, &key.Role, &key.KeyPrefix, &key.CreatedAt,
+		&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.RotatedFrom)
+}
+
+// CreateAPIKey stores a new key and returns it together with the plaintext
+// key, which is not recoverable afterwards
+func (db *Database) CreateAPIKey(ctx context.Context, name, role string, expiresAt *time.Time) (*APIKey, string, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryWrite)
+	defer cancel()
+
+	if !IsValidRole(role) {
+		return nil, "", fmt.Errorf("invalid role %q", role)
+	}
+
+	plaintext, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `
+	INSERT INTO api_keys (name, role, key_prefix, key_hash, expires_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING ` + apiKeyColumns
+
+	var key APIKey
+	if err := scanAPIKey(db.QueryRowContext(ctx, query, name, role, plaintext[:apiKeyDisplayLength], hashAPIKey(plaintext), expiresAt), &key); err != nil {
+		return nil, "", err
+	}
+	return &key, plaintext, nil
+}
+
+// GetAPIKeys returns all keys including revoked ones, newest first
+func (db *Database) GetAPIKeys(ctx context.Context) ([]APIKey, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryRead)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var key APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// AuthenticateAPIKey returns the active (not revoked, not expired) key
+// matching plaintext, or sql.ErrNoRows
+func (db *Database) AuthenticateAPIKey(ctx context.Context, plaintext string) (*APIKey, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryRead)
+	defer cancel()
+
+	query := `
+	SELECT ` + apiKeyColumns + `
+	FROM api_keys
+	WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())`
+
+	var key APIKey
+	if err := scanAPIKey(db.QueryRowContext(ctx, query, hashAPIKey(plaintext)), &key); err != nil {
+		return nil, err
+	}
+
+	// At most one write per key and minute
+	db.ExecContext(ctx, `
+	UPDATE api_keys SET last_used_at = NOW()
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`, key.ID)
+
+	return &key, nil
+}
+
+// RevokeAPIKey revokes an active key; sql.ErrNoRows if there is none with id
+func (db *Database) RevokeAPIKey(ctx context.Context, id int) (*APIKey, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryWrite)
+	defer cancel()
+
+	query := `
+	UPDATE api_keys SET revoked_at = NOW()
+	WHERE id = $1 AND revoked_at IS NULL
+	RETURNING ` + apiKeyColumns
+
+	var key APIKey
+	if err := scanAPIKey(db.QueryRowContext(ctx, query, id), &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RotateAPIKey replaces an active key with a new one of the same name, role
+// and expiry, and revokes the old key in the same transaction
+func (db *Database) RotateAPIKey(ctx context.Context, id int) (*APIKey, string, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryWrite)
+	defer cancel()
+
+	plaintext, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	var name, role string
+	var expiresAt *time.Time
+	err = tx.QueryRowContext(ctx, `
+	UPDATE api_keys SET revoked_at = NOW()
+	WHERE id = $1 AND revoked_at IS NULL
+	RETURNING name, role, expires_at`, id).Scan(&name, &role, &expiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `
+	INSERT INTO api_keys (name, role, key_prefix, key_hash, expires_at, rotated_from)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING ` + apiKeyColumns
+
+	var key APIKey
+	if err := scanAPIKey(tx.QueryRowContext(ctx, query, name, role, plaintext[:apiKeyDisplayLength], hashAPIKey(plaintext), expiresAt, id), &key); err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	return &key, plaintext, nil
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// Bucket is the half-open interval [Start, End) of one sampled history point
+type Bucket struct {
+	Start time.Time
+	End   time.Time
+}
+
+// bucketSize is a calendar-aware bucket width. Sub-day sizes divide a day, so
+// every local day starts a new bucket at midnight whatever its length.
+type bucketSize struct {
+	label   string
+	nominal time.Duration // Typical width, to skip sizes that are far too small
+	minutes int           // Sub-day sizes: wall clock minutes
+	days    int           // Day and week sizes
+	months  int           // Month, quarter and year sizes
+}
+
+var bucketSizes = []bucketSize{
+	{label: "1m", nominal: time.Minute, minutes: 1},
+	{label: "2m", nominal: 2 * time.Minute, minutes: 2},
+	{label: "5m", nominal: 5 * time.Minute, minutes: 5},
+	{label: "10m", nominal: 10 * time.Minute, minutes: 10},
+	{label: "15m", nominal: 15 * time.Minute, minutes: 15},
+	{label: "30m", nominal: 30 * time.Minute, minutes: 30},
+	{label: "1h", nominal: time.Hour, minutes: 60},
+	{label: "2h", nominal: 2 * time.Hour, minutes: 120},
+	{label: "3h", nominal: 3 * time.Hour, minutes: 180},
+	{label: "4h", nominal: 4 * time.Hour, minutes: 240},
+	{label: "6h", nominal: 6 * time.Hour, minutes: 360},
+	{label: "8h", nominal: 8 * time.Hour, minutes: 480},
+	{label: "12h", nominal: 12 * time.Hour, minutes: 720},
+	{label: "1d", nominal: 24 * time.Hour, days: 1},
+	{label: "1w", nominal: 7 * 24 * time.Hour, days: 7},
+	{label: "1mo", nominal: 30 * 24 * time.Hour, months: 1},
+	{label: "3mo", nominal: 91 * 24 * time.Hour, months: 3},
+	{label: "1y", nominal: 365 * 24 * time.Hour, months: 12},
+}
+
+// floor returns the bucket boundary at or before t, in t's location.
+// Weeks start on Monday, quarters in January, April, July and October.
+func (s bucketSize) floor(t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch {
+	case s.minutes > 0:
+		minutes := t.Hour()*60 + t.Minute()
+		return time.Date(year, month, day, 0, minutes-minutes%s.minutes, 0, 0, t.Location())
+	case s.days == 7:
+		return time.Date(year, month, day-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+	case s.days > 0:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(year, month-(month-1)%time.Month(s.months), 1, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// next returns the boundary after b. Wall clock times that do not exist on a
+// daylight saving day resolve to an instant already used, so they are skipped.
+func (s bucketSize) next(b time.Time) time.Time {
+	year, month, day := b.Date()
+	switch {
+	case s.minutes > 0:
+		minutes := b.Hour()*60 + b.Minute()
+		for step := 1; ; step++ {
+			wall := minutes - minutes%s.minutes + step*s.minutes
+			if wall >= 24*60 {
+				return time.Date(year, month, day+1, 0, 0, 0, 0, b.Location())
+			}
+			if next := time.Date(year, month, day, 0, wall, 0, 0, b.Location()); next.After(b) {
+				return next
+			}
+		}
+	case s.days > 0:
+		return time.Date(year, month, day+s.days, 0, 0, 0, 0, b.Location())
+	default:
+		return time.Date(year, month+time.Month(s.months), 1, 0, 0, 0, 0, b.Location())
+	}
+}
+
+// AlignedBuckets splits [start, end) into at most maxBuckets buckets whose
+// boundaries fall on local midnight in loc (or on a fixed step after it), so
+// days, weeks and months stay whole across daylight saving changes. The first
+// and last bucket are cut to the range. It returns the buckets and the label
+// of their size, e.g. "15m", "1d" or "1mo". Ranges longer than maxBuckets
+// years use yearly buckets.
+func AlignedBuckets(start, end time.Time, loc *time.Location, maxBuckets int) ([]Bucket, string) {
+	start, end = start.In(loc), end.In(loc)
+	if !end.After(start) || maxBuckets < 1 {
+		return nil, ""
+	}
+
+	minimum := end.Sub(start) / time.Duration(maxBuckets)
+	var buckets []Bucket
+	var label string
+	for i, size := range bucketSizes {
+		if size.nominal*2 < minimum && i < len(bucketSizes)-1 {
+			continue
+		}
+
+		buckets = buckets[:0]
+		fits := true
+		for boundary := size.floor(start); boundary.Before(end); boundary = size.next(boundary) {
+			if len(buckets) == maxBuckets {
+				fits = false
+				break
+			}
+			buckets = append(buckets, Bucket{Start: boundary, End: size.next(boundary)})
+		}
+		label = size.label
+		if fits {
+			break
+		}
+	}
+	if len(buckets) == maxBuckets && buckets[len(buckets)-1].End.Before(end) {
+		// Even yearly buckets do not fit; extend the last one to the end
+		buckets[len(buckets)-1].End = end
+	}
+
+	buckets[0].Start = start
+	if buckets[len(buckets)-1].End.After(end) {
+		buckets[len(buckets)-1].End = end
+	}
+	return buckets, label
+}
+
+// CalendarBuckets splits [start, end) into buckets of one size label ("1d",
+// "1w", ...) aligned in loc like AlignedBuckets, the first and last cut to
+// the range. Unknown labels and empty ranges return no buckets.
+func CalendarBuckets(start, end time.Time, loc *time.Location, label string) []Bucket {
+	start, end = start.In(loc), end.In(loc)
+	if !end.After(start) {
+		return nil
+	}
+
+	for _, size := range bucketSizes {
+		if size.label != label {
+			continue
+		}
+		var buckets []Bucket
+		for boundary := size.floor(start); boundary.Before(end); boundary = size.next(boundary) {
+			buckets = append(buckets, Bucket{Start: boundary, End: size.next(boundary)})
+		}
+		buckets[0].Start = start
+		if buckets[len(buckets)-1].End.After(end) {
+			buckets[len(buckets)-1].End = end
+		}
+		return buckets
+	}
+	return nil
+}
+
+// EvenBuckets splits [start, end) into n buckets of equal length
+func EvenBuckets(start, end time.Time, n int) []Bucket {
+	if !end.After(start) || n < 1 {
+		return nil
+	}
+	size := end.Sub(start) / time.Duration(n)
+	buckets := make([]Bucket, n)
+	for i := range buckets {
+		buckets[i] = Bucket{Start: start.Add(time.Duration(i) * size), End: start.Add(time.Duration(i+1) * size)}
+	}
+	buckets[n-1].End = end
+	return buckets
+}
+
+// GetTempSensorDataInBuckets samples one reading per bucket: the first one in
+// the bucket. Buckets without a reading become gap slots at the bucket start,
+// like in GetTempSensorDataWithTimeIntervals. When aggregation is enabled,
+// every point also gets the aggregates over all readings of its bucket.
+func (db *Database) GetTempSensorDataInBuckets(ctx context.Context, buckets []Bucket, filter QualityFilter, aggregation BucketAggregation) ([]TempSensorData, error) {
+	if len(buckets) == 0 {
+		return []TempSensorData{}, nil
+	}
+
+	ctx, cancel := db.withTimeout(ctx, QueryAggregate)
+	defer cancel()
+
+	allData, err := db.getTempSensorDataInRange(ctx, buckets[0].Start, buckets[len(buckets)-1].End, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]TempSensorData, 0, len(buckets))
+	eachBucket(allData, buckets, func(bucket Bucket, readings []TempSensorData) {
+		point := TempSensorData{Timestamp: bucket.Start, Gap: true}
+		if len(readings) > 0 {
+			point = readings[0]
+		}
+		if aggregation.Enabled() {
+			point.Bucket = aggregateBucket(readings, bucket, aggregation)
+		}
+		result = append(result, point)
+	})
+
+	result = markOutliers(result)
+	result = addDerivedMetrics(result)
+
+	return result, nil
+}
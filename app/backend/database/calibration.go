@@ -0,0 +1,260 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+var validCalibrationTargets = map[string]map[string]bool{
+	SensorMain:     {MetricTemperature: true, MetricHumidity: true},
+	SensorACOutlet: {MetricTemperature: true, MetricHumidity: true},
+}
+
+// IsValidCalibrationTarget reports whether sensor/metric can be calibrated
+func IsValidCalibrationTarget(sensor, metric string) bool {
+	return validCalibrationTargets[sensor][metric]
+}
+
+// Apply returns the calibrated value for a raw value
+func (c SensorCalibration) Apply(raw float64) float64 {
+	return raw*c.Slope + c.Offset
+}
+
+// InsertSensorCalibration stores a new calibration record
+func (db *Database) InsertSensorCalibration(ctx context.Context, cal *SensorCalibration) error {
+	ctx, cancel := db.withTimeout(ctx, QueryWrite)
+	defer cancel()
+
+	return insertSensorCalibration(ctx, db, cal)
+}
+
+// InsertSensorCalibrationAndRecalibrate stores a new calibration record and
+// recalibrates the stored readings from its effective_from until endTime in
+// one transaction, so a failed recompute does not leave the calibration stored
+func (db *Database) InsertSensorCalibrationAndRecalibrate(ctx context.Context, cal *SensorCalibration, endTime time.Time) (int64, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryBulk)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := insertSensorCalibration(ctx, tx, cal); err != nil {
+		return 0, err
+	}
+
+	updated, err := recalibrate(ctx, tx, cal.EffectiveFrom, endTime)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+// rowQuerier is implemented by both *sql.DB and *sql.Tx
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func insertSensorCalibration(ctx context.Context, q rowQuerier, cal *SensorCalibration) error {
+	if !IsValidCalibrationTarget(cal.Sensor, cal.Metric) {
+		return fmt.Errorf("invalid calibration target %s/%s", cal.Sensor, cal.Metric)
+	}
+
+	query := `
+	INSERT INTO sensor_calibration (sensor, metric, offset_value, slope, effective_from, note) 
+	VALUES ($1, $2, $3, $4, $5, $6) 
+	RETURNING id, created_at`
+
+	return q.QueryRowContext(ctx, query, cal.Sensor, cal.Metric, cal.Offset, cal.Slope, dbTime(cal.EffectiveFrom), cal.Note).Scan(&cal.ID, &cal.CreatedAt)
+}
+
+// GetSensorCalibrations returns the calibration history, newest first.
+// An empty sensor returns the history of all sensors.
+func (db *Database) GetSensorCalibrations(ctx context.Context, sensor string) ([]SensorCalibration, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryRead)
+	defer cancel()
+
+	query := `
+	SELECT id, sensor, metric, offset_value, slope, effective_from, COALESCE(note, ''), created_at 
+	FROM sensor_calibration 
+	WHERE $1 = '' OR sensor = $1 
+	ORDER BY effective_from DESC, id DESC`
+
+	rows, err := db.QueryContext(ctx, query, sensor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calibrations := []SensorCalibration{}
+	for rows.Next() {
+		var cal SensorCalibration
+		if err := rows.Scan(&cal.ID, &cal.Sensor, &cal.Metric, &cal.Offset, &cal.Slope, &cal.EffectiveFrom, &cal.Note, &cal.CreatedAt); err != nil {
+			return nil, err
+		}
+		cal.EffectiveFrom = localTime(cal.EffectiveFrom)
+		calibrations = append(calibrations, cal)
+	}
+
+	return calibrations, rows.Err()
+}
+
+// getActiveCalibrations returns the calibration in effect at the given time
+// for every sensor/metric pair, keyed by "sensor/metric"
+func (db *Database) getActiveCalibrations(ctx context.Context, at time.Time) (map[string]SensorCalibration, error) {
+	query := `
+	SELECT DISTINCT ON (sensor, metric) id, sensor, metric, offset_value, slope, effective_from 
+	FROM sensor_calibration 
+	WHERE effective_from <= $1 
+	ORDER BY sensor, metric, effective_from DESC, id DESC`
+
+	rows, err := db.QueryContext(ctx, query, dbTime(at))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	active := make(map[string]SensorCalibration)
+	for rows.Next() {
+		var cal SensorCalibration
+		if err := rows.Scan(&cal.ID, &cal.Sensor, &cal.Metric, &cal.Offset, &cal.Slope, &cal.EffectiveFrom); err != nil {
+			return nil, err
+		}
+		cal.EffectiveFrom = localTime(cal.EffectiveFrom)
+		active[cal.Sensor+"/"+cal.Metric] = cal
+	}
+
+	return active, rows.Err()
+}
+
+// applyCalibration keeps the incoming values as raw values and replaces the
+// measured values with their calibrated counterparts
+func (db *Database) applyCalibration(ctx context.Context, data *TempSensorData) error {
+	keepRawValues(data)
+
+	active, err := db.getActiveCalibrations(ctx, data.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	applyCalibrations(data, active)
+	return nil
+}
+
+// keepRawValues copies the incoming values to the raw value fields
+func keepRawValues(data *TempSensorData) {
+	if data.RawTemperature == nil {
+		raw := data.Temperature
+		data.RawTemperature = &raw
+	}
+	if data.RawHumidity == nil {
+		raw := data.Humidity
+		data.RawHumidity = &raw
+	}
+	if data.RawACOutletTemperature == nil && data.ACOutletTemperature != nil {
+		raw := *data.ACOutletTemperature
+		data.RawACOutletTemperature = &raw
+	}
+	if data.RawACOutletHumidity == nil && data.ACOutletHumidity != nil {
+		raw := *data.ACOutletHumidity
+		data.RawACOutletHumidity = &raw
+	}
+}
+
+// activeCalibrationsAt picks the calibrations in effect at the given time from a
+// history ordered newest first (as returned by GetSensorCalibrations)
+func activeCalibrationsAt(history []SensorCalibration, at time.Time) map[string]SensorCalibration {
+	active := make(map[string]SensorCalibration)
+	for _, cal := range history {
+		key := cal.Sensor + "/" + cal.Metric
+		if _, found := active[key]; found || cal.EffectiveFrom.After(at) {
+			continue
+		}
+		active[key] = cal
+	}
+	return active
+}
+
+// applyCalibrations sets the measured values from the raw values of data
+func applyCalibrations(data *TempSensorData, active map[string]SensorCalibration) {
+	calibrate := func(sensor, metric string, raw float64) float64 {
+		if cal, ok := active[sensor+"/"+metric]; ok {
+			return cal.Apply(raw)
+		}
+		return raw
+	}
+
+	data.Temperature = calibrate(SensorMain, MetricTemperature, *data.RawTemperature)
+	data.Humidity = calibrate(SensorMain, MetricHumidity, *data.RawHumidity)
+	if data.RawACOutletTemperature != nil {
+		value := calibrate(SensorACOutlet, MetricTemperature, *data.RawACOutletTemperature)
+		data.ACOutletTemperature = &value
+	}
+	if data.RawACOutletHumidity != nil {
+		value := calibrate(SensorACOutlet, MetricHumidity, *data.RawACOutletHumidity)
+		data.ACOutletHumidity = &value
+	}
+}
+
+// RecalibrateRange recomputes calibrated values from the stored raw values
+// for a historical time range and re-runs quality detection on the result,
+// in one transaction so values and flags never disagree. Manually edited
+// readings are left untouched.
+func (db *Database) RecalibrateRange(ctx context.Context, startTime, endTime time.Time) (int64, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryBulk)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	updated, err := recalibrate(ctx, tx, startTime, endTime)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+// recalibrate recomputes the calibrated values of a time range from the raw
+// values and re-runs quality detection on the result
+func recalibrate(ctx context.Context, exec execer, startTime, endTime time.Time) (int64, error) {
+	query := `
+	UPDATE temp_sensor_data SET 
+		temperature = apply_sensor_calibration(raw_temperature, $3, $5, timestamp),
+		humidity = apply_sensor_calibration(raw_humidity, $3, $6, timestamp),
+		ac_outlet_temperature = apply_sensor_calibration(raw_ac_outlet_temperature, $4, $5, timestamp),
+		ac_outlet_humidity = apply_sensor_calibration(raw_ac_outlet_humidity, $4, $6, timestamp)
+	WHERE timestamp >= $1 AND timestamp <= $2 
+	AND raw_temperature IS NOT NULL AND raw_humidity IS NOT NULL 
+	AND NOT ($7 = ANY(quality_flags))`
+
+	result, err := exec.ExecContext(ctx, query, dbTime(startTime), dbTime(endTime), SensorMain, SensorACOutlet, MetricTemperature, MetricHumidity, QualityFlagManualEdit)
+	if err != nil {
+		return 0, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := redetectQualityFlags(ctx, exec, startTime, endTime); err != nil {
+		return 0, fmt.Errorf("failed to re-run quality detection: %w", err)
+	}
+
+	return updated, nil
+}
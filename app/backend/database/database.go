@@ -1,16 +1,18 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type Database struct {
 	*sql.DB
+	timeouts QueryTimeouts
 }
 
 func NewDatabase(dataSourceName string) (*Database, error) {
@@ -23,7 +25,7 @@ func NewDatabase(dataSourceName string) (*Database, error) {
 		return nil, err
 	}
 
-	return &Database{db}, nil
+	return &Database{DB: db, timeouts: DefaultQueryTimeouts()}, nil
 }
 
 // CreateTables is deprecated - use migrations instead
@@ -33,24 +35,70 @@ func (db *Database) CreateTables() error {
 	return nil
 }
 
-func (db *Database) InsertTempSensorData(data *TempSensorData) error {
+// tempSensorDataColumns is the column list read by scanTempSensorData
+const tempSensorDataColumns = `id, temperature, humidity, ac_outlet_temperature, ac_outlet_humidity, timestamp, quality_flags, raw_temperature, raw_humidity, raw_ac_outlet_temperature, raw_ac_outlet_humidity`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTempSensorData(row rowScanner, item *TempSensorData) error {
+	err := row.Scan(&item.ID, &item.Temperature, &item.Humidity, &item.ACOutletTemperature, &item.ACOutletHumidity, &item.Timestamp,
+		pq.Array(&item.QualityFlags), &item.RawTemperature, &item.RawHumidity, &item.RawACOutletTemperature, &item.RawACOutletHumidity)
+	item.Timestamp = localTime(item.Timestamp)
+	return err
+}
+
+// temp_sensor_data.timestamp is TIMESTAMP without time zone holding the
+// server's local wall clock, and PostgreSQL drops the offset of a parameter.
+// dbTime turns a bound into that wall clock; localTime reads a scanned value
+// (the wall clock, labelled UTC by lib/pq) back as the instant it stands for.
+func dbTime(t time.Time) time.Time {
+	return t.In(time.Local)
+}
+
+func localTime(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+}
+
+func (db *Database) InsertTempSensorData(ctx context.Context, data *TempSensorData) error {
+	ctx, cancel := db.withTimeout(ctx, QueryWrite)
+	defer cancel()
+
+	// Calibration is applied to the incoming raw values; the raw values are kept
+	if err := db.applyCalibration(ctx, data); err != nil {
+		return fmt.Errorf("failed to apply calibration: %w", err)
+	}
+
+	// Quality flags are decided once, when the reading is written
+	detectQualityFlags(data, time.Now())
+
 	query := `
-	INSERT INTO temp_sensor_data (temperature, humidity, ac_outlet_temperature, ac_outlet_humidity, timestamp) 
-	VALUES ($1, $2, $3, $4, $5) 
+	INSERT INTO temp_sensor_data (temperature, humidity, ac_outlet_temperature, ac_outlet_humidity, timestamp, quality_flags,
+		raw_temperature, raw_humidity, raw_ac_outlet_temperature, raw_ac_outlet_humidity) 
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) 
 	RETURNING id`
 
-	err := db.QueryRow(query, data.Temperature, data.Humidity, data.ACOutletTemperature, data.ACOutletHumidity, data.Timestamp).Scan(&data.ID)
+	err := db.QueryRowContext(ctx, query, data.Temperature, data.Humidity, data.ACOutletTemperature, data.ACOutletHumidity, data.Timestamp, pq.Array(data.QualityFlags),
+		data.RawTemperature, data.RawHumidity, data.RawACOutletTemperature, data.RawACOutletHumidity).Scan(&data.ID)
 	return err
 }
 
-func (db *Database) GetTempSensorData(limit, offset int) ([]TempSensorData, error) {
+func (db *Database) GetTempSensorData(ctx context.Context, limit, offset int, filter QualityFilter) ([]TempSensorData, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryRead)
+	defer cancel()
+
+	condition, args := filter.sqlCondition(3)
 	query := `
-	SELECT id, temperature, humidity, ac_outlet_temperature, ac_outlet_humidity, timestamp 
+	SELECT ` + tempSensorDataColumns + ` 
 	FROM temp_sensor_data 
+	WHERE TRUE` + condition + `
 	ORDER BY timestamp DESC 
 	LIMIT $1 OFFSET $2`
 
-	rows, err := db.Query(query, limit, offset)
+	rows, err := db.QueryContext(ctx, query, append([]interface{}{limit, offset}, args...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -59,41 +107,55 @@ func (db *Database) GetTempSensorData(limit, offset int) ([]TempSensorData, erro
 	var data []TempSensorData
 	for rows.Next() {
 		var item TempSensorData
-		err := rows.Scan(&item.ID, &item.Temperature, &item.Humidity, &item.ACOutletTemperature, &item.ACOutletHumidity, &item.Timestamp)
+		err := scanTempSensorData(rows, &item)
 		if err != nil {
 			return nil, err
 		}
 		data = append(data, item)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	data = markOutliers(data)
+	data = addDerivedMetrics(data)
 
 	return data, nil
 }
 
-func (db *Database) GetLatestTempSensorData() (*TempSensorData, error) {
+func (db *Database) GetLatestTempSensorData(ctx context.Context) (*TempSensorData, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryRead)
+	defer cancel()
+
 	query := `
-	SELECT id, temperature, humidity, ac_outlet_temperature, ac_outlet_humidity, timestamp 
+	SELECT ` + tempSensorDataColumns + ` 
 	FROM temp_sensor_data 
 	ORDER BY timestamp DESC 
 	LIMIT 1`
 
 	var data TempSensorData
-	err := db.QueryRow(query).Scan(&data.ID, &data.Temperature, &data.Humidity, &data.ACOutletTemperature, &data.ACOutletHumidity, &data.Timestamp)
+	err := scanTempSensorData(db.QueryRowContext(ctx, query), &data)
 	if err != nil {
 		return nil, err
 	}
 
-	// Mark outlier status
-	data.IsOutlier = isTemperatureOutlier(data.Temperature)
+	// Mark outlier status and derived metrics
+	data.IsOutlier = hasQualityFlag(data.QualityFlags, QualityFlagOutlier)
+	data.Derived = CalculateDerivedMetrics(data.Temperature, data.Humidity)
+	if data.ACOutletTemperature != nil && data.ACOutletHumidity != nil {
+		data.ACOutletDerived = CalculateDerivedMetrics(*data.ACOutletTemperature, *data.ACOutletHumidity)
+	}
 
 	return &data, nil
 }
 
-func (db *Database) GetTempSensorDataWithTerm(limit, term int) ([]TempSensorData, error) {
+func (db *Database) GetTempSensorDataWithTerm(ctx context.Context, limit, term int, filter QualityFilter) ([]TempSensorData, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryRead)
+	defer cancel()
+
 	var latestID int
 	latestQuery := `SELECT id FROM temp_sensor_data ORDER BY timestamp DESC LIMIT 1`
-	err := db.QueryRow(latestQuery).Scan(&latestID)
+	err := db.QueryRowContext(ctx, latestQuery).Scan(&latestID)
 	if err != nil {
 		return nil, err
 	}
@@ -117,13 +179,16 @@ func (db *Database) GetTempSensorDataWithTerm(limit, term int) ([]TempSensorData
 		args[i] = id
 	}
 
+	condition, filterArgs := filter.sqlCondition(len(args) + 1)
+	args = append(args, filterArgs...)
+
 	query := fmt.Sprintf(`
-	SELECT id, temperature, humidity, ac_outlet_temperature, ac_outlet_humidity, timestamp 
+	SELECT `+tempSensorDataColumns+` 
 	FROM temp_sensor_data 
-	WHERE id IN (%s)
-	ORDER BY timestamp DESC`, strings.Join(placeholders, ","))
+	WHERE id IN (%s)%s
+	ORDER BY timestamp DESC`, strings.Join(placeholders, ","), condition)
 
-	rows, err := db.Query(query, args...)
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -132,28 +197,36 @@ func (db *Database) GetTempSensorDataWithTerm(limit, term int) ([]TempSensorData
 	var data []TempSensorData
 	for rows.Next() {
 		var item TempSensorData
-		err := rows.Scan(&item.ID, &item.Temperature, &item.Humidity, &item.Timestamp)
+		err := scanTempSensorData(rows, &item)
 		if err != nil {
 			return nil, err
 		}
 		data = append(data, item)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	data = markOutliers(data)
+	data = addDerivedMetrics(data)
 
 	return data, nil
 }
 
 // GetTempSensorDataByTimeRange retrieves temperature sensor data within a specific time range
-func (db *Database) GetTempSensorDataByTimeRange(startTime, endTime time.Time, limit int) ([]TempSensorData, error) {
+func (db *Database) GetTempSensorDataByTimeRange(ctx context.Context, startTime, endTime time.Time, limit int, filter QualityFilter) ([]TempSensorData, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryRead)
+	defer cancel()
+
+	condition, args := filter.sqlCondition(4)
 	query := `
-	SELECT id, temperature, humidity, ac_outlet_temperature, ac_outlet_humidity, timestamp 
+	SELECT ` + tempSensorDataColumns + ` 
 	FROM temp_sensor_data 
-	WHERE timestamp >= $1 AND timestamp <= $2 
+	WHERE timestamp >= $1 AND timestamp <= $2` + condition + ` 
 	ORDER BY timestamp DESC 
 	LIMIT $3`
 
-	rows, err := db.Query(query, startTime, endTime, limit)
+	rows, err := db.QueryContext(ctx, query, append([]interface{}{dbTime(startTime), dbTime(endTime), limit}, args...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -162,44 +235,33 @@ func (db *Database) GetTempSensorDataByTimeRange(startTime, endTime time.Time, l
 	var data []TempSensorData
 	for rows.Next() {
 		var item TempSensorData
-		err := rows.Scan(&item.ID, &item.Temperature, &item.Humidity, &item.ACOutletTemperature, &item.ACOutletHumidity, &item.Timestamp)
+		err := scanTempSensorData(rows, &item)
 		if err != nil {
 			return nil, err
 		}
 		data = append(data, item)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	// Mark outliers before returning
+	// Mark outliers and derived metrics before returning
 	data = markOutliers(data)
+	data = addDerivedMetrics(data)
 
 	return data, nil
 }
 
 // GetTempSensorDataWithTimeIntervals returns exactly 'limit' data points sampled evenly across the time range
-func (db *Database) GetTempSensorDataWithTimeIntervals(startTime, endTime time.Time, limit int) ([]TempSensorData, error) {
-	// First, get all data in the time range for sampling
-	query := `
-	SELECT id, temperature, humidity, ac_outlet_temperature, ac_outlet_humidity, timestamp 
-	FROM temp_sensor_data 
-	WHERE timestamp >= $1 AND timestamp <= $2 
-	ORDER BY timestamp ASC`
+func (db *Database) GetTempSensorDataWithTimeIntervals(ctx context.Context, startTime, endTime time.Time, limit int, filter QualityFilter) ([]TempSensorData, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryAggregate)
+	defer cancel()
 
-	rows, err := db.Query(query, startTime, endTime)
+	// First, get all data in the time range for sampling
+	allData, err := db.getTempSensorDataInRange(ctx, startTime, endTime, filter)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	// Load all available data into memory for efficient lookup
-	var allData []TempSensorData
-	for rows.Next() {
-		var item TempSensorData
-		err := rows.Scan(&item.ID, &item.Temperature, &item.Humidity, &item.ACOutletTemperature, &item.ACOutletHumidity, &item.Timestamp)
-		if err != nil {
-			return nil, err
-		}
-		allData = append(allData, item)
-	}
 
 	if len(allData) == 0 {
 		// No data available, create empty slots across the entire time range
@@ -213,7 +275,7 @@ func (db *Database) GetTempSensorDataWithTimeIntervals(startTime, endTime time.T
 
 	if limit == 1 {
 		// Special case: only one point requested, return the most recent
-		return []TempSensorData{mostRecentData}, nil
+		return addDerivedMetrics(markOutliers([]TempSensorData{mostRecentData})), nil
 	}
 
 	// Calculate time slots for the first (limit-1) points across the time range
@@ -256,10 +318,11 @@ func (db *Database) GetTempSensorDataWithTimeIntervals(startTime, endTime time.T
 		} else {
 			// No data found for this time slot, create null entry
 			result = append(result, TempSensorData{
-				ID:          0, // Use 0 to indicate null entry
+				ID:          0, // Use 0 to indicate null entry (v1 responses)
 				Temperature: 0, // Will be handled as null in frontend
 				Humidity:    0, // Will be handled as null in frontend
 				Timestamp:   targetTime,
+				Gap:         true,
 			})
 		}
 	}
@@ -268,10 +331,39 @@ func (db *Database) GetTempSensorDataWithTimeIntervals(startTime, endTime time.T
 	result = append(result, mostRecentData)
 
 	result = markOutliers(result)
+	result = addDerivedMetrics(result)
 
 	return result, nil
 }
 
+// getTempSensorDataInRange loads every reading in [startTime, endTime], oldest first
+func (db *Database) getTempSensorDataInRange(ctx context.Context, startTime, endTime time.Time, filter QualityFilter) ([]TempSensorData, error) {
+	condition, args := filter.sqlCondition(3)
+	query := `
+	SELECT ` + tempSensorDataColumns + ` 
+	FROM temp_sensor_data 
+	WHERE timestamp >= $1 AND timestamp <= $2` + condition + ` 
+	ORDER BY timestamp ASC`
+
+	rows, err := db.QueryContext(ctx, query, append([]interface{}{dbTime(startTime), dbTime(endTime)}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Load all available data into memory for efficient lookup
+	var allData []TempSensorData
+	for rows.Next() {
+		var item TempSensorData
+		err := scanTempSensorData(rows, &item)
+		if err != nil {
+			return nil, err
+		}
+		allData = append(allData, item)
+	}
+	return allData, rows.Err()
+}
+
 // Helper function to create empty time slots when no data is available
 func createEmptyTimeSlots(startTime, endTime time.Time, limit int) []TempSensorData {
 	result := make([]TempSensorData, 0, limit)
@@ -281,29 +373,17 @@ func createEmptyTimeSlots(startTime, endTime time.Time, limit int) []TempSensorD
 	for i := 0; i < limit; i++ {
 		targetTime := startTime.Add(time.Duration(i) * slotDuration)
 		result = append(result, TempSensorData{
-			ID:          0, // Use 0 to indicate null entry
+			ID:          0, // Use 0 to indicate null entry (v1 responses)
 			Temperature: 0, // Will be handled as null in frontend
 			Humidity:    0, // Will be handled as null in frontend
 			Timestamp:   targetTime,
+			Gap:         true,
 		})
 	}
 
 	return result
 }
 
-// 이상치는 temp가 3도 이내일 때의 온습도
-// dht22자체문제인거같기도
-func isTemperatureOutlier(temperature float64) bool {
-	return temperature <= 3.0
-}
-
-func markOutliers(data []TempSensorData) []TempSensorData {
-	for i := range data {
-		data[i].IsOutlier = isTemperatureOutlier(data[i].Temperature)
-	}
-	return data
-}
-
 func filterOutliers(values []float64, isOutlier []bool) []float64 {
 	var filtered []float64
 	for i, val := range values {
@@ -315,20 +395,39 @@ func filterOutliers(values []float64, isOutlier []bool) []float64 {
 }
 
 // GetDataCountInTimeRange returns the number of records in a time range
-func (db *Database) GetDataCountInTimeRange(startTime, endTime time.Time) (int, error) {
+func (db *Database) GetDataCountInTimeRange(ctx context.Context, startTime, endTime time.Time, filter QualityFilter) (int, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryRead)
+	defer cancel()
+
+	condition, args := filter.sqlCondition(3)
 	query := `
 	SELECT COUNT(*) 
 	FROM temp_sensor_data 
-	WHERE timestamp >= $1 AND timestamp <= $2`
+	WHERE timestamp >= $1 AND timestamp <= $2` + condition
 
 	var count int
-	err := db.QueryRow(query, startTime, endTime).Scan(&count)
+	err := db.QueryRowContext(ctx, query, append([]interface{}{dbTime(startTime), dbTime(endTime)}, args...)...).Scan(&count)
 	return count, err
 }
 
+// DeleteTempSensorDataBefore deletes readings older than cutoff (retention)
+func (db *Database) DeleteTempSensorDataBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryBulk)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, `DELETE FROM temp_sensor_data WHERE timestamp < $1`, dbTime(cutoff))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // GetTempSensorDataWithAggregation enhances data points with aggregated values
 // calculated from surrounding ±windowSize data points
-func (db *Database) GetTempSensorDataWithAggregation(baseData []TempSensorData, windowSize int) ([]TempSensorData, error) {
+func (db *Database) GetTempSensorDataWithAggregation(ctx context.Context, baseData []TempSensorData, windowSize int) ([]TempSensorData, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryAggregate)
+	defer cancel()
+
 	if len(baseData) == 0 {
 		return baseData, nil
 	}
@@ -341,8 +440,16 @@ func (db *Database) GetTempSensorDataWithAggregation(baseData []TempSensorData,
 
 	// For each data point, calculate both default (±3) and configurable aggregated values
 	for i := range result {
+		// Gaps have no neighbouring rows of their own
+		if result[i].Gap {
+			continue
+		}
+
 		// Always calculate default aggregated values (±3 window) for main display
-		defaultAggregated, err := db.calculateDefaultAggregatesForDataPoint(result[i])
+		defaultAggregated, err := db.calculateDefaultAggregatesForDataPoint(ctx, result[i])
+		if ctx.Err() != nil {
+			return nil, ctx.Err() // Timed out or the client is gone; the other points would fail too
+		}
 		if err != nil {
 			// Log error but continue with other points
 			continue
@@ -351,7 +458,10 @@ func (db *Database) GetTempSensorDataWithAggregation(baseData []TempSensorData,
 
 		// Calculate configurable aggregated values only if windowSize > 0
 		if windowSize > 0 {
-			aggregated, err := db.calculateAggregatesForDataPoint(result[i], windowSize)
+			aggregated, err := db.calculateAggregatesForDataPoint(ctx, result[i], windowSize)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			if err != nil {
 				// Log error but continue with other points
 				continue
@@ -364,7 +474,7 @@ func (db *Database) GetTempSensorDataWithAggregation(baseData []TempSensorData,
 }
 
 // calculateDefaultAggregatesForDataPoint calculates avg for main display using ±3 window
-func (db *Database) calculateDefaultAggregatesForDataPoint(dataPoint TempSensorData) (*DefaultAggregatedValues, error) {
+func (db *Database) calculateDefaultAggregatesForDataPoint(ctx context.Context, dataPoint TempSensorData) (*DefaultAggregatedValues, error) {
 	// Get surrounding data points based on ID range (±3 from current point)
 	startID := dataPoint.ID - 3
 	endID := dataPoint.ID + 3
@@ -375,12 +485,12 @@ func (db *Database) calculateDefaultAggregatesForDataPoint(dataPoint TempSensorD
 	}
 
 	query := `
-	SELECT temperature, humidity 
+	SELECT temperature, humidity, quality_flags 
 	FROM temp_sensor_data 
 	WHERE id >= $1 AND id <= $2 
 	ORDER BY id ASC`
 
-	rows, err := db.Query(query, startID, endID)
+	rows, err := db.QueryContext(ctx, query, startID, endID)
 	if err != nil {
 		return nil, err
 	}
@@ -393,14 +503,19 @@ func (db *Database) calculateDefaultAggregatesForDataPoint(dataPoint TempSensorD
 
 	for rows.Next() {
 		var temp, hum float64
-		err := rows.Scan(&temp, &hum)
+		var flags []string
+		err := rows.Scan(&temp, &hum, pq.Array(&flags))
 		if err != nil {
 			return nil, err
 		}
+		outlier := hasQualityFlag(flags, QualityFlagOutlier)
 		temperatures = append(temperatures, temp)
 		humidities = append(humidities, hum)
-		tempOutliers = append(tempOutliers, isTemperatureOutlier(temp))
-		humOutliers = append(humOutliers, isTemperatureOutlier(temp)) // Use same outlier logic for humidity
+		tempOutliers = append(tempOutliers, outlier)
+		humOutliers = append(humOutliers, outlier) // Use same outlier flag for humidity
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
 	if len(temperatures) == 0 {
@@ -421,7 +536,7 @@ func (db *Database) calculateDefaultAggregatesForDataPoint(dataPoint TempSensorD
 
 // calculateAggregatesForDataPoint calculates avg/max/min for a single data point
 // using ±windowSize surrounding data points
-func (db *Database) calculateAggregatesForDataPoint(dataPoint TempSensorData, windowSize int) (*AggregatedValues, error) {
+func (db *Database) calculateAggregatesForDataPoint(ctx context.Context, dataPoint TempSensorData, windowSize int) (*AggregatedValues, error) {
 	// Get surrounding data points based on ID range (±windowSize from current point)
 	startID := dataPoint.ID - windowSize
 	endID := dataPoint.ID + windowSize
@@ -432,12 +547,12 @@ func (db *Database) calculateAggregatesForDataPoint(dataPoint TempSensorData, wi
 	}
 
 	query := `
-	SELECT temperature, humidity 
+	SELECT temperature, humidity, ac_outlet_temperature, ac_outlet_humidity, quality_flags 
 	FROM temp_sensor_data 
 	WHERE id >= $1 AND id <= $2 
 	ORDER BY id ASC`
 
-	rows, err := db.Query(query, startID, endID)
+	rows, err := db.QueryContext(ctx, query, startID, endID)
 	if err != nil {
 		return nil, err
 	}
@@ -447,17 +562,28 @@ func (db *Database) calculateAggregatesForDataPoint(dataPoint TempSensorData, wi
 	var humidities []float64
 	var tempOutliers []bool
 	var humOutliers []bool
+	var acTemperatures, acHumidities []float64 // Pairs of non-outlier readings with both values
 
 	for rows.Next() {
 		var temp, hum float64
-		err := rows.Scan(&temp, &hum)
+		var acTemp, acHum sql.NullFloat64
+		var flags []string
+		err := rows.Scan(&temp, &hum, &acTemp, &acHum, pq.Array(&flags))
 		if err != nil {
 			return nil, err
 		}
+		outlier := hasQualityFlag(flags, QualityFlagOutlier)
 		temperatures = append(temperatures, temp)
 		humidities = append(humidities, hum)
-		tempOutliers = append(tempOutliers, isTemperatureOutlier(temp))
-		humOutliers = append(humOutliers, isTemperatureOutlier(temp)) // Use same outlier logic for humidity
+		tempOutliers = append(tempOutliers, outlier)
+		humOutliers = append(humOutliers, outlier) // Use same outlier flag for humidity
+		if !outlier && acTemp.Valid && acHum.Valid {
+			acTemperatures = append(acTemperatures, acTemp.Float64)
+			acHumidities = append(acHumidities, acHum.Float64)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
 	if len(temperatures) == 0 {
@@ -470,7 +596,7 @@ func (db *Database) calculateAggregatesForDataPoint(dataPoint TempSensorData, wi
 	tempAgg := calculateStatsForSlice(filteredTemps)
 	humAgg := calculateStatsForSlice(filteredHums)
 
-	return &AggregatedValues{
+	aggregated := &AggregatedValues{
 		Temperature: &TemperatureAggregates{
 			Average: tempAgg.Average,
 			Maximum: tempAgg.Maximum,
@@ -483,7 +609,47 @@ func (db *Database) calculateAggregatesForDataPoint(dataPoint TempSensorData, wi
 			Minimum: humAgg.Minimum,
 			Count:   humAgg.Count,
 		},
-	}, nil
+	}
+
+	// Derived metric series from the same (outlier-filtered) temperature/humidity pairs
+	aggregated.DewPoint, aggregated.AbsoluteHumidity, aggregated.HeatIndex, aggregated.Humidex = derivedAggregates(filteredTemps, filteredHums)
+	aggregated.ACOutletDewPoint, aggregated.ACOutletAbsoluteHumidity, aggregated.ACOutletHeatIndex, aggregated.ACOutletHumidex = derivedAggregates(acTemperatures, acHumidities)
+
+	return aggregated, nil
+}
+
+// derivedAggregates aggregates the derived metrics of temperature/humidity
+// pairs; a metric is nil when no pair yields it
+func derivedAggregates(temperatures, humidities []float64) (dewPoint, absoluteHumidity, heatIndex, humidex *MetricAggregates) {
+	var dewPoints, absoluteHumidities, heatIndexes, humidexes []float64
+	for i := range temperatures {
+		if i >= len(humidities) {
+			break
+		}
+		derived := CalculateDerivedMetrics(temperatures[i], humidities[i])
+		if derived == nil {
+			continue
+		}
+		dewPoints = append(dewPoints, derived.DewPoint)
+		absoluteHumidities = append(absoluteHumidities, derived.AbsoluteHumidity)
+		heatIndexes = append(heatIndexes, derived.HeatIndex)
+		humidexes = append(humidexes, derived.Humidex)
+	}
+	return metricAggregates(dewPoints), metricAggregates(absoluteHumidities), metricAggregates(heatIndexes), metricAggregates(humidexes)
+}
+
+// metricAggregates converts a derived series to MetricAggregates, nil if empty
+func metricAggregates(values []float64) *MetricAggregates {
+	if len(values) == 0 {
+		return nil
+	}
+	stats := calculateStatsForSlice(values)
+	return &MetricAggregates{
+		Average: stats.Average,
+		Maximum: stats.Maximum,
+		Minimum: stats.Minimum,
+		Count:   stats.Count,
+	}
 }
 
 // Helper struct for statistical calculations
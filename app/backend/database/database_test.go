@@ -0,0 +1,125 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// setLocal runs the rest of the test as a server whose local time zone is name
+func setLocal(t *testing.T, name string) {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = previous })
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// storeAndScan stands in for a TIMESTAMP column: lib/pq sends the value with
+// its offset, PostgreSQL keeps only the wall clock and lib/pq scans it back
+// without a zone
+func storeAndScan(t *testing.T, value time.Time) time.Time {
+	t.Helper()
+	wallClock := string(pq.FormatTimestamp(value))[:len("2006-01-02 15:04:05")]
+	scanned, err := pq.ParseTimestamp(nil, wallClock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scanned
+}
+
+func TestTimestampRoundTrip(t *testing.T) {
+	seoul := mustLoadLocation(t, "Asia/Seoul")
+	bound := time.Date(2025, 1, 14, 0, 0, 0, 0, seoul)
+
+	for _, server := range []string{"UTC", "America/New_York", "Asia/Seoul"} {
+		t.Run(server, func(t *testing.T) {
+			setLocal(t, server)
+
+			// A reading stored at the bound's instant is found by the bound
+			if got := localTime(storeAndScan(t, dbTime(bound))); !got.Equal(bound) {
+				t.Errorf("round trip of %v = %v", bound, got)
+			}
+			// Without dbTime the offset of the requested timezone is lost
+			if server != "Asia/Seoul" && localTime(storeAndScan(t, bound)).Equal(bound) {
+				t.Errorf("storing %v without dbTime should move it", bound)
+			}
+		})
+	}
+}
+
+// Readings of a Seoul day on a UTC server fall into every local-midnight bucket
+func TestAlignedBucketsMatchStoredReadings(t *testing.T) {
+	setLocal(t, "UTC")
+	seoul := mustLoadLocation(t, "Asia/Seoul")
+	start := time.Date(2025, 1, 14, 0, 0, 0, 0, seoul)
+	end := start.AddDate(0, 0, 1)
+
+	buckets, label := AlignedBuckets(start, end, seoul, 24)
+	if label != "1h" || len(buckets) != 24 {
+		t.Fatalf("AlignedBuckets = %d buckets of %s, want 24 of 1h", len(buckets), label)
+	}
+
+	// One reading 10 minutes into every hour, as collected by the server
+	var data []TempSensorData
+	for hour := 0; hour < 24; hour++ {
+		collected := start.Add(time.Duration(hour)*time.Hour + 10*time.Minute).In(time.Local)
+		data = append(data, TempSensorData{Timestamp: localTime(storeAndScan(t, dbTime(collected)))})
+	}
+
+	var empty int
+	eachBucket(data, buckets, func(bucket Bucket, readings []TempSensorData) {
+		if len(readings) != 1 {
+			empty++
+			t.Errorf("bucket %v has %d readings, want 1", bucket.Start, len(readings))
+		}
+	})
+	if empty > 0 {
+		t.Errorf("%d of 24 buckets do not hold their reading", empty)
+	}
+}
+
+// A calibration applies from its effective instant on every server, to readings
+// stored the same way
+func TestCalibrationEffectiveFromRoundTrip(t *testing.T) {
+	effective := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, server := range []string{"UTC", "America/New_York", "Asia/Seoul"} {
+		t.Run(server, func(t *testing.T) {
+			setLocal(t, server)
+
+			stored := localTime(storeAndScan(t, dbTime(effective)))
+			if !stored.Equal(effective) {
+				t.Fatalf("effective_from round trip of %v = %v", effective, stored)
+			}
+
+			history := []SensorCalibration{{Sensor: SensorMain, Metric: MetricTemperature, Offset: -1, Slope: 1, EffectiveFrom: stored}}
+			for _, tt := range []struct {
+				reading time.Time
+				want    bool
+			}{
+				{reading: effective.Add(-time.Minute), want: false},
+				{reading: effective, want: true},
+				{reading: effective.Add(time.Minute), want: true},
+			} {
+				at := localTime(storeAndScan(t, dbTime(tt.reading)))
+				if _, got := activeCalibrationsAt(history, at)[SensorMain+"/"+MetricTemperature]; got != tt.want {
+					t.Errorf("calibration active for a reading at %v = %v, want %v", tt.reading, got, tt.want)
+				}
+			}
+		})
+	}
+}
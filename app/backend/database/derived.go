@@ -0,0 +1,120 @@
+package database
+
+import "math"
+
+// Magnus formula coefficients (Sonntag 1990, valid for -45°C..60°C over water)
+const (
+	magnusA = 17.62
+	magnusB = 243.12
+)
+
+// CalculateDerivedMetrics computes psychrometric metrics from temperature (°C)
+// and relative humidity (%). Returns nil when humidity is out of range.
+func CalculateDerivedMetrics(temperature, humidity float64) *DerivedMetrics {
+	if humidity <= 0 || humidity > 100 {
+		return nil
+	}
+
+	dewPoint := calculateDewPoint(temperature, humidity)
+
+	return &DerivedMetrics{
+		DewPoint:         dewPoint,
+		AbsoluteHumidity: calculateAbsoluteHumidity(temperature, humidity),
+		HeatIndex:        calculateHeatIndex(temperature, humidity),
+		Humidex:          calculateHumidex(temperature, dewPoint),
+	}
+}
+
+// calculateDewPoint returns the dew point in °C
+func calculateDewPoint(temperature, humidity float64) float64 {
+	gamma := math.Log(humidity/100) + magnusA*temperature/(magnusB+temperature)
+	return magnusB * gamma / (magnusA - gamma)
+}
+
+// calculateAbsoluteHumidity returns water vapour density in g/m³
+func calculateAbsoluteHumidity(temperature, humidity float64) float64 {
+	saturationPressure := 6.112 * math.Exp(magnusA*temperature/(magnusB+temperature)) // hPa
+	return saturationPressure * humidity * 2.1674 / (273.15 + temperature)
+}
+
+// calculateHeatIndex returns the NOAA heat index (Rothfusz regression) in °C
+func calculateHeatIndex(temperature, humidity float64) float64 {
+	f := temperature*9/5 + 32
+
+	// Steadman's simple formula is used below ~80°F
+	hi := 0.5 * (f + 61.0 + (f-68.0)*1.2 + humidity*0.094)
+	if (hi+f)/2 >= 80 {
+		hi = -42.379 + 2.04901523*f + 10.14333127*humidity -
+			0.22475541*f*humidity - 0.00683783*f*f - 0.05481717*humidity*humidity +
+			0.00122874*f*f*humidity + 0.00085282*f*humidity*humidity -
+			0.00000199*f*f*humidity*humidity
+
+		if humidity < 13 && f >= 80 && f <= 112 {
+			hi -= ((13 - humidity) / 4) * math.Sqrt((17-math.Abs(f-95))/17)
+		} else if humidity > 85 && f >= 80 && f <= 87 {
+			hi += ((humidity - 85) / 10) * ((87 - f) / 5)
+		}
+	}
+
+	return (hi - 32) * 5 / 9
+}
+
+// calculateHumidex returns the Canadian humidex from temperature and dew point (°C)
+func calculateHumidex(temperature, dewPoint float64) float64 {
+	vapourPressure := 6.11 * math.Exp(5417.7530*(1/273.16-1/(273.15+dewPoint)))
+	return temperature + 0.5555*(vapourPressure-10)
+}
+
+// addDerivedMetrics attaches derived metrics for the main and AC outlet sensors.
+// Empty time slots are left without derived values.
+func addDerivedMetrics(data []TempSensorData) []TempSensorData {
+	for i := range data {
+		if data[i].Gap {
+			continue
+		}
+		data[i].Derived = CalculateDerivedMetrics(data[i].Temperature, data[i].Humidity)
+		if data[i].ACOutletTemperature != nil && data[i].ACOutletHumidity != nil {
+			data[i].ACOutletDerived = CalculateDerivedMetrics(*data[i].ACOutletTemperature, *data[i].ACOutletHumidity)
+		}
+	}
+	return data
+}
+
+// MetricValue returns the value of a (possibly derived) metric of a sensor
+func MetricValue(data *TempSensorData, sensor, metric string) (float64, bool) {
+	var temperature, humidity float64
+	switch sensor {
+	case SensorMain:
+		temperature, humidity = data.Temperature, data.Humidity
+	case SensorACOutlet:
+		if data.ACOutletTemperature == nil || data.ACOutletHumidity == nil {
+			return 0, false
+		}
+		temperature, humidity = *data.ACOutletTemperature, *data.ACOutletHumidity
+	default:
+		return 0, false
+	}
+
+	switch metric {
+	case MetricTemperature:
+		return temperature, true
+	case MetricHumidity:
+		return humidity, true
+	}
+
+	derived := CalculateDerivedMetrics(temperature, humidity)
+	if derived == nil {
+		return 0, false
+	}
+	switch metric {
+	case MetricDewPoint:
+		return derived.DewPoint, true
+	case MetricAbsoluteHumidity:
+		return derived.AbsoluteHumidity, true
+	case MetricHeatIndex:
+		return derived.HeatIndex, true
+	case MetricHumidex:
+		return derived.Humidex, true
+	}
+	return 0, false
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Downsample modes of sampled history
+const (
+	DownsampleNearest = "nearest" // One reading per time slot (default)
+	DownsampleLTTB    = "lttb"    // Largest-Triangle-Three-Buckets: keeps the visual shape, including short spikes
+	DownsampleMinMax  = "minmax"  // Lowest and highest reading of every bucket: an envelope that keeps every peak
+)
+
+// ParseDownsample parses the downsample parameter, defaulting to DownsampleNearest
+func ParseDownsample(value string) (string, error) {
+	switch value {
+	case "":
+		return DownsampleNearest, nil
+	case DownsampleNearest, DownsampleLTTB, DownsampleMinMax:
+		return value, nil
+	}
+	return "", fmt.Errorf("unknown downsample mode %q", value)
+}
+
+// ParseDownsampleMetric parses the metric LTTB and min/max select points by,
+// one of AggregateMetrics, defaulting to temperature
+func ParseDownsampleMetric(value string) (string, error) {
+	if value == "" {
+		return MetricTemperature, nil
+	}
+	if !containsString(AggregateMetrics, value) {
+		return "", fmt.Errorf("unknown downsample metric %q", value)
+	}
+	return value, nil
+}
+
+// GetTempSensorDataLTTB reduces the readings of [startTime, endTime] to limit
+// readings with Largest-Triangle-Three-Buckets over metric. The first and last
+// reading are always kept. Readings without the metric (e.g. when the AC
+// outlet sensor failed) are skipped. There are no gap slots.
+func (db *Database) GetTempSensorDataLTTB(ctx context.Context, startTime, endTime time.Time, limit int, filter QualityFilter, metric string) ([]TempSensorData, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryAggregate)
+	defer cancel()
+
+	allData, err := db.getTempSensorDataInRange(ctx, startTime, endTime, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := lttbSample(allData, limit, metric)
+	result = markOutliers(result)
+	result = addDerivedMetrics(result)
+
+	return result, nil
+}
+
+// lttbSample picks limit readings of data (oldest first) with LTTB over metric,
+// skipping readings without it
+func lttbSample(data []TempSensorData, limit int, metric string) []TempSensorData {
+	sensor, name := aggregateMetricSource(metric)
+	var readings []TempSensorData
+	var xs, ys []float64
+	for i := range data {
+		if value, ok := MetricValue(&data[i], sensor, name); ok {
+			readings = append(readings, data[i])
+			xs = append(xs, float64(data[i].Timestamp.UnixMilli()))
+			ys = append(ys, value)
+		}
+	}
+
+	result := make([]TempSensorData, 0, limit)
+	for _, i := range lttbIndexes(xs, ys, limit) {
+		result = append(result, readings[i])
+	}
+	return result
+}
+
+// lttbIndexes returns the indexes of the threshold points LTTB keeps
+func lttbIndexes(xs, ys []float64, threshold int) []int {
+	n := len(xs)
+	if threshold >= n {
+		indexes := make([]int, n)
+		for i := range indexes {
+			indexes[i] = i
+		}
+		return indexes
+	}
+	switch threshold {
+	case 0:
+		return nil
+	case 1:
+		return []int{n - 1} // The most recent reading, like the nearest-point sampling
+	case 2:
+		return []int{0, n - 1}
+	}
+
+	// The first and last points are fixed; the rest are split into threshold-2 buckets
+	indexes := make([]int, 0, threshold)
+	indexes = append(indexes, 0)
+	every := float64(n-2) / float64(threshold-2)
+	selected := 0
+
+	for bucket := 0; bucket < threshold-2; bucket++ {
+		start := int(float64(bucket)*every) + 1
+		end := int(float64(bucket+1)*every) + 1
+
+		// Average of the next bucket (the last point for the last bucket) is the third triangle corner
+		nextStart, nextEnd := end, int(float64(bucket+2)*every)+1
+		if nextEnd > n {
+			nextEnd = n
+		}
+		var avgX, avgY float64
+		for i := nextStart; i < nextEnd; i++ {
+			avgX += xs[i]
+			avgY += ys[i]
+		}
+		avgX /= float64(nextEnd - nextStart)
+		avgY /= float64(nextEnd - nextStart)
+
+		best, bestArea := start, -1.0
+		for i := start; i < end; i++ {
+			area := math.Abs((xs[selected]-avgX)*(ys[i]-ys[selected]) - (xs[selected]-xs[i])*(avgY-ys[selected]))
+			if area > bestArea {
+				best, bestArea = i, area
+			}
+		}
+		indexes = append(indexes, best)
+		selected = best
+	}
+
+	return append(indexes, n-1)
+}
+
+// GetTempSensorDataMinMax returns the lowest and highest reading of metric in
+// every bucket, in time order (one point when they are the same reading).
+// Buckets without the metric become gap slots. When aggregation is enabled,
+// both points of a bucket carry its aggregates.
+func (db *Database) GetTempSensorDataMinMax(ctx context.Context, buckets []Bucket, filter QualityFilter, metric string, aggregation BucketAggregation) ([]TempSensorData, error) {
+	if len(buckets) == 0 {
+		return []TempSensorData{}, nil
+	}
+
+	ctx, cancel := db.withTimeout(ctx, QueryAggregate)
+	defer cancel()
+
+	allData, err := db.getTempSensorDataInRange(ctx, buckets[0].Start, buckets[len(buckets)-1].End, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := minMaxSample(allData, buckets, metric, aggregation)
+	result = markOutliers(result)
+	result = addDerivedMetrics(result)
+
+	return result, nil
+}
+
+// minMaxSample picks the lowest and highest reading of metric in every bucket
+// from data (oldest first), or a gap slot for a bucket without the metric
+func minMaxSample(data []TempSensorData, buckets []Bucket, metric string, aggregation BucketAggregation) []TempSensorData {
+	sensor, name := aggregateMetricSource(metric)
+	result := make([]TempSensorData, 0, 2*len(buckets))
+	eachBucket(data, buckets, func(bucket Bucket, readings []TempSensorData) {
+		var aggregates *BucketAggregates
+		if aggregation.Enabled() {
+			aggregates = aggregateBucket(readings, bucket, aggregation)
+		}
+
+		low, high := -1, -1
+		var lowValue, highValue float64
+		for i := range readings {
+			value, ok := MetricValue(&readings[i], sensor, name)
+			if !ok {
+				continue
+			}
+			if low < 0 || value < lowValue {
+				low, lowValue = i, value
+			}
+			if high < 0 || value > highValue {
+				high, highValue = i, value
+			}
+		}
+
+		var points []TempSensorData
+		switch {
+		case low < 0:
+			points = []TempSensorData{{Timestamp: bucket.Start, Gap: true}}
+		case low == high:
+			points = []TempSensorData{readings[low]}
+		case low < high:
+			points = []TempSensorData{readings[low], readings[high]}
+		default:
+			points = []TempSensorData{readings[high], readings[low]}
+		}
+		for _, point := range points {
+			point.Bucket = aggregates
+			result = append(result, point)
+		}
+	})
+	return result
+}
+
+// eachBucket calls visit with the readings (oldest first) of every bucket
+func eachBucket(data []TempSensorData, buckets []Bucket, visit func(Bucket, []TempSensorData)) {
+	next := 0
+	for _, bucket := range buckets {
+		for next < len(data) && data[next].Timestamp.Before(bucket.Start) {
+			next++
+		}
+		end := next
+		for end < len(data) && data[end].Timestamp.Before(bucket.End) {
+			end++
+		}
+		visit(bucket, data[next:end])
+		next = end
+	}
+}
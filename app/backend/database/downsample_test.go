@@ -0,0 +1,157 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLTTBIndexes(t *testing.T) {
+	xs := []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	flat := make([]float64, len(xs))
+
+	tests := []struct {
+		name      string
+		ys        []float64
+		threshold int
+		want      []int
+	}{
+		{name: "limit above points", ys: flat, threshold: 15, want: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{name: "limit equals points", ys: flat, threshold: 10, want: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{name: "limit 0", ys: flat, threshold: 0, want: nil},
+		{name: "limit 1 keeps the latest", ys: flat, threshold: 1, want: []int{9}},
+		{name: "limit 2 keeps first and last", ys: flat, threshold: 2, want: []int{0, 9}},
+		{name: "spike in the only bucket", ys: []float64{0, 0, 0, 0, 10, 0, 0, 0, 0, 0}, threshold: 3, want: []int{0, 4, 9}},
+		{name: "spike at bucket edges", ys: []float64{0, 5, 0, 0, 0, 0, 0, 0, -5, 0}, threshold: 4, want: []int{0, 1, 8, 9}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lttbIndexes(xs, tt.ys, tt.threshold); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lttbIndexes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Every bucket contributes one point: the result is ordered, has threshold
+// points and keeps both ends
+func TestLTTBIndexesShape(t *testing.T) {
+	n := 101
+	xs, ys := make([]float64, n), make([]float64, n)
+	for i := range xs {
+		xs[i], ys[i] = float64(i), float64((i*37)%11)
+	}
+
+	for threshold := 3; threshold < n; threshold += 7 {
+		indexes := lttbIndexes(xs, ys, threshold)
+		if len(indexes) != threshold {
+			t.Fatalf("threshold %d: %d indexes", threshold, len(indexes))
+		}
+		if indexes[0] != 0 || indexes[len(indexes)-1] != n-1 {
+			t.Errorf("threshold %d: first and last = %d, %d", threshold, indexes[0], indexes[len(indexes)-1])
+		}
+		for i := 1; i < len(indexes); i++ {
+			if indexes[i] <= indexes[i-1] {
+				t.Fatalf("threshold %d: indexes not increasing: %v", threshold, indexes)
+			}
+		}
+	}
+}
+
+// Readings without the metric are skipped, so the first and last points kept
+// are the first and last readings that have it
+func TestLTTBSampleSkipsReadingsWithoutMetric(t *testing.T) {
+	start := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+	data := readingsEvery(start, time.Minute, 6, 22)
+	for _, i := range []int{1, 2, 4} {
+		data[i].ACOutletTemperature, data[i].ACOutletHumidity = float(18+float64(i)), float(50)
+	}
+
+	got := lttbSample(data, 2, "ac_outlet_temperature")
+	if len(got) != 2 || !got[0].Timestamp.Equal(data[1].Timestamp) || !got[1].Timestamp.Equal(data[4].Timestamp) {
+		t.Fatalf("lttbSample = %v, want readings 1 and 4", timestampsOf(got))
+	}
+
+	if got := lttbSample(data, 10, "ac_outlet_temperature"); len(got) != 3 {
+		t.Errorf("lttbSample with a limit above the readings = %v, want readings 1, 2 and 4", timestampsOf(got))
+	}
+}
+
+func TestMinMaxSample(t *testing.T) {
+	start := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+	buckets := []Bucket{
+		{Start: start, End: start.Add(10 * time.Minute)},
+		{Start: start.Add(10 * time.Minute), End: start.Add(20 * time.Minute)},
+		{Start: start.Add(20 * time.Minute), End: start.Add(30 * time.Minute)},
+	}
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	reading := func(minutes int, temperature float64, acOutlet *float64) TempSensorData {
+		data := TempSensorData{Timestamp: at(minutes), Temperature: temperature, Humidity: 40}
+		if acOutlet != nil {
+			data.ACOutletTemperature, data.ACOutletHumidity = acOutlet, float(50)
+		}
+		return data
+	}
+
+	data := []TempSensorData{
+		reading(0, 22, float(18)),
+		reading(2, 25, nil),
+		reading(4, 20, float(17)),
+		reading(9, 21, float(19)),
+		reading(10, 23, nil), // Starts the second bucket
+	}
+
+	tests := []struct {
+		name   string
+		metric string
+		want   []time.Time
+		gaps   []time.Time
+	}{
+		{
+			name:   "high before low, single reading, empty bucket",
+			metric: MetricTemperature,
+			want:   []time.Time{at(2), at(4), at(10), at(20)},
+			gaps:   []time.Time{at(20)},
+		},
+		{
+			name:   "readings without the metric are skipped",
+			metric: "ac_outlet_temperature",
+			want:   []time.Time{at(4), at(9), at(10), at(20)},
+			gaps:   []time.Time{at(10), at(20)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := minMaxSample(data, buckets, tt.metric, BucketAggregation{})
+			if !reflect.DeepEqual(timestampsOf(got), tt.want) {
+				t.Fatalf("minMaxSample = %v, want %v", timestampsOf(got), tt.want)
+			}
+			var gaps []time.Time
+			for _, point := range got {
+				if point.Gap {
+					gaps = append(gaps, point.Timestamp)
+				}
+			}
+			if !reflect.DeepEqual(gaps, tt.gaps) {
+				t.Errorf("gaps = %v, want %v", gaps, tt.gaps)
+			}
+		})
+	}
+
+	// Both points of a bucket carry its aggregates
+	aggregation := BucketAggregation{Functions: []string{"max"}, Metrics: []string{MetricTemperature}}
+	got := minMaxSample(data, buckets[:1], MetricTemperature, aggregation)
+	if len(got) != 2 || got[0].Bucket == nil || got[0].Bucket != got[1].Bucket {
+		t.Errorf("points of the first bucket do not share its aggregates: %+v", got)
+	}
+}
+
+func timestampsOf(data []TempSensorData) []time.Time {
+	timestamps := make([]time.Time, len(data))
+	for i := range data {
+		timestamps[i] = data[i].Timestamp
+	}
+	return timestamps
+}
@@ -0,0 +1,132 @@
+package database
+
+import "fmt"
+
+// FillMode decides what sampled history returns for slots without a reading
+type FillMode string
+
+const (
+	FillNull     FillMode = "null"     // Leave the slot empty
+	FillPrevious FillMode = "previous" // Carry the previous reading forward
+	FillLinear   FillMode = "linear"   // Interpolate between the surrounding readings
+)
+
+// ParseFillMode parses the fill query parameter, defaulting to FillNull
+func ParseFillMode(value string) (FillMode, error) {
+	switch FillMode(value) {
+	case "", FillNull:
+		return FillNull, nil
+	case FillPrevious, FillLinear:
+		return FillMode(value), nil
+	}
+	return "", fmt.Errorf("unknown fill mode %q", value)
+}
+
+// FillGaps fills gap slots of time-ordered (oldest first) sampled data.
+// Filled slots keep Gap set and are flagged as interpolated.
+// Slots that cannot be filled (no reading before, or after for linear) stay empty.
+func FillGaps(data []TempSensorData, mode FillMode) []TempSensorData {
+	if mode == FillNull {
+		return data
+	}
+
+	prev := -1
+	for i := range data {
+		if !data[i].Gap {
+			prev = i
+			continue
+		}
+		if prev < 0 {
+			continue
+		}
+
+		switch mode {
+		case FillPrevious:
+			fillFrom(&data[i], data[prev], data[prev], 0)
+		case FillLinear:
+			next := -1
+			for j := i + 1; j < len(data); j++ {
+				if !data[j].Gap {
+					next = j
+					break
+				}
+			}
+			if next < 0 {
+				continue
+			}
+			span := data[next].Timestamp.Sub(data[prev].Timestamp)
+			ratio := 0.0
+			if span > 0 {
+				ratio = float64(data[i].Timestamp.Sub(data[prev].Timestamp)) / float64(span)
+			}
+			fillFrom(&data[i], data[prev], data[next], ratio)
+		}
+	}
+
+	return data
+}
+
+// fillFrom sets the values of a gap slot to before + (after-before)*ratio
+func fillFrom(slot *TempSensorData, before, after TempSensorData, ratio float64) {
+	lerp := func(a, b float64) float64 {
+		return a + (b-a)*ratio
+	}
+	lerpPtr := func(a, b *float64) *float64 {
+		if a == nil || b == nil {
+			return nil
+		}
+		value := lerp(*a, *b)
+		return &value
+	}
+
+	slot.Temperature = lerp(before.Temperature, after.Temperature)
+	slot.Humidity = lerp(before.Humidity, after.Humidity)
+	slot.ACOutletTemperature = lerpPtr(before.ACOutletTemperature, after.ACOutletTemperature)
+	slot.ACOutletHumidity = lerpPtr(before.ACOutletHumidity, after.ACOutletHumidity)
+	slot.QualityFlags = []string{QualityFlagInterpolated}
+	slot.IsOutlier = false
+	slot.Derived = CalculateDerivedMetrics(slot.Temperature, slot.Humidity)
+	if slot.ACOutletTemperature != nil && slot.ACOutletHumidity != nil {
+		slot.ACOutletDerived = CalculateDerivedMetrics(*slot.ACOutletTemperature, *slot.ACOutletHumidity)
+	}
+}
+
+// isFilled reports whether a gap slot received values from FillGaps
+func (d TempSensorData) isFilled() bool {
+	return d.Gap && hasQualityFlag(d.QualityFlags, QualityFlagInterpolated)
+}
+
+// ToNullable converts sampled data to the v2 representation with explicit nulls
+func ToNullable(data []TempSensorData) []NullableTempSensorData {
+	result := make([]NullableTempSensorData, 0, len(data))
+	for i := range data {
+		item := data[i]
+		point := NullableTempSensorData{
+			Timestamp:         item.Timestamp,
+			Gap:               item.Gap,
+			IsOutlier:         item.IsOutlier,
+			QualityFlags:      item.QualityFlags,
+			Derived:           item.Derived,
+			ACOutletDerived:   item.ACOutletDerived,
+			DefaultAggregated: item.DefaultAggregated,
+			Aggregated:        item.Aggregated,
+			Bucket:            item.Bucket,
+		}
+		if point.QualityFlags == nil {
+			point.QualityFlags = []string{}
+		}
+
+		if !item.Gap || item.isFilled() {
+			point.Temperature = &item.Temperature
+			point.Humidity = &item.Humidity
+			point.ACOutletTemperature = item.ACOutletTemperature
+			point.ACOutletHumidity = item.ACOutletHumidity
+		}
+		if !item.Gap {
+			point.ID = &item.ID
+		}
+
+		result = append(result, point)
+	}
+	return result
+}
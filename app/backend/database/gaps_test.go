@@ -0,0 +1,106 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+// sampledWithGaps returns six one-minute slots: a leading gap, a reading, two
+// gaps, a reading and a trailing gap. Only the first reading has AC outlet values.
+func sampledWithGaps(start time.Time) []TempSensorData {
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	return []TempSensorData{
+		{Timestamp: at(0), Gap: true},
+		{ID: 1, Timestamp: at(1), Temperature: 20, Humidity: 40, ACOutletTemperature: float(15), ACOutletHumidity: float(60)},
+		{Timestamp: at(2), Gap: true},
+		{Timestamp: at(3), Gap: true},
+		{ID: 2, Timestamp: at(4), Temperature: 26, Humidity: 46},
+		{Timestamp: at(5), Gap: true},
+	}
+}
+
+func TestFillGaps(t *testing.T) {
+	start := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+
+	// Temperature of every slot after filling, nil for an empty slot
+	tests := []struct {
+		mode            FillMode
+		wantTemperature []*float64
+		wantACOutlet    []*float64
+	}{
+		{
+			mode:            FillNull,
+			wantTemperature: []*float64{nil, float(20), nil, nil, float(26), nil},
+			wantACOutlet:    []*float64{nil, float(15), nil, nil, nil, nil},
+		},
+		{
+			mode:            FillPrevious,
+			wantTemperature: []*float64{nil, float(20), float(20), float(20), float(26), float(26)},
+			wantACOutlet:    []*float64{nil, float(15), float(15), float(15), nil, nil},
+		},
+		{
+			// No reading after the trailing gap; no AC outlet value after the first reading
+			mode:            FillLinear,
+			wantTemperature: []*float64{nil, float(20), float(22), float(24), float(26), nil},
+			wantACOutlet:    []*float64{nil, float(15), nil, nil, nil, nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			points := ToNullable(FillGaps(sampledWithGaps(start), tt.mode))
+
+			for i, point := range points {
+				if !equalFloatPtr(point.Temperature, tt.wantTemperature[i]) {
+					t.Errorf("slot %d temperature = %v, want %v", i, deref(point.Temperature), deref(tt.wantTemperature[i]))
+				}
+				if !equalFloatPtr(point.ACOutletTemperature, tt.wantACOutlet[i]) {
+					t.Errorf("slot %d ac_outlet_temperature = %v, want %v", i, deref(point.ACOutletTemperature), deref(tt.wantACOutlet[i]))
+				}
+
+				filled := point.Gap && point.Temperature != nil
+				if interpolated := hasQualityFlag(point.QualityFlags, QualityFlagInterpolated); interpolated != filled {
+					t.Errorf("slot %d interpolated flag = %v, want %v", i, interpolated, filled)
+				}
+				if filled && point.Derived == nil {
+					t.Errorf("slot %d was filled without derived metrics", i)
+				}
+				if point.QualityFlags == nil {
+					t.Errorf("slot %d quality_flags is null, want []", i)
+				}
+				if (point.ID != nil) == point.Gap {
+					t.Errorf("slot %d id = %v with gap %v", i, point.ID, point.Gap)
+				}
+			}
+		})
+	}
+}
+
+// Linear fill weighs the surrounding readings by time, not by slot position
+func TestFillGapsLinearUnevenSpacing(t *testing.T) {
+	start := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+	data := []TempSensorData{
+		{ID: 1, Timestamp: start, Temperature: 20, Humidity: 40},
+		{Timestamp: start.Add(time.Minute), Gap: true},
+		{ID: 2, Timestamp: start.Add(4 * time.Minute), Temperature: 28, Humidity: 48},
+	}
+
+	filled := FillGaps(data, FillLinear)[1]
+	if filled.Temperature != 22 || filled.Humidity != 42 {
+		t.Errorf("filled slot = %v/%v, want 22/42", filled.Temperature, filled.Humidity)
+	}
+}
+
+func equalFloatPtr(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func deref(v *float64) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+)
+
+// GoMigrationFunc performs a migration step inside the migration transaction.
+// It must not commit or roll back tx.
+type GoMigrationFunc func(tx *sql.Tx) error
+
+// goMigration is a migration written in Go, for data transformations that
+// are awkward in plain SQL (row splitting, per-row computations, ...)
+type goMigration struct {
+	Description string
+	Up          GoMigrationFunc
+	Down        GoMigrationFunc // nil if the migration cannot be reverted
+}
+
+var (
+	goMigrations       = make(map[string]goMigration)
+	migrationVersionRe = regexp.MustCompile(`^\d{3}_[a-z0-9_]+$`)
+)
+
+// RegisterGoMigration registers a Go migration under version. Go and SQL
+// migrations share one number space: NNN must not be used by a file in
+// migrations/ or another Go migration. They are applied in version order
+// together, each in its own transaction, and recorded in schema_version
+// like SQL migrations.
+//
+// Register from an init function in this package, with NNN the next unused number:
+//
+//	func init() {
+//		RegisterGoMigration("NNN_split_sensor_rows", "Split ac_outlet_* columns into per-sensor rows",
+//			splitSensorRowsUp, splitSensorRowsDown)
+//	}
+//
+// It panics on an invalid or duplicate version, like sql.Register.
+func RegisterGoMigration(version, description string, up, down GoMigrationFunc) {
+	if !migrationVersionRe.MatchString(version) {
+		panic(fmt.Sprintf("database: invalid Go migration version %q, expected NNN_name", version))
+	}
+	if up == nil {
+		panic(fmt.Sprintf("database: Go migration %s has no up function", version))
+	}
+	if _, dup := goMigrations[version]; dup {
+		panic(fmt.Sprintf("database: Go migration %s registered twice", version))
+	}
+
+	goMigrations[version] = goMigration{Description: description, Up: up, Down: down}
+}
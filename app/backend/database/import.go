@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// ImportTempSensorDataBatch inserts historical readings in one transaction.
+// A reading is a duplicate (and skipped) when a reading with the same timestamp
+// already exists. Calibration history is applied in memory, and readings are
+// judged on time at their own timestamp so backfills are not flagged as late.
+func (db *Database) ImportTempSensorDataBatch(ctx context.Context, batch []TempSensorData, calibrations []SensorCalibration) (inserted, duplicates int, err error) {
+	ctx, cancel := db.withTimeout(ctx, QueryBulk)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO temp_sensor_data (temperature, humidity, ac_outlet_temperature, ac_outlet_humidity, timestamp, quality_flags,
+		raw_temperature, raw_humidity, raw_ac_outlet_temperature, raw_ac_outlet_humidity) 
+	SELECT $1::float8, $2::float8, $3::float8, $4::float8, $5::timestamp, $6::text[], $7::float8, $8::float8, $9::float8, $10::float8 
+	WHERE NOT EXISTS (SELECT 1 FROM temp_sensor_data WHERE timestamp = $5::timestamp) 
+	RETURNING id`
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stmt.Close()
+
+	for i := range batch {
+		data := &batch[i]
+		keepRawValues(data)
+		applyCalibrations(data, activeCalibrationsAt(calibrations, data.Timestamp))
+		detectQualityFlags(data, data.Timestamp)
+
+		err := stmt.QueryRowContext(ctx, data.Temperature, data.Humidity, data.ACOutletTemperature, data.ACOutletHumidity, data.Timestamp, pq.Array(data.QualityFlags),
+			data.RawTemperature, data.RawHumidity, data.RawACOutletTemperature, data.RawACOutletHumidity).Scan(&data.ID)
+		if err == sql.ErrNoRows {
+			duplicates++
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		inserted++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return inserted, duplicates, nil
+}
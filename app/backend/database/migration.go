@@ -1,11 +1,17 @@
 package database
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -17,22 +23,142 @@ import (
 type Migration struct {
 	Version     string
 	Description string
-	SQL         string
+	SQL         string // Up section
+	DownSQL     string // Down section, empty if the migration cannot be reverted
+
+	// Set instead of SQL/DownSQL for migrations registered with RegisterGoMigration
+	UpFunc   GoMigrationFunc
+	DownFunc GoMigrationFunc
+}
+
+// IsGo reports whether the migration is a registered Go migration
+func (m *Migration) IsGo() bool {
+	return m.UpFunc != nil
+}
+
+// up runs the up step of the migration in tx
+func (m *Migration) up(tx *sql.Tx) error {
+	if m.IsGo() {
+		return m.UpFunc(tx)
+	}
+	_, err := tx.Exec(m.SQL)
+	return err
+}
+
+// down runs the down step of the migration in tx
+func (m *Migration) down(tx *sql.Tx) error {
+	if m.IsGo() {
+		return m.DownFunc(tx)
+	}
+	_, err := tx.Exec(m.DownSQL)
+	return err
+}
+
+// reversible reports whether the migration has a down step
+func (m *Migration) reversible() bool {
+	if m.IsGo() {
+		return m.DownFunc != nil
+	}
+	return m.DownSQL != ""
+}
+
+// dataLossRe matches down statements that delete stored data
+var dataLossRe = regexp.MustCompile(`(?i)\b(DROP\s+(TABLE|COLUMN)|TRUNCATE|DELETE\s+FROM)\b[^,;\n]*`)
+
+// dataLoss returns the statements of the down step that delete stored data.
+// Go down functions cannot be inspected and are not checked.
+func (m *Migration) dataLoss() []string {
+	if m.IsGo() {
+		return nil
+	}
+	return dataLossRe.FindAllString(m.DownSQL, -1)
 }
 
+// ErrDataLoss is returned when a migration to revert deletes data and the
+// data loss was not allowed with SetAllowDataLoss
+var ErrDataLoss = errors.New("down migration deletes data")
+
+// Section markers inside a migration file. Everything before the down marker
+// is the up migration; files without markers are up-only.
+const (
+	migrationUpMarker   = "-- +migrate Up"
+	migrationDownMarker = "-- +migrate Down"
+)
+
+// DriftPolicy decides what RunMigrations does when an applied migration file
+// no longer matches the checksum recorded when it was applied
+type DriftPolicy string
+
+const (
+	DriftWarn DriftPolicy = "warn" // Log the drift and continue
+	DriftFail DriftPolicy = "fail" // Refuse to migrate
+)
+
+// ParseDriftPolicy validates a drift policy, defaulting to warn
+func ParseDriftPolicy(value string) (DriftPolicy, error) {
+	switch DriftPolicy(strings.ToLower(value)) {
+	case "", DriftWarn:
+		return DriftWarn, nil
+	case DriftFail:
+		return DriftFail, nil
+	}
+	return "", fmt.Errorf("unknown migration drift policy %q (use warn or fail)", value)
+}
+
+// embeddedMigrations are the SQL migrations compiled into the binary
+//
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// migrationLockID is the PostgreSQL advisory lock key held while migrating,
+// so only one instance applies migrations when several start at once
+const migrationLockID int64 = 4283019472
+
 // MigrationManager handles database migrations
 type MigrationManager struct {
-	db *Database
+	db            *Database
+	source        fs.FS // Directory holding the NNN_name.sql files
+	sourceName    string
+	driftPolicy   DriftPolicy
+	allowDataLoss bool
 }
 
-// NewMigrationManager creates a new migration manager
+// NewMigrationManager creates a new migration manager using the embedded migrations
 func NewMigrationManager(db *Database) *MigrationManager {
-	return &MigrationManager{db: db}
+	source, _ := fs.Sub(embeddedMigrations, "migrations")
+	return &MigrationManager{db: db, source: source, sourceName: "embedded", driftPolicy: DriftWarn}
+}
+
+// SetMigrationsDir reads migrations from a directory instead of the embedded files
+func (mm *MigrationManager) SetMigrationsDir(dir string) {
+	mm.source = os.DirFS(dir)
+	mm.sourceName = dir
+}
+
+// SetDriftPolicy sets how RunMigrations reacts to edited applied migrations
+func (mm *MigrationManager) SetDriftPolicy(policy DriftPolicy) {
+	mm.driftPolicy = policy
+}
+
+// SetAllowDataLoss allows reverting migrations whose down step drops tables or
+// columns or deletes rows
+func (mm *MigrationManager) SetAllowDataLoss(allow bool) {
+	mm.allowDataLoss = allow
 }
 
 // RunMigrations runs all pending migrations
-func (mm *MigrationManager) RunMigrations(migrationsDir string) error {
-	log.Println("Starting database migrations...")
+func (mm *MigrationManager) RunMigrations() error {
+	slog.Info("Starting database migrations", "source", mm.sourceName)
+
+	unlock, err := mm.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := mm.upgradeSchemaVersion(); err != nil {
+		return err
+	}
 
 	// Get applied migrations
 	appliedMigrations, err := mm.getAppliedMigrations()
@@ -41,7 +167,7 @@ func (mm *MigrationManager) RunMigrations(migrationsDir string) error {
 	}
 
 	// Get all migration files
-	migrationFiles, err := mm.getMigrationFiles(migrationsDir)
+	migrationFiles, err := mm.getMigrationFiles()
 	if err != nil {
 		return fmt.Errorf("failed to get migration files: %v", err)
 	}
@@ -49,44 +175,264 @@ func (mm *MigrationManager) RunMigrations(migrationsDir string) error {
 	// Sort migration files by version
 	sort.Strings(migrationFiles)
 
+	// Applied files must not have been edited since they ran
+	if err := mm.checkDrift(); err != nil {
+		return err
+	}
+
 	// Find pending migrations
 	pendingMigrations := mm.getPendingMigrations(migrationFiles, appliedMigrations)
 
 	if len(pendingMigrations) == 0 {
-		log.Println("No pending migrations found")
+		slog.Info("No pending migrations found")
 		return nil
 	}
 
-	log.Printf("Found %d pending migrations", len(pendingMigrations))
+	slog.Info("Found pending migrations", "count", len(pendingMigrations))
 
 	// Run pending migrations
 	for _, migrationFile := range pendingMigrations {
-		if err := mm.runMigration(migrationFile, migrationsDir); err != nil {
+		if err := mm.runMigration(migrationFile); err != nil {
 			return fmt.Errorf("failed to run migration %s: %v", migrationFile, err)
 		}
 	}
 
-	log.Println("All migrations completed successfully")
+	slog.Info("All migrations completed successfully")
 	return nil
 }
 
-// getAppliedMigrations returns a set of applied migration versions
-func (mm *MigrationManager) getAppliedMigrations() (map[string]bool, error) {
-	// Check if schema_version table exists
-	var tableExists bool
-	query := `
-		SELECT EXISTS (
-			SELECT FROM information_schema.tables 
-			WHERE table_schema = 'public' 
-			AND table_name = 'schema_version'
-		)`
+// MigrateDown reverts the last n applied migrations, newest first
+func (mm *MigrationManager) MigrateDown(n int) error {
+	unlock, err := mm.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := mm.upgradeSchemaVersion(); err != nil {
+		return err
+	}
+
+	if err := mm.checkDrift(); err != nil {
+		return err
+	}
+
+	applied, err := mm.getAppliedVersions()
+	if err != nil {
+		return fmt.Errorf("failed to get applied migrations: %v", err)
+	}
+
+	if n > len(applied) {
+		n = len(applied)
+	}
+
+	var toRevert []string
+	for i := len(applied) - 1; i >= len(applied)-n; i-- {
+		toRevert = append(toRevert, applied[i])
+	}
+
+	return mm.rollbackMigrations(toRevert)
+}
 
-	err := mm.db.QueryRow(query).Scan(&tableExists)
+// MigrateTo applies or reverts migrations until target is the newest applied
+// migration. The target may be a full version or its numeric prefix ("003");
+// "0" reverts every migration.
+func (mm *MigrationManager) MigrateTo(target string) error {
+	unlock, err := mm.lock()
 	if err != nil {
-		return nil, fmt.Errorf("failed to check schema_version table: %v", err)
+		return err
 	}
+	defer unlock()
+
+	if err := mm.upgradeSchemaVersion(); err != nil {
+		return err
+	}
+
+	migrationFiles, err := mm.getMigrationFiles()
+	if err != nil {
+		return fmt.Errorf("failed to get migration files: %v", err)
+	}
+	sort.Strings(migrationFiles)
+
+	targetVersion, err := resolveMigrationVersion(migrationFiles, target)
+	if err != nil {
+		return err
+	}
+
+	if err := mm.checkDrift(); err != nil {
+		return err
+	}
+
+	applied, err := mm.getAppliedVersions()
+	if err != nil {
+		return fmt.Errorf("failed to get applied migrations: %v", err)
+	}
+
+	// Revert applied migrations newer than the target, newest first
+	var toRevert []string
+	for i := len(applied) - 1; i >= 0; i-- {
+		if applied[i] > targetVersion {
+			toRevert = append(toRevert, applied[i])
+		}
+	}
+	if err := mm.rollbackMigrations(toRevert); err != nil {
+		return err
+	}
+
+	// Apply pending migrations up to and including the target
+	appliedSet := make(map[string]bool)
+	for _, version := range applied {
+		appliedSet[version] = true
+	}
+	for _, version := range mm.getPendingMigrations(migrationFiles, appliedSet) {
+		if version > targetVersion {
+			break
+		}
+		if err := mm.runMigration(version); err != nil {
+			return fmt.Errorf("failed to run migration %s: %v", version, err)
+		}
+	}
+
+	slog.Info("Database is at target migration", "version", target)
+	return nil
+}
+
+// lock takes the migration advisory lock on a dedicated connection, waiting
+// while another instance holds it. The returned func releases the lock.
+func (mm *MigrationManager) lock() (func(), error) {
+	ctx := context.Background()
+	conn, err := mm.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection for migration lock: %v", err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, migrationLockID).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to acquire migration lock: %v", err)
+	}
+	if !acquired {
+		slog.Warn("Another instance is running migrations, waiting for the migration lock")
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to acquire migration lock: %v", err)
+		}
+	}
+
+	return func() {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+			slog.Error("Failed to release migration lock", "error", err)
+		}
+		conn.Close()
+	}, nil
+}
+
+// resolveMigrationVersion maps a target ("003", "003_add_quality_flags" or "0")
+// to a full migration version; "" stands for "before the first migration"
+func resolveMigrationVersion(migrationFiles []string, target string) (string, error) {
+	if strings.Trim(target, "0") == "" {
+		return "", nil
+	}
+	for _, version := range migrationFiles {
+		if version == target || strings.HasPrefix(version, target+"_") {
+			return version, nil
+		}
+	}
+	return "", fmt.Errorf("unknown migration version %q", target)
+}
+
+// rollbackMigrations reverts the given versions in order. Every migration is
+// checked for a down section, and for data loss unless allowed, first so a
+// refused one does not leave the schema half reverted.
+func (mm *MigrationManager) rollbackMigrations(versions []string) error {
+	var migrations []*Migration
+	for _, version := range versions {
+		migration, err := mm.loadMigration(version)
+		if err != nil {
+			return err
+		}
+		if !migration.reversible() {
+			if migration.IsGo() {
+				return fmt.Errorf("Go migration %s has no down function and cannot be reverted", version)
+			}
+			return fmt.Errorf("migration %s has no %q section and cannot be reverted", version, migrationDownMarker)
+		}
+		if statements := migration.dataLoss(); len(statements) > 0 {
+			if !mm.allowDataLoss {
+				return fmt.Errorf("%w: reverting %s runs %s", ErrDataLoss, version, strings.Join(statements, "; "))
+			}
+			slog.Warn("Reverting migration deletes data", "version", version, "statements", statements)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	for _, migration := range migrations {
+		if err := mm.rollbackMigration(migration); err != nil {
+			return fmt.Errorf("failed to revert migration %s: %v", migration.Version, err)
+		}
+	}
+	return nil
+}
+
+// rollbackMigration runs the down section and removes the schema_version
+// record in a single transaction
+func (mm *MigrationManager) rollbackMigration(migration *Migration) error {
+	slog.Info("Reverting migration", "version", migration.Version)
 
-	if !tableExists {
+	tx, err := mm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	if err := migration.down(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to execute down migration: %v", err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM schema_version WHERE version = $1`, migration.Version)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to remove migration record: %v", err)
+	}
+	if removed, err := result.RowsAffected(); err == nil && removed != 1 {
+		tx.Rollback()
+		return fmt.Errorf("migration %s is not recorded as applied", migration.Version)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit down migration: %v", err)
+	}
+
+	slog.Info("Successfully reverted migration", "version", migration.Version)
+	return nil
+}
+
+// getAppliedVersions returns applied migration versions, oldest first
+func (mm *MigrationManager) getAppliedVersions() ([]string, error) {
+	appliedMigrations, err := mm.getAppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]string, 0, len(appliedMigrations))
+	for version := range appliedMigrations {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// upgradeSchemaVersion creates schema_version on first use (marking the
+// initial migration applied on databases that predate it) and adds the
+// checksum column to tables created before checksums were recorded. It
+// writes, so it runs only with the migration lock held.
+func (mm *MigrationManager) upgradeSchemaVersion() error {
+	exists, _, err := mm.schemaVersionState(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if !exists {
 		// Check if temp_sensor_data table exists (legacy table)
 		var legacyTableExists bool
 		legacyQuery := `
@@ -98,23 +444,24 @@ func (mm *MigrationManager) getAppliedMigrations() (map[string]bool, error) {
 
 		err := mm.db.QueryRow(legacyQuery).Scan(&legacyTableExists)
 		if err != nil {
-			return nil, fmt.Errorf("failed to check temp_sensor_data table: %v", err)
+			return fmt.Errorf("failed to check temp_sensor_data table: %v", err)
 		}
 
 		// Create schema_version table
 		createTableQuery := `
-			CREATE TABLE schema_version (
+			CREATE TABLE IF NOT EXISTS schema_version (
 				id SERIAL PRIMARY KEY,
 				version VARCHAR(50) NOT NULL UNIQUE,
 				description TEXT,
-				applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				checksum VARCHAR(64)
 			)`
 
 		if _, err := mm.db.Exec(createTableQuery); err != nil {
-			return nil, fmt.Errorf("failed to create schema_version table: %v", err)
+			return fmt.Errorf("failed to create schema_version table: %v", err)
 		}
 
-		log.Println("Created schema_version table")
+		slog.Info("Created schema_version table")
 
 		// If legacy table exists, mark the initial migration as applied
 		if legacyTableExists {
@@ -124,19 +471,40 @@ func (mm *MigrationManager) getAppliedMigrations() (map[string]bool, error) {
 				ON CONFLICT (version) DO NOTHING`
 
 			if _, err := mm.db.Exec(insertQuery); err != nil {
-				return nil, fmt.Errorf("failed to insert initial migration record: %v", err)
+				return fmt.Errorf("failed to insert initial migration record: %v", err)
 			}
 
-			log.Println("Detected existing temp_sensor_data table, marked initial migration as applied")
-			return map[string]bool{"001_initial_schema": true}, nil
+			slog.Info("Detected existing temp_sensor_data table, marked initial migration as applied")
 		}
+		return nil
+	}
 
-		return make(map[string]bool), nil
+	// schema_version tables created before checksums were recorded
+	if _, err := mm.db.Exec(`ALTER TABLE schema_version ADD COLUMN IF NOT EXISTS checksum VARCHAR(64)`); err != nil {
+		return fmt.Errorf("failed to add checksum column to schema_version: %v", err)
 	}
+	return nil
+}
 
-	// Get applied migrations
-	query = `SELECT version FROM schema_version ORDER BY version`
-	rows, err := mm.db.Query(query)
+// schemaVersionState reports whether schema_version exists and has the
+// checksum column, without changing anything
+func (mm *MigrationManager) schemaVersionState(ctx context.Context) (exists, hasChecksum bool, err error) {
+	query := `
+		SELECT COUNT(*) > 0, COUNT(*) FILTER (WHERE column_name = 'checksum') > 0
+		FROM information_schema.columns 
+		WHERE table_schema = 'public' 
+		AND table_name = 'schema_version'`
+
+	if err := mm.db.QueryRowContext(ctx, query).Scan(&exists, &hasChecksum); err != nil {
+		return false, false, fmt.Errorf("failed to check schema_version table: %v", err)
+	}
+	return exists, hasChecksum, nil
+}
+
+// getAppliedMigrations returns a set of applied migration versions. It needs
+// schema_version, so callers run upgradeSchemaVersion first.
+func (mm *MigrationManager) getAppliedMigrations() (map[string]bool, error) {
+	rows, err := mm.db.Query(`SELECT version FROM schema_version ORDER BY version`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query applied migrations: %v", err)
 	}
@@ -151,14 +519,14 @@ func (mm *MigrationManager) getAppliedMigrations() (map[string]bool, error) {
 		applied[version] = true
 	}
 
-	return applied, nil
+	return applied, rows.Err()
 }
 
 // getMigrationFiles returns all migration files in the migrations directory
-func (mm *MigrationManager) getMigrationFiles(migrationsDir string) ([]string, error) {
+func (mm *MigrationManager) getMigrationFiles() ([]string, error) {
 	var files []string
 
-	err := filepath.WalkDir(migrationsDir, func(path string, d fs.DirEntry, err error) error {
+	err := fs.WalkDir(mm.source, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -176,6 +544,28 @@ func (mm *MigrationManager) getMigrationFiles(migrationsDir string) ([]string, e
 		return nil, fmt.Errorf("failed to walk migrations directory: %v", err)
 	}
 
+	// Registered Go migrations share the version sequence
+	sqlFiles := make(map[string]bool)
+	for _, file := range files {
+		sqlFiles[file] = true
+	}
+	for version := range goMigrations {
+		if sqlFiles[version] {
+			return nil, fmt.Errorf("migration %s exists both as SQL file and Go migration", version)
+		}
+		files = append(files, version)
+	}
+
+	// One number per migration, so "migrate to 006" and the order are unambiguous
+	numbers := make(map[string]string)
+	for _, version := range files {
+		number, _, _ := strings.Cut(version, "_")
+		if other, ok := numbers[number]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share the number %s", other, version, number)
+		}
+		numbers[number] = version
+	}
+
 	return files, nil
 }
 
@@ -193,8 +583,8 @@ func (mm *MigrationManager) getPendingMigrations(migrationFiles []string, applie
 }
 
 // runMigration executes a single migration
-func (mm *MigrationManager) runMigration(version, migrationsDir string) error {
-	log.Printf("Running migration: %s", version)
+func (mm *MigrationManager) runMigration(version string) error {
+	slog.Info("Running migration", "version", version)
 
 	// Check if migration is already applied
 	var exists bool
@@ -205,19 +595,24 @@ func (mm *MigrationManager) runMigration(version, migrationsDir string) error {
 	}
 
 	if exists {
-		log.Printf("Migration %s already applied, skipping", version)
+		slog.Info("Migration already applied, skipping", "version", version)
 		return nil
 	}
 
-	// Read migration file
-	filePath := filepath.Join(migrationsDir, version+".sql")
-	content, err := os.ReadFile(filePath)
+	// Read migration file (or registered Go migration)
+	migration, err := mm.loadMigration(version)
 	if err != nil {
-		return fmt.Errorf("failed to read migration file %s: %v", filePath, err)
+		return err
 	}
 
 	// Parse migration description from SQL comments
-	description := mm.extractDescription(string(content))
+	description := migration.Description
+
+	// Go migrations have no file content to checksum
+	var checksum sql.NullString
+	if !migration.IsGo() {
+		checksum = sql.NullString{String: migration.Checksum(), Valid: true}
+	}
 
 	// Start transaction
 	tx, err := mm.db.Begin()
@@ -225,19 +620,19 @@ func (mm *MigrationManager) runMigration(version, migrationsDir string) error {
 		return fmt.Errorf("failed to begin transaction: %v", err)
 	}
 
-	// Execute migration SQL
-	if _, err := tx.Exec(string(content)); err != nil {
+	// Execute migration SQL / Go function
+	if err := migration.up(tx); err != nil {
 		tx.Rollback()
-		return fmt.Errorf("failed to execute migration SQL: %v", err)
+		return fmt.Errorf("failed to execute migration: %v", err)
 	}
 
 	// Record migration as applied (ignore if already exists)
 	insertQuery := `
-		INSERT INTO schema_version (version, description, applied_at) 
-		VALUES ($1, $2, $3)
+		INSERT INTO schema_version (version, description, applied_at, checksum) 
+		VALUES ($1, $2, $3, $4)
 		ON CONFLICT (version) DO NOTHING`
 
-	if _, err := tx.Exec(insertQuery, version, description, time.Now()); err != nil {
+	if _, err := tx.Exec(insertQuery, version, description, time.Now(), checksum); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to record migration: %v", err)
 	}
@@ -247,10 +642,72 @@ func (mm *MigrationManager) runMigration(version, migrationsDir string) error {
 		return fmt.Errorf("failed to commit migration: %v", err)
 	}
 
-	log.Printf("Successfully applied migration: %s", version)
+	slog.Info("Successfully applied migration", "version", version)
 	return nil
 }
 
+// loadMigration returns a registered Go migration, or reads a migration file
+// and splits it into up and down sections
+func (mm *MigrationManager) loadMigration(version string) (*Migration, error) {
+	if goMig, ok := goMigrations[version]; ok {
+		return &Migration{
+			Version:     version,
+			Description: goMig.Description,
+			UpFunc:      goMig.Up,
+			DownFunc:    goMig.Down,
+		}, nil
+	}
+
+	filePath := version + ".sql"
+	content, err := fs.ReadFile(mm.source, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration file %s: %v", filePath, err)
+	}
+
+	up, down := splitMigrationSections(string(content))
+	return &Migration{
+		Version:     version,
+		Description: mm.extractDescription(string(content)),
+		SQL:         up,
+		DownSQL:     down,
+	}, nil
+}
+
+// splitMigrationSections returns the up and down SQL of a migration file
+func splitMigrationSections(content string) (string, string) {
+	var up, down strings.Builder
+	inDown := false
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		marker := strings.TrimSpace(line)
+		switch {
+		case strings.EqualFold(marker, migrationUpMarker):
+			inDown = false
+			continue
+		case strings.EqualFold(marker, migrationDownMarker):
+			inDown = true
+			continue
+		}
+
+		if inDown {
+			down.WriteString(line)
+		} else {
+			up.WriteString(line)
+		}
+	}
+
+	return up.String(), strings.TrimSpace(down.String())
+}
+
+// Checksum is the SHA-256 of the up section. Only the up section counts, so
+// adding or fixing a down section later is not drift; surrounding whitespace
+// and line endings are ignored.
+func (m *Migration) Checksum() string {
+	normalized := strings.TrimSpace(strings.ReplaceAll(m.SQL, "\r\n", "\n"))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
 // extractDescription extracts the description from SQL comments
 func (mm *MigrationManager) extractDescription(content string) string {
 	lines := strings.Split(content, "\n")
@@ -263,14 +720,95 @@ func (mm *MigrationManager) extractDescription(content string) string {
 	return "No description provided"
 }
 
-// GetMigrationStatus returns the current migration status
-func (mm *MigrationManager) GetMigrationStatus(migrationsDir string) ([]MigrationStatus, error) {
-	appliedMigrations, err := mm.getAppliedMigrations()
+// appliedMigrationRecord is a row of schema_version
+type appliedMigrationRecord struct {
+	Description string
+	AppliedAt   time.Time
+	Checksum    string // Empty for migrations applied before checksums were recorded
+}
+
+// getAppliedMigrationRecords returns the schema_version rows keyed by version.
+// Read only: before the first migration run there is no schema_version (no
+// migration applied) or no checksum column (no checksum recorded).
+func (mm *MigrationManager) getAppliedMigrationRecords(ctx context.Context) (map[string]appliedMigrationRecord, error) {
+	exists, hasChecksum, err := mm.schemaVersionState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return map[string]appliedMigrationRecord{}, nil
+	}
+
+	checksum := `''`
+	if hasChecksum {
+		checksum = `COALESCE(checksum, '')`
+	}
+	rows, err := mm.db.QueryContext(ctx, `
+		SELECT version, COALESCE(description, ''), applied_at, `+checksum+`
+		FROM schema_version`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query applied migrations: %v", err)
+	}
+	defer rows.Close()
+
+	records := make(map[string]appliedMigrationRecord)
+	for rows.Next() {
+		var version string
+		var record appliedMigrationRecord
+		if err := rows.Scan(&version, &record.Description, &record.AppliedAt, &record.Checksum); err != nil {
+			return nil, fmt.Errorf("failed to scan migration record: %v", err)
+		}
+		records[version] = record
+	}
+
+	return records, rows.Err()
+}
+
+// checkDrift compares applied migration files with their recorded checksums.
+// Migrations applied before checksums existed adopt the current checksum.
+func (mm *MigrationManager) checkDrift() error {
+	status, err := mm.GetMigrationStatus(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to check migration checksums: %v", err)
+	}
+
+	var drifted []string
+	for _, s := range status {
+		if !s.Applied || s.Missing || s.Kind == migrationKindGo {
+			continue
+		}
+		if s.AppliedChecksum == "" {
+			if _, err := mm.db.Exec(`UPDATE schema_version SET checksum = $1 WHERE version = $2`, s.Checksum, s.Version); err != nil {
+				return fmt.Errorf("failed to record checksum of migration %s: %v", s.Version, err)
+			}
+			slog.Info("Recorded checksum for previously applied migration", "version", s.Version)
+			continue
+		}
+		if s.Drift {
+			slog.Warn("Migration was modified after it was applied",
+				"version", s.Version, "applied_checksum", s.AppliedChecksum, "checksum", s.Checksum)
+			drifted = append(drifted, s.Version)
+		}
+	}
+
+	if len(drifted) > 0 && mm.driftPolicy == DriftFail {
+		return fmt.Errorf("applied migrations were modified: %s", strings.Join(drifted, ", "))
+	}
+	return nil
+}
+
+// GetMigrationStatus returns the current migration status. It only reads,
+// under the QueryRead timeout.
+func (mm *MigrationManager) GetMigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	ctx, cancel := mm.db.withTimeout(ctx, QueryRead)
+	defer cancel()
+
+	records, err := mm.getAppliedMigrationRecords(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	migrationFiles, err := mm.getMigrationFiles(migrationsDir)
+	migrationFiles, err := mm.getMigrationFiles()
 	if err != nil {
 		return nil, err
 	}
@@ -278,19 +816,74 @@ func (mm *MigrationManager) GetMigrationStatus(migrationsDir string) ([]Migratio
 	sort.Strings(migrationFiles)
 
 	var status []MigrationStatus
+	seen := make(map[string]bool)
 	for _, file := range migrationFiles {
-		applied := appliedMigrations[file]
+		seen[file] = true
+
+		migration, err := mm.loadMigration(file)
+		if err != nil {
+			return nil, err
+		}
+
+		s := MigrationStatus{
+			Version:     file,
+			Kind:        migrationKindSQL,
+			Description: migration.Description,
+		}
+		if migration.IsGo() {
+			s.Kind = migrationKindGo
+		} else {
+			s.Checksum = migration.Checksum()
+		}
+		if record, ok := records[file]; ok {
+			appliedAt := record.AppliedAt
+			s.Applied = true
+			s.AppliedAt = &appliedAt
+			s.AppliedChecksum = record.Checksum
+			s.Drift = record.Checksum != "" && record.Checksum != s.Checksum
+		}
+		status = append(status, s)
+	}
+
+	// Applied migrations whose file has been removed
+	var missing []string
+	for version := range records {
+		if !seen[version] {
+			missing = append(missing, version)
+		}
+	}
+	sort.Strings(missing)
+	for _, version := range missing {
+		record := records[version]
+		appliedAt := record.AppliedAt
 		status = append(status, MigrationStatus{
-			Version: file,
-			Applied: applied,
+			Version:         version,
+			Description:     record.Description,
+			Applied:         true,
+			AppliedAt:       &appliedAt,
+			AppliedChecksum: record.Checksum,
+			Missing:         true,
 		})
 	}
 
 	return status, nil
 }
 
+// Migration kinds reported in MigrationStatus
+const (
+	migrationKindSQL = "sql"
+	migrationKindGo  = "go"
+)
+
 // MigrationStatus represents the status of a migration
 type MigrationStatus struct {
-	Version string
-	Applied bool
+	Version         string     `json:"version"`
+	Kind            string     `json:"kind"` // "sql" or "go"
+	Description     string     `json:"description"`
+	Applied         bool       `json:"applied"`
+	AppliedAt       *time.Time `json:"applied_at"`
+	Checksum        string     `json:"checksum,omitempty"`         // Checksum of the file on disk
+	AppliedChecksum string     `json:"applied_checksum,omitempty"` // Checksum recorded when applied
+	Drift           bool       `json:"drift"`                      // File changed after it was applied
+	Missing         bool       `json:"missing,omitempty"`          // Applied, but the file is gone
 }
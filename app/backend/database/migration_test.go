@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigrationNumbersAreUnique(t *testing.T) {
+	noop := func(*sql.Tx) error { return nil }
+	source := fstest.MapFS{
+		"001_initial_schema.sql": {Data: []byte("-- Initial schema\n")},
+		"002_add_api_keys.sql":   {Data: []byte("-- API keys\n")},
+	}
+
+	tests := []struct {
+		name        string
+		goMigration string
+		wantErr     string
+	}{
+		{name: "SQL only"},
+		{name: "next number", goMigration: "003_split_sensor_rows"},
+		{name: "number of a SQL file", goMigration: "002_split_sensor_rows", wantErr: "share the number 002"},
+		{name: "same version as a SQL file", goMigration: "002_add_api_keys", wantErr: "both as SQL file and Go migration"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.goMigration != "" {
+				RegisterGoMigration(tt.goMigration, tt.name, noop, nil)
+				t.Cleanup(func() { delete(goMigrations, tt.goMigration) })
+			}
+
+			mm := &MigrationManager{source: source, sourceName: "test"}
+			_, err := mm.getMigrationFiles()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("getMigrationFiles: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("getMigrationFiles error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// A Go migration runs in the transaction that records it: its writes and its
+// schema_version row are committed or rolled back together
+func TestGoMigrationUpAndDown(t *testing.T) {
+	source := fstest.MapFS{
+		"001_initial_schema.sql": {Data: []byte("-- Description: Initial schema\nCREATE TABLE readings (id SERIAL);\n-- +migrate Down\nALTER TABLE readings RENAME TO readings_old;\n")},
+	}
+	const version = "002_split_sensor_rows"
+
+	failUp := false
+	up := func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`UPDATE readings SET id = id -- go up`); err != nil {
+			return err
+		}
+		if failUp {
+			return errors.New("row 7 cannot be split")
+		}
+		return nil
+	}
+	down := func(tx *sql.Tx) error {
+		_, err := tx.Exec(`UPDATE readings SET id = id -- go down`)
+		return err
+	}
+	RegisterGoMigration(version, "Split sensor rows", up, down)
+	t.Cleanup(func() { delete(goMigrations, version) })
+
+	newManager := func() (*MigrationManager, *migrationDB) {
+		db, stub := newMigrationDatabase()
+		return &MigrationManager{db: db, source: source, sourceName: "test", driftPolicy: DriftFail}, stub
+	}
+
+	t.Run("up and down", func(t *testing.T) {
+		mm, stub := newManager()
+		if err := mm.RunMigrations(); err != nil {
+			t.Fatalf("RunMigrations: %v", err)
+		}
+		if got := stub.applied(); strings.Join(got, ",") != "001_initial_schema,"+version {
+			t.Fatalf("applied after up = %v", got)
+		}
+		if !stub.ran("go up") {
+			t.Error("Go migration up was not committed")
+		}
+
+		status, err := mm.GetMigrationStatus(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last := status[len(status)-1]; last.Version != version || last.Kind != migrationKindGo || !last.Applied {
+			t.Errorf("status of %s = %+v", version, last)
+		}
+
+		if err := mm.MigrateDown(1); err != nil {
+			t.Fatalf("MigrateDown: %v", err)
+		}
+		if got := stub.applied(); strings.Join(got, ",") != "001_initial_schema" {
+			t.Fatalf("applied after down = %v", got)
+		}
+		if !stub.ran("go down") {
+			t.Error("Go migration down was not committed")
+		}
+	})
+
+	t.Run("failed up is rolled back", func(t *testing.T) {
+		failUp = true
+		t.Cleanup(func() { failUp = false })
+
+		mm, stub := newManager()
+		if err := mm.RunMigrations(); err == nil || !strings.Contains(err.Error(), "row 7") {
+			t.Fatalf("RunMigrations error = %v, want the Go migration error", err)
+		}
+		if got := stub.applied(); strings.Join(got, ",") != "001_initial_schema" {
+			t.Errorf("applied = %v, want only the SQL migration", got)
+		}
+		if stub.ran("go up") {
+			t.Error("writes of the failed Go migration were committed")
+		}
+	})
+}
+
+// Reverting checks for drift like migrating up, and refuses down steps that
+// delete data unless allowed
+func TestRevertChecks(t *testing.T) {
+	initial := "-- Description: Initial schema\nCREATE TABLE readings (id SERIAL);\n-- +migrate Down\nDROP TABLE IF EXISTS readings;\n"
+	index := "-- Description: Index\nCREATE INDEX idx_readings ON readings(id);\n-- +migrate Down\nDROP INDEX IF EXISTS idx_readings;\n"
+
+	tests := []struct {
+		name          string
+		edited        string // Up section of 002 after it was applied
+		revert        func(mm *MigrationManager) error
+		allowDataLoss bool
+		wantErr       string
+		wantDataLoss  bool
+		wantApplied   string
+	}{
+		{name: "down without data loss", revert: func(mm *MigrationManager) error { return mm.MigrateDown(1) }, wantApplied: "001_initial_schema"},
+		{name: "down of a modified migration", edited: "CREATE INDEX idx_readings ON readings(id DESC);\n", revert: func(mm *MigrationManager) error { return mm.MigrateDown(1) }, wantErr: "were modified", wantApplied: "001_initial_schema,002_index"},
+		{name: "down dropping a table", revert: func(mm *MigrationManager) error { return mm.MigrateDown(2) }, wantErr: "DROP TABLE IF EXISTS readings", wantDataLoss: true, wantApplied: "001_initial_schema,002_index"},
+		{name: "to 0 dropping a table", revert: func(mm *MigrationManager) error { return mm.MigrateTo("0") }, wantErr: "DROP TABLE IF EXISTS readings", wantDataLoss: true, wantApplied: "001_initial_schema,002_index"},
+		{name: "data loss allowed", revert: func(mm *MigrationManager) error { return mm.MigrateTo("0") }, allowDataLoss: true, wantApplied: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := fstest.MapFS{
+				"001_initial_schema.sql": {Data: []byte(initial)},
+				"002_index.sql":          {Data: []byte(index)},
+			}
+			db, stub := newMigrationDatabase()
+			mm := &MigrationManager{db: db, source: source, sourceName: "test", driftPolicy: DriftFail}
+			if err := mm.RunMigrations(); err != nil {
+				t.Fatalf("RunMigrations: %v", err)
+			}
+
+			if tt.edited != "" {
+				source["002_index.sql"] = &fstest.MapFile{Data: []byte("-- Description: Index\n" + tt.edited + "-- +migrate Down\nDROP INDEX IF EXISTS idx_readings;\n")}
+			}
+			mm.SetAllowDataLoss(tt.allowDataLoss)
+
+			err := tt.revert(mm)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("revert: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("revert error = %v, want %q", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrDataLoss) != tt.wantDataLoss {
+				t.Errorf("revert error = %v, ErrDataLoss %v", err, tt.wantDataLoss)
+			}
+			if got := strings.Join(stub.applied(), ","); got != tt.wantApplied {
+				t.Errorf("applied = %q, want %q", got, tt.wantApplied)
+			}
+		})
+	}
+}
@@ -11,4 +11,8 @@ CREATE TABLE IF NOT EXISTS temp_sensor_data (
 );
 
 -- Create index for timestamp
-CREATE INDEX IF NOT EXISTS idx_temp_sensor_data_timestamp ON temp_sensor_data(timestamp); 
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_temp_sensor_data_timestamp ON temp_sensor_data(timestamp);
+
+-- +migrate Down
+-- Deletes every reading; migrate down/to refuse it without -confirm-data-loss
+DROP TABLE IF EXISTS temp_sensor_data;
@@ -4,4 +4,9 @@
 
 ALTER TABLE temp_sensor_data
 ADD COLUMN ac_outlet_temperature FLOAT,
-ADD COLUMN ac_outlet_humidity FLOAT;
\ No newline at end of file
+ADD COLUMN ac_outlet_humidity FLOAT;
+
+-- +migrate Down
+ALTER TABLE temp_sensor_data
+DROP COLUMN IF EXISTS ac_outlet_temperature,
+DROP COLUMN IF EXISTS ac_outlet_humidity;
@@ -0,0 +1,19 @@
+-- Migration: 003_add_quality_flags
+-- Description: Store data-quality flags (outlier, interpolated, late, spooled, manual_edit) per reading
+-- Created: 2026-10-18
+
+ALTER TABLE temp_sensor_data
+ADD COLUMN quality_flags TEXT[] NOT NULL DEFAULT '{}';
+
+-- Outliers used to be recomputed on every read; persist them for existing rows
+UPDATE temp_sensor_data
+SET quality_flags = ARRAY['outlier']
+WHERE temperature <= 3.0;
+
+CREATE INDEX IF NOT EXISTS idx_temp_sensor_data_quality_flags ON temp_sensor_data USING GIN (quality_flags);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_temp_sensor_data_quality_flags;
+
+ALTER TABLE temp_sensor_data
+DROP COLUMN IF EXISTS quality_flags;
@@ -0,0 +1,60 @@
+-- Migration: 004_add_sensor_calibration
+-- Description: Add per-sensor calibration history and keep raw sensor values next to calibrated ones
+-- Created: 2026-10-18
+
+CREATE TABLE IF NOT EXISTS sensor_calibration (
+    id SERIAL PRIMARY KEY,
+    sensor VARCHAR(50) NOT NULL,
+    metric VARCHAR(50) NOT NULL,
+    offset_value FLOAT NOT NULL DEFAULT 0,
+    slope FLOAT NOT NULL DEFAULT 1,
+    effective_from TIMESTAMP NOT NULL,
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_calibration_lookup ON sensor_calibration(sensor, metric, effective_from DESC);
+
+-- Raw (uncalibrated) values; existing rows were never calibrated
+ALTER TABLE temp_sensor_data
+ADD COLUMN raw_temperature FLOAT,
+ADD COLUMN raw_humidity FLOAT,
+ADD COLUMN raw_ac_outlet_temperature FLOAT,
+ADD COLUMN raw_ac_outlet_humidity FLOAT;
+
+UPDATE temp_sensor_data
+SET raw_temperature = temperature,
+    raw_humidity = humidity,
+    raw_ac_outlet_temperature = ac_outlet_temperature,
+    raw_ac_outlet_humidity = ac_outlet_humidity;
+
+-- Calibrated value of a raw reading at a given time (raw value if no calibration applies)
+CREATE OR REPLACE FUNCTION apply_sensor_calibration(raw_value FLOAT, p_sensor TEXT, p_metric TEXT, at_time TIMESTAMP)
+RETURNS FLOAT AS $$
+    SELECT COALESCE(
+        (SELECT raw_value * slope + offset_value
+         FROM sensor_calibration
+         WHERE sensor = p_sensor AND metric = p_metric AND effective_from <= at_time
+         ORDER BY effective_from DESC, id DESC
+         LIMIT 1),
+        raw_value)
+$$ LANGUAGE SQL STABLE;
+
+-- +migrate Down
+-- Without calibration history the stored values go back to the raw sensor values
+UPDATE temp_sensor_data
+SET temperature = raw_temperature,
+    humidity = raw_humidity,
+    ac_outlet_temperature = raw_ac_outlet_temperature,
+    ac_outlet_humidity = raw_ac_outlet_humidity
+WHERE raw_temperature IS NOT NULL AND raw_humidity IS NOT NULL;
+
+DROP FUNCTION IF EXISTS apply_sensor_calibration(FLOAT, TEXT, TEXT, TIMESTAMP);
+
+ALTER TABLE temp_sensor_data
+DROP COLUMN IF EXISTS raw_temperature,
+DROP COLUMN IF EXISTS raw_humidity,
+DROP COLUMN IF EXISTS raw_ac_outlet_temperature,
+DROP COLUMN IF EXISTS raw_ac_outlet_humidity;
+
+DROP TABLE IF EXISTS sensor_calibration;
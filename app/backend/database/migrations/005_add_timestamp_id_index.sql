@@ -0,0 +1,8 @@
+-- Migration: 005_add_timestamp_id_index
+-- Description: Add (timestamp, id) index for keyset cursor pagination
+-- Created: 2026-10-18
+
+CREATE INDEX IF NOT EXISTS idx_temp_sensor_data_timestamp_id ON temp_sensor_data(timestamp, id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_temp_sensor_data_timestamp_id;
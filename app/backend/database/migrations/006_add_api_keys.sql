@@ -0,0 +1,19 @@
+-- Migration: 006_add_api_keys
+-- Description: Add hashed API keys with roles for authentication
+-- Created: 2026-10-18
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('viewer', 'operator', 'admin', 'ingest-device')),
+    key_prefix VARCHAR(16) NOT NULL,            -- Shown in listings to identify a key
+    key_hash CHAR(64) NOT NULL UNIQUE,          -- SHA-256 of the full key, the key itself is never stored
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    rotated_from INTEGER REFERENCES api_keys(id)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS api_keys;
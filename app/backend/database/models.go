@@ -3,15 +3,51 @@ package database
 import "time"
 
 type TempSensorData struct {
-	ID                  int                      `json:"id" db:"id"`
-	Temperature         float64                  `json:"temperature" db:"temperature"`
-	Humidity            float64                  `json:"humidity" db:"humidity"`
-	ACOutletTemperature *float64                 `json:"ac_outlet_temperature,omitempty" db:"ac_outlet_temperature"`
-	ACOutletHumidity    *float64                 `json:"ac_outlet_humidity,omitempty" db:"ac_outlet_humidity"`
-	Timestamp           time.Time                `json:"timestamp" db:"timestamp"`
+	ID                     int                      `json:"id" db:"id"`
+	Temperature            float64                  `json:"temperature" db:"temperature"`
+	Humidity               float64                  `json:"humidity" db:"humidity"`
+	ACOutletTemperature    *float64                 `json:"ac_outlet_temperature,omitempty" db:"ac_outlet_temperature"`
+	ACOutletHumidity       *float64                 `json:"ac_outlet_humidity,omitempty" db:"ac_outlet_humidity"`
+	Timestamp              time.Time                `json:"timestamp" db:"timestamp"`
+	RawTemperature         *float64                 `json:"raw_temperature,omitempty" db:"raw_temperature"`
+	RawHumidity            *float64                 `json:"raw_humidity,omitempty" db:"raw_humidity"`
+	RawACOutletTemperature *float64                 `json:"raw_ac_outlet_temperature,omitempty" db:"raw_ac_outlet_temperature"`
+	RawACOutletHumidity    *float64                 `json:"raw_ac_outlet_humidity,omitempty" db:"raw_ac_outlet_humidity"`
+	IsOutlier              bool                     `json:"is_outlier"`
+	Gap                    bool                     `json:"gap,omitempty"` // Sampled slot without a reading
+	QualityFlags           []string                 `json:"quality_flags,omitempty" db:"quality_flags"`
+	Derived                *DerivedMetrics          `json:"derived,omitempty"`
+	ACOutletDerived        *DerivedMetrics          `json:"ac_outlet_derived,omitempty"`
+	DefaultAggregated      *DefaultAggregatedValues `json:"default_aggregated,omitempty"`
+	Aggregated             *AggregatedValues        `json:"aggregated,omitempty"`
+	Bucket                 *BucketAggregates        `json:"bucket,omitempty"` // Requested aggregates over the point's bucket
+}
+
+// NullableTempSensorData is the v2 history point: gaps carry null values
+// instead of zeros and are marked with Gap
+type NullableTempSensorData struct {
+	ID                  *int                     `json:"id"`
+	Temperature         *float64                 `json:"temperature"`
+	Humidity            *float64                 `json:"humidity"`
+	ACOutletTemperature *float64                 `json:"ac_outlet_temperature"`
+	ACOutletHumidity    *float64                 `json:"ac_outlet_humidity"`
+	Timestamp           time.Time                `json:"timestamp"`
+	Gap                 bool                     `json:"gap"`
 	IsOutlier           bool                     `json:"is_outlier"`
+	QualityFlags        []string                 `json:"quality_flags"`
+	Derived             *DerivedMetrics          `json:"derived"`
+	ACOutletDerived     *DerivedMetrics          `json:"ac_outlet_derived"`
 	DefaultAggregated   *DefaultAggregatedValues `json:"default_aggregated,omitempty"`
 	Aggregated          *AggregatedValues        `json:"aggregated,omitempty"`
+	Bucket              *BucketAggregates        `json:"bucket,omitempty"`
+}
+
+// DerivedMetrics are psychrometric values computed from temperature and humidity
+type DerivedMetrics struct {
+	DewPoint         float64 `json:"dew_point"`         // °C
+	AbsoluteHumidity float64 `json:"absolute_humidity"` // g/m³
+	HeatIndex        float64 `json:"heat_index"`        // °C
+	Humidex          float64 `json:"humidex"`           // °C
 }
 
 type DefaultAggregatedValues struct {
@@ -20,8 +56,16 @@ type DefaultAggregatedValues struct {
 }
 
 type AggregatedValues struct {
-	Temperature *TemperatureAggregates `json:"temperature,omitempty"`
-	Humidity    *HumidityAggregates    `json:"humidity,omitempty"`
+	Temperature              *TemperatureAggregates `json:"temperature,omitempty"`
+	Humidity                 *HumidityAggregates    `json:"humidity,omitempty"`
+	DewPoint                 *MetricAggregates      `json:"dew_point,omitempty"`
+	AbsoluteHumidity         *MetricAggregates      `json:"absolute_humidity,omitempty"`
+	HeatIndex                *MetricAggregates      `json:"heat_index,omitempty"`
+	Humidex                  *MetricAggregates      `json:"humidex,omitempty"`
+	ACOutletDewPoint         *MetricAggregates      `json:"ac_outlet_dew_point,omitempty"`
+	ACOutletAbsoluteHumidity *MetricAggregates      `json:"ac_outlet_absolute_humidity,omitempty"`
+	ACOutletHeatIndex        *MetricAggregates      `json:"ac_outlet_heat_index,omitempty"`
+	ACOutletHumidex          *MetricAggregates      `json:"ac_outlet_humidex,omitempty"`
 }
 
 type TemperatureAggregates struct {
@@ -38,6 +82,119 @@ type HumidityAggregates struct {
 	Count   int     `json:"count"` // Number of data points used for calculation
 }
 
+// MetricAggregates holds avg/max/min for a derived metric series
+type MetricAggregates struct {
+	Average float64 `json:"average"`
+	Maximum float64 `json:"maximum"`
+	Minimum float64 `json:"minimum"`
+	Count   int     `json:"count"` // Number of data points used for calculation
+}
+
+// BucketAggregates holds aggregate functions of every reading in [Start, End).
+// Metrics maps a metric (e.g. "ac_outlet_temperature") to function results;
+// functions are null when the bucket has no reading of the metric.
+type BucketAggregates struct {
+	Start   time.Time                      `json:"start"`
+	End     time.Time                      `json:"end"`
+	Metrics map[string]map[string]*float64 `json:"metrics"`
+}
+
+// PeriodStats summarizes one day or week per sensor (main, ac_outlet)
+type PeriodStats struct {
+	Start   time.Time              `json:"start"`
+	End     time.Time              `json:"end"`
+	Sensors map[string]SensorStats `json:"sensors"`
+}
+
+// SensorStats summarizes the readings of one sensor in a period. Outliers
+// count towards Readings but not towards the metric and threshold values.
+type SensorStats struct {
+	Readings         int              `json:"readings"`
+	ExpectedReadings int              `json:"expected_readings"` // Period length / collection interval
+	Completeness     float64          `json:"completeness"`      // Percent of expected readings, at most 100
+	Outliers         int              `json:"outliers"`
+	Temperature      MetricStats      `json:"temperature"`
+	Humidity         MetricStats      `json:"humidity"`
+	Thresholds       []ThresholdStats `json:"thresholds"`
+}
+
+// MetricStats is null throughout when the period has no reading of the metric
+type MetricStats struct {
+	Min  *StatsExtreme `json:"min"`
+	Max  *StatsExtreme `json:"max"`
+	Mean *float64      `json:"mean"`
+}
+
+// StatsExtreme is a minimum or maximum and the first time it occurred
+type StatsExtreme struct {
+	Value     float64   `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ThresholdStats is the time a metric spent above Above and below Below.
+// The seconds are null for a limit that is not set.
+type ThresholdStats struct {
+	Metric       string   `json:"metric"`
+	Above        *float64 `json:"above"`
+	Below        *float64 `json:"below"`
+	SecondsAbove *float64 `json:"seconds_above"`
+	SecondsBelow *float64 `json:"seconds_below"`
+}
+
+// Sensor identifiers used for calibration and per-sensor metadata
+const (
+	SensorMain     = "main"
+	SensorACOutlet = "ac_outlet"
+)
+
+// Measured metrics of a sensor
+const (
+	MetricTemperature = "temperature"
+	MetricHumidity    = "humidity"
+)
+
+// Derived metrics of a sensor, see CalculateDerivedMetrics
+const (
+	MetricDewPoint         = "dew_point"
+	MetricAbsoluteHumidity = "absolute_humidity"
+	MetricHeatIndex        = "heat_index"
+	MetricHumidex          = "humidex"
+)
+
+// SensorCalibration maps a raw value to calibrated = raw*Slope + Offset
+// from EffectiveFrom until the next calibration of the same sensor/metric
+type SensorCalibration struct {
+	ID            int       `json:"id" db:"id"`
+	Sensor        string    `json:"sensor" db:"sensor"`
+	Metric        string    `json:"metric" db:"metric"`
+	Offset        float64   `json:"offset" db:"offset_value"`
+	Slope         float64   `json:"slope" db:"slope"`
+	EffectiveFrom time.Time `json:"effective_from" db:"effective_from"`
+	Note          string    `json:"note" db:"note"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// API key roles. admin can do everything; ingest-device can only push readings.
+const (
+	RoleViewer       = "viewer"
+	RoleOperator     = "operator"
+	RoleAdmin        = "admin"
+	RoleIngestDevice = "ingest-device"
+)
+
+// APIKey is a stored API key; only the SHA-256 hash of the key is kept
+type APIKey struct {
+	ID          int        `json:"id" db:"id"`
+	Name        string     `json:"name" db:"name"`
+	Role        string     `json:"role" db:"role"`
+	KeyPrefix   string     `json:"key_prefix" db:"key_prefix"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at" db:"revoked_at"`
+	RotatedFrom *int       `json:"rotated_from,omitempty" db:"rotated_from"`
+}
+
 type TempAPIResponse struct {
 	Temperature float64 `json:"temperature"`
 	Humidity    float64 `json:"humidity"`
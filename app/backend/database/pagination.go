@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PageCursor is a keyset position in the (timestamp, id) ordering of readings.
+// Pages are ordered newest first; Backward cursors page towards newer readings.
+type PageCursor struct {
+	Timestamp time.Time
+	ID        int
+	Backward  bool
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Encode returns the opaque string form of the cursor
+func (pc PageCursor) Encode() string {
+	direction := "n"
+	if pc.Backward {
+		direction = "p"
+	}
+	raw := fmt.Sprintf("%s|%d|%d", direction, pc.Timestamp.UnixMicro(), pc.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor produced by PageCursor.Encode
+func DecodeCursor(value string) (*PageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 || (parts[0] != "n" && parts[0] != "p") {
+		return nil, errInvalidCursor
+	}
+
+	micros, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &PageCursor{
+		Timestamp: time.UnixMicro(micros).UTC(),
+		ID:        id,
+		Backward:  parts[0] == "p",
+	}, nil
+}
+
+// Page is one page of keyset-paginated readings
+type Page struct {
+	Data       []TempSensorData
+	NextCursor *PageCursor // Older readings, nil on the last page
+	PrevCursor *PageCursor // Newer readings, nil on the first page
+}
+
+// GetTempSensorDataPage returns up to limit readings after the cursor (newest
+// first). A nil cursor starts at the newest reading.
+// Rows arriving while paging never shift or duplicate rows of later pages.
+func (db *Database) GetTempSensorDataPage(ctx context.Context, cursor *PageCursor, limit int, filter QualityFilter) (*Page, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryRead)
+	defer cancel()
+
+	var where, order string
+	args := []interface{}{limit + 1}
+
+	switch {
+	case cursor == nil:
+		where = "WHERE TRUE"
+		order = "timestamp DESC, id DESC"
+	case cursor.Backward:
+		where = "WHERE (timestamp, id) > ($2, $3)"
+		order = "timestamp ASC, id ASC"
+		args = append(args, dbTime(cursor.Timestamp), cursor.ID)
+	default:
+		where = "WHERE (timestamp, id) < ($2, $3)"
+		order = "timestamp DESC, id DESC"
+		args = append(args, dbTime(cursor.Timestamp), cursor.ID)
+	}
+
+	condition, filterArgs := filter.sqlCondition(len(args) + 1)
+	args = append(args, filterArgs...)
+
+	query := `
+	SELECT ` + tempSensorDataColumns + ` 
+	FROM temp_sensor_data 
+	` + where + condition + ` 
+	ORDER BY ` + order + ` 
+	LIMIT $1`
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	data := []TempSensorData{}
+	for rows.Next() {
+		var item TempSensorData
+		if err := scanTempSensorData(rows, &item); err != nil {
+			return nil, err
+		}
+		data = append(data, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	hasMore := len(data) > limit
+	if hasMore {
+		data = data[:limit]
+	}
+
+	backward := cursor != nil && cursor.Backward
+	if backward {
+		// Backward pages are read oldest first; present them newest first like every page
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+
+	data = markOutliers(data)
+	data = addDerivedMetrics(data)
+
+	page := &Page{Data: data}
+	if len(data) == 0 {
+		return page, nil
+	}
+
+	first, last := data[0], data[len(data)-1]
+	if (!backward && hasMore) || backward {
+		page.NextCursor = &PageCursor{Timestamp: last.Timestamp, ID: last.ID}
+	}
+	if (backward && hasMore) || (!backward && cursor != nil) {
+		page.PrevCursor = &PageCursor{Timestamp: first.Timestamp, ID: first.ID, Backward: true}
+	}
+
+	return page, nil
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPageCursorRoundTrip(t *testing.T) {
+	seoul := mustLoadLocation(t, "Asia/Seoul")
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	tests := []struct {
+		name   string
+		cursor PageCursor
+	}{
+		{name: "UTC", cursor: PageCursor{Timestamp: time.Date(2025, 1, 14, 10, 30, 0, 0, time.UTC), ID: 42}},
+		{name: "positive offset", cursor: PageCursor{Timestamp: time.Date(2025, 1, 14, 19, 30, 0, 123456000, seoul), ID: 7}},
+		{name: "negative offset backward", cursor: PageCursor{Timestamp: time.Date(2025, 3, 9, 1, 59, 59, 999999000, newYork), ID: 1, Backward: true}},
+		{name: "zero id", cursor: PageCursor{Timestamp: time.Date(2024, 12, 31, 23, 59, 59, 0, seoul)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := DecodeCursor(tt.cursor.Encode())
+			if err != nil {
+				t.Fatalf("DecodeCursor: %v", err)
+			}
+			if !decoded.Timestamp.Equal(tt.cursor.Timestamp) || decoded.ID != tt.cursor.ID || decoded.Backward != tt.cursor.Backward {
+				t.Errorf("round trip of %+v = %+v", tt.cursor, *decoded)
+			}
+		})
+	}
+
+	// Precision below a microsecond is dropped, like in a TIMESTAMP column
+	precise := time.Date(2025, 1, 14, 10, 30, 0, 123456789, seoul)
+	decoded, err := DecodeCursor(PageCursor{Timestamp: precise}.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := precise.Truncate(time.Microsecond); !decoded.Timestamp.Equal(want) {
+		t.Errorf("decoded timestamp = %v, want %v", decoded.Timestamp, want)
+	}
+}
+
+// A cursor made from a stored reading on a non-UTC server points back at the
+// reading's wall clock in the database
+func TestPageCursorOfStoredReading(t *testing.T) {
+	setLocal(t, "Asia/Seoul")
+	collected := time.Date(2025, 1, 14, 9, 15, 30, 0, time.Local)
+	stored := localTime(storeAndScan(t, dbTime(collected)))
+
+	decoded, err := DecodeCursor(PageCursor{Timestamp: stored, ID: 3}.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dbTime(decoded.Timestamp); got.Format("2006-01-02 15:04:05") != "2025-01-14 09:15:30" {
+		t.Errorf("cursor compares against %v, want the stored wall clock 09:15:30", got)
+	}
+}
+
+func TestDecodeCursorRejectsInvalid(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "empty", value: ""},
+		{name: "not base64", value: "!!not-a-cursor!!"},
+		{name: "padded base64", value: base64.URLEncoding.EncodeToString([]byte("n|1|2"))},
+		{name: "unknown direction", value: encode("x|1736850600000000|42")},
+		{name: "missing id", value: encode("n|1736850600000000")},
+		{name: "extra field", value: encode("n|1736850600000000|42|1")},
+		{name: "timestamp not a number", value: encode("n|2025-01-14T10:30:00Z|42")},
+		{name: "id not a number", value: encode("p|1736850600000000|x")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if cursor, err := DecodeCursor(tt.value); !errors.Is(err, errInvalidCursor) {
+				t.Errorf("DecodeCursor(%q) = %+v, %v, want errInvalidCursor", tt.value, cursor, err)
+			}
+		})
+	}
+}
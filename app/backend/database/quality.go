@@ -0,0 +1,153 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Data-quality flags stored in temp_sensor_data.quality_flags
+const (
+	QualityFlagOutlier      = "outlier"
+	QualityFlagInterpolated = "interpolated"
+	QualityFlagLate         = "late"
+	QualityFlagSpooled      = "spooled"
+	QualityFlagManualEdit   = "manual_edit"
+)
+
+var validQualityFlags = map[string]bool{
+	QualityFlagOutlier:      true,
+	QualityFlagInterpolated: true,
+	QualityFlagLate:         true,
+	QualityFlagSpooled:      true,
+	QualityFlagManualEdit:   true,
+}
+
+// 이상치는 temp가 3도 이내일 때의 온습도
+// dht22자체문제인거같기도
+const outlierTemperatureThreshold = 3.0
+
+// lateReadingThreshold is how far behind the ingestion time a reading may be
+// before it is flagged as late
+const lateReadingThreshold = 5 * time.Minute
+
+// QualityFilter selects readings by their quality flags
+type QualityFilter struct {
+	ExcludeFlags []string // Readings carrying any of these flags are skipped
+	ExcludeAll   bool     // Skip every reading that carries at least one flag
+}
+
+// ParseQualityFilter parses a comma separated flag list ("outlier,late") or "any"
+func ParseQualityFilter(value string) (QualityFilter, error) {
+	var filter QualityFilter
+	if value == "" {
+		return filter, nil
+	}
+	if value == "any" {
+		filter.ExcludeAll = true
+		return filter, nil
+	}
+
+	for _, flag := range strings.Split(value, ",") {
+		flag = strings.TrimSpace(flag)
+		if flag == "" {
+			continue
+		}
+		if !validQualityFlags[flag] {
+			return filter, fmt.Errorf("unknown quality flag %q", flag)
+		}
+		filter.ExcludeFlags = append(filter.ExcludeFlags, flag)
+	}
+	return filter, nil
+}
+
+// sqlCondition returns an "AND ..." fragment for the filter using the given
+// placeholder position, along with its arguments
+func (f QualityFilter) sqlCondition(argPos int) (string, []interface{}) {
+	if f.ExcludeAll {
+		return " AND cardinality(quality_flags) = 0", nil
+	}
+	if len(f.ExcludeFlags) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf(" AND NOT (quality_flags && $%d::text[])", argPos), []interface{}{pq.Array(f.ExcludeFlags)}
+}
+
+func isTemperatureOutlier(temperature float64) bool {
+	return temperature <= outlierTemperatureThreshold
+}
+
+func hasQualityFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func addQualityFlag(flags []string, flag string) []string {
+	if hasQualityFlag(flags, flag) {
+		return flags
+	}
+	return append(flags, flag)
+}
+
+// detectQualityFlags adds the flags that can be derived from the reading itself
+// at ingestion time, keeping any flags the caller already set (spooled, manual_edit, ...)
+func detectQualityFlags(data *TempSensorData, ingestedAt time.Time) {
+	if data.QualityFlags == nil {
+		data.QualityFlags = []string{}
+	}
+	if isTemperatureOutlier(data.Temperature) {
+		data.QualityFlags = addQualityFlag(data.QualityFlags, QualityFlagOutlier)
+	}
+	if ingestedAt.Sub(data.Timestamp) > lateReadingThreshold {
+		data.QualityFlags = addQualityFlag(data.QualityFlags, QualityFlagLate)
+	}
+	data.IsOutlier = hasQualityFlag(data.QualityFlags, QualityFlagOutlier)
+}
+
+// markOutliers sets IsOutlier from the stored quality flags
+func markOutliers(data []TempSensorData) []TempSensorData {
+	for i := range data {
+		data[i].IsOutlier = hasQualityFlag(data[i].QualityFlags, QualityFlagOutlier)
+	}
+	return data
+}
+
+// RedetectQualityFlags re-runs outlier detection over a historical time range
+// and returns the number of readings whose flags changed.
+// Flags that are only known at ingestion time (late, spooled, ...) are left untouched.
+func (db *Database) RedetectQualityFlags(ctx context.Context, startTime, endTime time.Time) (int64, error) {
+	ctx, cancel := db.withTimeout(ctx, QueryBulk)
+	defer cancel()
+
+	return redetectQualityFlags(ctx, db, startTime, endTime)
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func redetectQualityFlags(ctx context.Context, exec execer, startTime, endTime time.Time) (int64, error) {
+	query := `
+	UPDATE temp_sensor_data
+	SET quality_flags = CASE
+		WHEN temperature <= $3 THEN array_append(array_remove(quality_flags, $4), $4)
+		ELSE array_remove(quality_flags, $4)
+	END
+	WHERE timestamp >= $1 AND timestamp <= $2
+	AND (temperature <= $3) <> ($4 = ANY(quality_flags))`
+
+	result, err := exec.ExecContext(ctx, query, dbTime(startTime), dbTime(endTime), outlierTemperatureThreshold, QualityFlagOutlier)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// StatsThreshold is a limit GetTempSensorStats reports the time above or
+// below of, for one sensor metric
+type StatsThreshold struct {
+	Sensor string
+	Metric string // temperature, humidity or a derived metric (dew_point, ...)
+	Above  *float64
+	Below  *float64
+}
+
+// StatsOptions selects the periods and thresholds of GetTempSensorStats
+type StatsOptions struct {
+	Periods    []Bucket
+	Interval   time.Duration // Collection interval: expected readings and the longest time one reading stands for
+	Thresholds []StatsThreshold
+}
+
+// statsSensors are summarized in every period, whether they reported or not
+var statsSensors = []string{SensorMain, SensorACOutlet}
+
+// GetTempSensorStats summarizes every period per sensor: min and max with
+// their time, mean, readings against the collection interval, outliers and
+// the time spent above and below each threshold of the sensor. A reading
+// stands for the time until the sensor's next reading, but at most two
+// collection intervals, so outages do not count as time above or below.
+func (db *Database) GetTempSensorStats(ctx context.Context, opts StatsOptions) ([]PeriodStats, error) {
+	if len(opts.Periods) == 0 {
+		return []PeriodStats{}, nil
+	}
+
+	ctx, cancel := db.withTimeout(ctx, QueryAggregate)
+	defer cancel()
+
+	allData, err := db.getTempSensorDataInRange(ctx, opts.Periods[0].Start, opts.Periods[len(opts.Periods)-1].End, QualityFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]PeriodStats, 0, len(opts.Periods))
+	eachBucket(allData, opts.Periods, func(period Bucket, readings []TempSensorData) {
+		stats := PeriodStats{Start: period.Start, End: period.End, Sensors: make(map[string]SensorStats, len(statsSensors))}
+		for _, sensor := range statsSensors {
+			stats.Sensors[sensor] = sensorStats(readings, period, sensor, opts)
+		}
+		result = append(result, stats)
+	})
+	return result, nil
+}
+
+func sensorStats(readings []TempSensorData, period Bucket, sensor string, opts StatsOptions) SensorStats {
+	stats := SensorStats{Thresholds: []ThresholdStats{}}
+
+	// Readings of the sensor that count for the values, oldest first
+	var valid []TempSensorData
+	for i := range readings {
+		if _, ok := MetricValue(&readings[i], sensor, MetricTemperature); !ok {
+			continue
+		}
+		stats.Readings++
+		if hasQualityFlag(readings[i].QualityFlags, QualityFlagOutlier) {
+			stats.Outliers++
+			continue
+		}
+		valid = append(valid, readings[i])
+	}
+
+	if opts.Interval > 0 {
+		stats.ExpectedReadings = int(math.Ceil(float64(period.End.Sub(period.Start)) / float64(opts.Interval)))
+	}
+	if stats.ExpectedReadings > 0 {
+		completeness := math.Min(100, float64(stats.Readings)*100/float64(stats.ExpectedReadings))
+		stats.Completeness = math.Round(completeness*10) / 10
+	}
+
+	stats.Temperature = metricStats(valid, sensor, MetricTemperature, period.Start.Location())
+	stats.Humidity = metricStats(valid, sensor, MetricHumidity, period.Start.Location())
+
+	for _, threshold := range opts.Thresholds {
+		if threshold.Sensor == sensor {
+			stats.Thresholds = append(stats.Thresholds, thresholdStats(valid, period, threshold, 2*opts.Interval))
+		}
+	}
+	return stats
+}
+
+// metricStats reports the time of the min and max in loc, the period's timezone
+func metricStats(readings []TempSensorData, sensor, metric string, loc *time.Location) MetricStats {
+	var stats MetricStats
+	var sum float64
+	var count int
+	for i := range readings {
+		value, ok := MetricValue(&readings[i], sensor, metric)
+		if !ok {
+			continue
+		}
+		if stats.Min == nil || value < stats.Min.Value {
+			stats.Min = &StatsExtreme{Value: value, Timestamp: readings[i].Timestamp.In(loc)}
+		}
+		if stats.Max == nil || value > stats.Max.Value {
+			stats.Max = &StatsExtreme{Value: value, Timestamp: readings[i].Timestamp.In(loc)}
+		}
+		sum += value
+		count++
+	}
+	if count > 0 {
+		mean := sum / float64(count)
+		stats.Mean = &mean
+	}
+	return stats
+}
+
+// thresholdStats adds up the time each reading stands for while the metric
+// is above or below the limits. The last reading of the period stands for
+// the time until the period ends.
+func thresholdStats(readings []TempSensorData, period Bucket, threshold StatsThreshold, maxStep time.Duration) ThresholdStats {
+	stats := ThresholdStats{Metric: threshold.Metric, Above: threshold.Above, Below: threshold.Below}
+
+	var above, below time.Duration
+	for i := range readings {
+		value, ok := MetricValue(&readings[i], threshold.Sensor, threshold.Metric)
+		if !ok {
+			continue
+		}
+		until := period.End
+		if i+1 < len(readings) {
+			until = readings[i+1].Timestamp
+		}
+		step := until.Sub(readings[i].Timestamp)
+		if maxStep > 0 && step > maxStep {
+			step = maxStep
+		}
+
+		if threshold.Above != nil && value > *threshold.Above {
+			above += step
+		}
+		if threshold.Below != nil && value < *threshold.Below {
+			below += step
+		}
+	}
+
+	if threshold.Above != nil {
+		seconds := math.Round(above.Seconds())
+		stats.SecondsAbove = &seconds
+	}
+	if threshold.Below != nil {
+		seconds := math.Round(below.Seconds())
+		stats.SecondsBelow = &seconds
+	}
+	return stats
+}
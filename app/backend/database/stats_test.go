@@ -0,0 +1,258 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+// readingsEvery returns n main-sensor readings interval apart from start with
+// the given temperatures, repeated
+func readingsEvery(start time.Time, interval time.Duration, n int, temperatures ...float64) []TempSensorData {
+	data := make([]TempSensorData, n)
+	for i := range data {
+		data[i] = TempSensorData{Timestamp: start.Add(time.Duration(i) * interval), Temperature: temperatures[i%len(temperatures)], Humidity: 40}
+	}
+	return data
+}
+
+func TestThresholdStats(t *testing.T) {
+	start := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+	period := Bucket{Start: start, End: start.Add(10 * time.Minute)}
+	interval := 30 * time.Second
+
+	tests := []struct {
+		name      string
+		readings  []TempSensorData
+		threshold StatsThreshold
+		above     *float64
+		below     *float64
+	}{
+		{
+			name:      "alternating",
+			readings:  readingsEvery(start, interval, 20, 28, 20, 24, 24),
+			threshold: StatsThreshold{Sensor: SensorMain, Metric: MetricTemperature, Above: float(27), Below: float(21)},
+			above:     float(150), below: float(150), // 5 readings each, 30s apart
+		},
+		{
+			name:      "only above",
+			readings:  readingsEvery(start, interval, 20, 28),
+			threshold: StatsThreshold{Sensor: SensorMain, Metric: MetricTemperature, Above: float(27)},
+			above:     float(600), // The last reading stands for the time until the period ends
+		},
+		{
+			name:      "limit is exclusive",
+			readings:  readingsEvery(start, interval, 20, 27),
+			threshold: StatsThreshold{Sensor: SensorMain, Metric: MetricTemperature, Above: float(27), Below: float(27)},
+			above:     float(0), below: float(0),
+		},
+		{
+			name: "outage counts at most two intervals",
+			readings: []TempSensorData{
+				{Timestamp: start, Temperature: 30, Humidity: 40},
+				{Timestamp: start.Add(5 * time.Minute), Temperature: 30, Humidity: 40},
+			},
+			threshold: StatsThreshold{Sensor: SensorMain, Metric: MetricTemperature, Above: float(27)},
+			above:     float(120),
+		},
+		{
+			name:      "missing sensor",
+			readings:  readingsEvery(start, interval, 20, 28),
+			threshold: StatsThreshold{Sensor: SensorACOutlet, Metric: MetricTemperature, Above: float(10)},
+			above:     float(0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := thresholdStats(tt.readings, period, tt.threshold, 2*interval)
+			checkSeconds(t, "seconds_above", got.SecondsAbove, tt.above)
+			checkSeconds(t, "seconds_below", got.SecondsBelow, tt.below)
+		})
+	}
+}
+
+func checkSeconds(t *testing.T, name string, got, want *float64) {
+	t.Helper()
+	switch {
+	case want == nil && got != nil:
+		t.Errorf("%s = %v, want null", name, *got)
+	case want != nil && got == nil:
+		t.Errorf("%s = null, want %v", name, *want)
+	case want != nil && *got != *want:
+		t.Errorf("%s = %v, want %v", name, *got, *want)
+	}
+}
+
+func TestSensorStatsCompleteness(t *testing.T) {
+	start := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+	hour := Bucket{Start: start, End: start.Add(time.Hour)}
+	opts := StatsOptions{Interval: 30 * time.Second}
+
+	tests := []struct {
+		name         string
+		period       Bucket
+		readings     []TempSensorData
+		expected     int
+		completeness float64
+	}{
+		{name: "complete", period: hour, readings: readingsEvery(start, 30*time.Second, 120, 24), expected: 120, completeness: 100},
+		{name: "three quarters", period: hour, readings: readingsEvery(start, 30*time.Second, 90, 24), expected: 120, completeness: 75},
+		{name: "rounded", period: hour, readings: readingsEvery(start, 30*time.Second, 20, 24), expected: 120, completeness: 16.7},
+		{name: "capped at 100", period: hour, readings: readingsEvery(start, 15*time.Second, 240, 24), expected: 120, completeness: 100},
+		{name: "empty", period: hour, expected: 120, completeness: 0},
+		{name: "partial period", period: Bucket{Start: start, End: start.Add(45 * time.Second)}, readings: readingsEvery(start, 30*time.Second, 2, 24), expected: 2, completeness: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sensorStats(tt.readings, tt.period, SensorMain, opts)
+			if got.Readings != len(tt.readings) || got.ExpectedReadings != tt.expected || got.Completeness != tt.completeness {
+				t.Errorf("readings %d/%d, completeness %v; want %d/%d, %v",
+					got.Readings, got.ExpectedReadings, got.Completeness, len(tt.readings), tt.expected, tt.completeness)
+			}
+		})
+	}
+}
+
+func TestSensorStatsOutliers(t *testing.T) {
+	start := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+	readings := readingsEvery(start, 30*time.Second, 4, 24, 25, 26, 23)
+	readings[1].Temperature = 1
+	readings[1].QualityFlags = []string{QualityFlagOutlier}
+
+	got := sensorStats(readings, Bucket{Start: start, End: start.Add(2 * time.Minute)}, SensorMain, StatsOptions{Interval: 30 * time.Second})
+	if got.Readings != 4 || got.Outliers != 1 {
+		t.Errorf("readings %d, outliers %d; want 4, 1", got.Readings, got.Outliers)
+	}
+	if got.Temperature.Min.Value != 23 || !got.Temperature.Min.Timestamp.Equal(readings[3].Timestamp) {
+		t.Errorf("min = %+v, want 23 at %v (outlier excluded)", got.Temperature.Min, readings[3].Timestamp)
+	}
+	if want := (24.0 + 26 + 23) / 3; got.Temperature.Max.Value != 26 || *got.Temperature.Mean != want {
+		t.Errorf("max %v, mean %v; want 26, %v", got.Temperature.Max.Value, *got.Temperature.Mean, want)
+	}
+	if ac := sensorStats(readings, Bucket{Start: start, End: start.Add(2 * time.Minute)}, SensorACOutlet, StatsOptions{}); ac.Readings != 0 || ac.Temperature.Min != nil || ac.Temperature.Mean != nil {
+		t.Errorf("ac_outlet without readings = %+v, want empty", ac)
+	}
+}
+
+func TestCalendarBuckets(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	seoul := mustLoadLocation(t, "Asia/Seoul")
+
+	tests := []struct {
+		name   string
+		start  time.Time
+		end    time.Time
+		loc    *time.Location
+		label  string
+		bounds []time.Time // Start of every bucket, then the end of the last
+	}{
+		{
+			name:  "days cut to the range",
+			start: time.Date(2025, 1, 14, 12, 0, 0, 0, seoul), end: time.Date(2025, 1, 16, 6, 0, 0, 0, seoul),
+			loc: seoul, label: "1d",
+			bounds: []time.Time{
+				time.Date(2025, 1, 14, 12, 0, 0, 0, seoul),
+				time.Date(2025, 1, 15, 0, 0, 0, 0, seoul),
+				time.Date(2025, 1, 16, 0, 0, 0, 0, seoul),
+				time.Date(2025, 1, 16, 6, 0, 0, 0, seoul),
+			},
+		},
+		{
+			name:  "UTC range split at Seoul midnight",
+			start: time.Date(2025, 1, 13, 15, 0, 0, 0, time.UTC), end: time.Date(2025, 1, 15, 15, 0, 0, 0, time.UTC),
+			loc: seoul, label: "1d",
+			bounds: []time.Time{
+				time.Date(2025, 1, 14, 0, 0, 0, 0, seoul),
+				time.Date(2025, 1, 15, 0, 0, 0, 0, seoul),
+				time.Date(2025, 1, 16, 0, 0, 0, 0, seoul),
+			},
+		},
+		{
+			name:  "daylight saving day is 23 hours",
+			start: time.Date(2025, 3, 8, 0, 0, 0, 0, newYork), end: time.Date(2025, 3, 10, 0, 0, 0, 0, newYork),
+			loc: newYork, label: "1d",
+			bounds: []time.Time{
+				time.Date(2025, 3, 8, 0, 0, 0, 0, newYork),
+				time.Date(2025, 3, 9, 0, 0, 0, 0, newYork),
+				time.Date(2025, 3, 10, 0, 0, 0, 0, newYork),
+			},
+		},
+		{
+			name:  "weeks start on Monday",
+			start: time.Date(2025, 1, 8, 0, 0, 0, 0, seoul), end: time.Date(2025, 1, 21, 0, 0, 0, 0, seoul),
+			loc: seoul, label: "1w",
+			bounds: []time.Time{
+				time.Date(2025, 1, 8, 0, 0, 0, 0, seoul),
+				time.Date(2025, 1, 13, 0, 0, 0, 0, seoul),
+				time.Date(2025, 1, 20, 0, 0, 0, 0, seoul),
+				time.Date(2025, 1, 21, 0, 0, 0, 0, seoul),
+			},
+		},
+		{
+			name:  "empty range",
+			start: time.Date(2025, 1, 14, 0, 0, 0, 0, seoul), end: time.Date(2025, 1, 14, 0, 0, 0, 0, seoul),
+			loc: seoul, label: "1d",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buckets := CalendarBuckets(tt.start, tt.end, tt.loc, tt.label)
+			var bounds []time.Time
+			for _, bucket := range buckets {
+				bounds = append(bounds, bucket.Start)
+			}
+			if len(buckets) > 0 {
+				bounds = append(bounds, buckets[len(buckets)-1].End)
+			}
+			if len(bounds) != len(tt.bounds) {
+				t.Fatalf("bounds = %v, want %v", bounds, tt.bounds)
+			}
+			for i := range bounds {
+				if !bounds[i].Equal(tt.bounds[i]) {
+					t.Errorf("bound %d = %v, want %v", i, bounds[i], tt.bounds[i])
+				}
+			}
+			for i := 1; i < len(buckets); i++ {
+				if !buckets[i].Start.Equal(buckets[i-1].End) {
+					t.Errorf("bucket %d starts at %v, previous ends at %v", i, buckets[i].Start, buckets[i-1].End)
+				}
+			}
+		})
+	}
+}
+
+// A Seoul day on a UTC server: every reading of the day lands in its period
+func TestStatsPeriodsWithTimezone(t *testing.T) {
+	setLocal(t, "UTC")
+	seoul := mustLoadLocation(t, "Asia/Seoul")
+	day := time.Date(2025, 1, 14, 0, 0, 0, 0, seoul)
+	periods := CalendarBuckets(day, day.AddDate(0, 0, 2), seoul, "1d")
+
+	// Stored readings every 30 minutes over both days; the hottest at 14:00 Seoul on the first
+	var data []TempSensorData
+	for at := day; at.Before(day.AddDate(0, 0, 2)); at = at.Add(30 * time.Minute) {
+		temperature := 24.0
+		if at.Equal(day.Add(14 * time.Hour)) {
+			temperature = 30
+		}
+		data = append(data, TempSensorData{Timestamp: localTime(storeAndScan(t, dbTime(at))), Temperature: temperature, Humidity: 40})
+	}
+
+	var stats []SensorStats
+	eachBucket(data, periods, func(period Bucket, readings []TempSensorData) {
+		stats = append(stats, sensorStats(readings, period, SensorMain, StatsOptions{Interval: 30 * time.Minute}))
+	})
+
+	if len(stats) != 2 || stats[0].Readings != 48 || stats[1].Readings != 48 || stats[0].Completeness != 100 {
+		t.Fatalf("stats = %+v, want two complete days of 48 readings", stats)
+	}
+	if max := stats[0].Temperature.Max; max.Value != 30 || !max.Timestamp.Equal(day.Add(14*time.Hour)) || max.Timestamp.Location() != seoul {
+		t.Errorf("max = %+v, want 30 at 14:00 Seoul", max)
+	}
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// streamBatchSize is the number of rows fetched per round trip from the server-side cursor
+const streamBatchSize = 1000
+
+// StreamTempSensorData reads every reading in a time range (oldest first)
+// through a server-side cursor and calls fn for each one.
+// Memory use is bounded by streamBatchSize regardless of the range size.
+func (db *Database) StreamTempSensorData(ctx context.Context, startTime, endTime time.Time, filter QualityFilter, fn func(*TempSensorData) error) error {
+	ctx, cancel := db.withTimeout(ctx, QueryExport)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Read-only transaction; rolling back also closes the cursor
+	defer tx.Rollback()
+
+	condition, args := filter.sqlCondition(3)
+	declare := `
+	DECLARE temp_sensor_data_stream NO SCROLL CURSOR FOR 
+	SELECT ` + tempSensorDataColumns + ` 
+	FROM temp_sensor_data 
+	WHERE timestamp >= $1 AND timestamp <= $2` + condition + ` 
+	ORDER BY timestamp ASC, id ASC`
+
+	if _, err := tx.ExecContext(ctx, declare, append([]interface{}{dbTime(startTime), dbTime(endTime)}, args...)...); err != nil {
+		return fmt.Errorf("failed to declare cursor: %w", err)
+	}
+
+	fetch := fmt.Sprintf("FETCH %d FROM temp_sensor_data_stream", streamBatchSize)
+	for {
+		rows, err := tx.QueryContext(ctx, fetch)
+		if err != nil {
+			return fmt.Errorf("failed to fetch from cursor: %w", err)
+		}
+
+		fetched := 0
+		for rows.Next() {
+			var item TempSensorData
+			if err := scanTempSensorData(rows, &item); err != nil {
+				rows.Close()
+				return err
+			}
+			item.IsOutlier = hasQualityFlag(item.QualityFlags, QualityFlagOutlier)
+			if err := fn(&item); err != nil {
+				rows.Close()
+				return err
+			}
+			fetched++
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return err
+		}
+		rows.Close()
+
+		if fetched < streamBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// migrationDB is a database/sql connector standing in for PostgreSQL in
+// migration tests. It keeps schema_version in memory and records every other
+// statement; writes inside a transaction take effect only on commit.
+type migrationDB struct {
+	mu         sync.Mutex
+	versions   map[string]string // Applied version -> checksum
+	statements []string          // Committed statements other than schema_version bookkeeping
+}
+
+func newMigrationDatabase() (*Database, *migrationDB) {
+	stub := &migrationDB{versions: make(map[string]string)}
+	return &Database{DB: sql.OpenDB(stub)}, stub
+}
+
+// applied returns the applied versions, oldest first
+func (s *migrationDB) applied() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions := make([]string, 0, len(s.versions))
+	for version := range s.versions {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// ran reports whether a committed statement contains match
+func (s *migrationDB) ran(match string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, statement := range s.statements {
+		if strings.Contains(statement, match) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *migrationDB) Connect(context.Context) (driver.Conn, error) {
+	return &migrationConn{db: s}, nil
+}
+
+func (s *migrationDB) Driver() driver.Driver { return migrationDriver{s} }
+
+type migrationDriver struct{ db *migrationDB }
+
+func (d migrationDriver) Open(string) (driver.Conn, error) { return &migrationConn{db: d.db}, nil }
+
+// migrationConn holds the writes of its open transaction until it ends
+type migrationConn struct {
+	db      *migrationDB
+	inTx    bool
+	pending []func()
+}
+
+func (c *migrationConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *migrationConn) Close() error { return nil }
+
+func (c *migrationConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	return migrationTx{c}, nil
+}
+
+// write applies fn now, or on commit inside a transaction
+func (c *migrationConn) write(fn func()) {
+	if c.inTx {
+		c.pending = append(c.pending, fn)
+		return
+	}
+	c.db.mu.Lock()
+	fn()
+	c.db.mu.Unlock()
+}
+
+func (c *migrationConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	switch {
+	case strings.Contains(query, "INSERT INTO schema_version"):
+		version, checksum := args[0].Value.(string), args[3].Value
+		c.write(func() {
+			c.db.versions[version], _ = checksum.(string)
+		})
+	case strings.Contains(query, "DELETE FROM schema_version"):
+		version := args[0].Value.(string)
+		c.db.mu.Lock()
+		_, found := c.db.versions[version]
+		c.db.mu.Unlock()
+		if !found {
+			return driver.RowsAffected(0), nil
+		}
+		c.write(func() { delete(c.db.versions, version) })
+	case strings.Contains(query, "advisory"), strings.Contains(query, "schema_version"):
+	default:
+		c.write(func() { c.db.statements = append(c.db.statements, query) })
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *migrationConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	switch {
+	case strings.Contains(query, "pg_try_advisory_lock"):
+		return &migrationRows{columns: []string{"acquired"}, rows: [][]driver.Value{{true}}}, nil
+	case strings.Contains(query, "information_schema.columns"):
+		return &migrationRows{columns: []string{"exists", "has_checksum"}, rows: [][]driver.Value{{true, true}}}, nil
+	case strings.Contains(query, "SELECT EXISTS(SELECT 1 FROM schema_version"):
+		_, found := c.db.versions[args[0].Value.(string)]
+		return &migrationRows{columns: []string{"exists"}, rows: [][]driver.Value{{found}}}, nil
+	case strings.Contains(query, "SELECT version, COALESCE(description, '')"):
+		rows := &migrationRows{columns: []string{"version", "description", "applied_at", "checksum"}}
+		for version, checksum := range c.db.versions {
+			rows.rows = append(rows.rows, []driver.Value{version, "", time.Now(), checksum})
+		}
+		return rows, nil
+	case strings.Contains(query, "SELECT version FROM schema_version"):
+		rows := &migrationRows{columns: []string{"version"}}
+		for version := range c.db.versions {
+			rows.rows = append(rows.rows, []driver.Value{version})
+		}
+		return rows, nil
+	}
+	return &migrationRows{}, nil
+}
+
+type migrationTx struct{ conn *migrationConn }
+
+func (tx migrationTx) Commit() error {
+	tx.conn.db.mu.Lock()
+	for _, fn := range tx.conn.pending {
+		fn()
+	}
+	tx.conn.db.mu.Unlock()
+	return tx.Rollback()
+}
+
+func (tx migrationTx) Rollback() error {
+	tx.conn.inTx, tx.conn.pending = false, nil
+	return nil
+}
+
+type migrationRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *migrationRows) Columns() []string { return r.columns }
+func (r *migrationRows) Close() error      { return nil }
+
+func (r *migrationRows) Next(dest []driver.Value) error {
+	if r.next == len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// QueryClass groups queries that share a statement timeout
+type QueryClass int
+
+const (
+	QueryRead      QueryClass = iota // Latest reading, pages, counts, keys and calibrations
+	QueryAggregate                   // History sampling and window aggregates
+	QueryWrite                       // Single inserts and key changes
+	QueryBulk                        // Range updates, retention deletes and import batches
+	QueryExport                      // Streaming exports, including writing to the client
+)
+
+// QueryTimeouts bounds each query class. When a timeout or the caller's
+// context ends, lib/pq cancels the statement on the server too. Zero disables
+// the timeout of a class.
+type QueryTimeouts struct {
+	Read      time.Duration
+	Aggregate time.Duration
+	Write     time.Duration
+	Bulk      time.Duration
+	Export    time.Duration
+}
+
+func DefaultQueryTimeouts() QueryTimeouts {
+	return QueryTimeouts{
+		Read:      5 * time.Second,
+		Aggregate: 30 * time.Second,
+		Write:     5 * time.Second,
+		Bulk:      5 * time.Minute,
+		Export:    30 * time.Minute,
+	}
+}
+
+func (t QueryTimeouts) forClass(class QueryClass) time.Duration {
+	switch class {
+	case QueryRead:
+		return t.Read
+	case QueryAggregate:
+		return t.Aggregate
+	case QueryWrite:
+		return t.Write
+	case QueryBulk:
+		return t.Bulk
+	case QueryExport:
+		return t.Export
+	}
+	return 0
+}
+
+// SetQueryTimeouts replaces the timeouts; call before serving requests
+func (db *Database) SetQueryTimeouts(timeouts QueryTimeouts) {
+	db.timeouts = timeouts
+}
+
+// withTimeout bounds ctx by the timeout of class. An earlier deadline of the
+// caller (e.g. a nested call) is kept.
+func (db *Database) withTimeout(ctx context.Context, class QueryClass) (context.Context, context.CancelFunc) {
+	timeout := db.timeouts.forClass(class)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// IsQueryTimeout reports whether err comes from a query that ran out of time or
+// was cancelled. lib/pq reports a cancelled statement as query_canceled rather
+// than the context error.
+func IsQueryTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return true
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "57014"
+}
+
+// PoolConfig sizes the connection pool; zero values keep the database/sql defaults
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func (db *Database) ConfigurePool(pool PoolConfig) {
+	if pool.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+}
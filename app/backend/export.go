@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"knet_management/database"
+	"knet_management/service"
+)
+
+// runExport writes a time range of readings to a file or stdout
+//
+//	main export -start 2025-01-01T00:00:00Z -end 2025-04-01T00:00:00Z -format parquet -out q1.parquet
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	start := fs.String("start", "", "Start time (RFC3339, required)")
+	end := fs.String("end", "", "End time (RFC3339), defaults to now")
+	format := fs.String("format", "csv", "Output format: csv, ndjson, parquet")
+	sensor := fs.String("sensor", "", "Comma separated sensors: main, ac_outlet (default all)")
+	metric := fs.String("metric", "", "Comma separated metrics (default all)")
+	resolution := fs.String("resolution", "raw", "raw or rollup bucket: 1m, 5m, 15m, 1h, 1d")
+	excludeFlags := fs.String("exclude-flags", "", "Quality flags to exclude, or any")
+	out := fs.String("out", "-", "Output file (- for stdout)")
+	fs.Parse(args)
+
+	startTime, err := time.Parse(time.RFC3339, *start)
+	if err != nil {
+		fatal("Invalid -start, use RFC3339", "error", err)
+	}
+	endTime := time.Now()
+	if *end != "" {
+		if endTime, err = time.Parse(time.RFC3339, *end); err != nil {
+			fatal("Invalid -end, use RFC3339", "error", err)
+		}
+	}
+
+	opts := service.ExportOptions{StartTime: startTime, EndTime: endTime}
+	if opts.Format, err = service.ParseExportFormat(*format); err != nil {
+		fatal("Invalid -format", "error", err)
+	}
+	if opts.Resolution, err = service.ParseExportResolution(*resolution); err != nil {
+		fatal("Invalid -resolution", "error", err)
+	}
+	if opts.Sensors, err = service.ParseExportSensors(*sensor); err != nil {
+		fatal("Invalid -sensor", "error", err)
+	}
+	if opts.Metrics, err = service.ParseExportMetrics(*metric); err != nil {
+		fatal("Invalid -metric", "error", err)
+	}
+	if opts.Filter, err = database.ParseQualityFilter(*excludeFlags); err != nil {
+		fatal("Invalid -exclude-flags", "error", err)
+	}
+
+	var output io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fatal("Failed to create output file", "path", *out, "error", err)
+		}
+		defer f.Close()
+		output = f
+	}
+
+	db := connectDatabase(loadConfig().Database)
+	defer db.Close()
+
+	written, err := service.NewTempSensorDataExporter(db).Export(context.Background(), output, opts)
+	if err != nil {
+		fatal("Export failed", "records", written, "error", err)
+	}
+	slog.Info("Export completed", "records", written)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"time"
+
+	"knet_management/service"
+)
+
+// runImport loads historical readings from a CSV or NDJSON file
+//
+//	knet_management import -file old.csv -timezone Asia/Seoul -mapping "timestamp=Time,temperature=Temp"
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	file := fs.String("file", "-", "CSV or NDJSON file to import (- for stdin)")
+	format := fs.String("format", "csv", "Input format: csv, ndjson")
+	mapping := fs.String("mapping", "", "Column mapping, e.g. timestamp=Time,temperature=Temp (C)")
+	timezone := fs.String("timezone", "UTC", "IANA timezone for timestamps without an offset")
+	layout := fs.String("timestamp-layout", "", "Go time layout of the timestamp column, or unix")
+	batchSize := fs.Int("batch-size", 500, "Readings per insert transaction")
+	dryRun := fs.Bool("dry-run", false, "Validate only, do not write")
+	fs.Parse(args)
+
+	parsedFormat, err := service.ParseImportFormat(*format)
+	if err != nil {
+		fatal("Invalid format", "error", err)
+	}
+
+	columnMapping, err := service.ParseColumnMapping(*mapping)
+	if err != nil {
+		fatal("Invalid mapping", "error", err)
+	}
+
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		fatal("Invalid timezone", "error", err)
+	}
+
+	var input io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			fatal("Failed to open input file", "path", *file, "error", err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	db := connectDatabase(loadConfig().Database)
+	defer db.Close()
+
+	report, importErr := service.NewTempSensorDataImporter(db).Import(context.Background(), input, service.ImportOptions{
+		Format:          parsedFormat,
+		ColumnMapping:   columnMapping,
+		Location:        location,
+		TimestampLayout: *layout,
+		BatchSize:       *batchSize,
+		DryRun:          *dryRun,
+	})
+
+	if report != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	}
+	if importErr != nil {
+		fatal("Import failed", "error", importErr)
+	}
+}
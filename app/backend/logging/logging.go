@@ -0,0 +1,101 @@
+// Package logging sets up the process-wide log/slog logger. Records logged
+// with a context carry the request ID stored in it by the API middleware.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// level is shared by every handler so it can be changed on config reload
+var level = new(slog.LevelVar)
+
+// Setup installs the default logger. format is "json" or "text"; level is
+// debug, info, warn or error. The standard log package is routed through it
+// as info records.
+func Setup(w io.Writer, format, logLevel string) error {
+	parsed, err := ParseLevel(logLevel)
+	if err != nil {
+		return err
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "json", "":
+		handler = slog.NewJSONHandler(w, options)
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return fmt.Errorf("unknown log format %q. Use: json, text", format)
+	}
+
+	level.Set(parsed)
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	return nil
+}
+
+// SetLevel changes the level of the logger installed by Setup
+func SetLevel(logLevel string) error {
+	parsed, err := ParseLevel(logLevel)
+	if err != nil {
+		return err
+	}
+	if parsed != level.Level() {
+		slog.Info("Log level changed", "from", level.Level().String(), "to", parsed.String())
+		level.Set(parsed)
+	}
+	return nil
+}
+
+// ParseLevel parses debug, info, warn (warning) or error
+func ParseLevel(logLevel string) (slog.Level, error) {
+	switch strings.ToLower(logLevel) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q. Use: debug, info, warn, error", logLevel)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID stored in ctx, if any
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// contextHandler adds request_id to records logged with a request context
+// (slog.InfoContext and friends)
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -1,94 +1,123 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
-	"time"
 
-	"knet_management/api"
+	"knet_management/config"
 	"knet_management/database"
-	"knet_management/service"
+	"knet_management/logging"
 
 	"github.com/joho/godotenv"
 )
 
-func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
-	}
+const usage = `Usage: main [-config config.yaml] <command> [arguments]
 
-	// 환경변수들은 docker compose에서..
-	// Database configuration
-	dbHost := getEnv("DB_HOST")
-	dbPort := getEnv("DB_PORT")
-	dbUser := getEnv("DB_USER")
-	dbPassword := getEnv("DB_PASSWORD")
-	dbName := getEnv("DB_NAME")
-
-	// Sensor configuration
-	sensorHost := getEnv("TEMP_SENSOR_HOST")
-	sensorPort := getEnv("TEMP_SENSOR_PORT")
-	sensorPath := getEnv("TEMP_SENSOR_PATH")
-	acOutletSensorHost := getEnv("AC_OUTLET_SENSOR_HOST")
-	acOutletSensorPort := getEnv("AC_OUTLET_SENSOR_PORT")
-	acOutletSensorPath := getEnv("AC_OUTLET_SENSOR_PATH")
-	collectionInterval := getEnv("TEMP_COLLECTION_INTERVAL")
-
-	// Server configuration
-	serverPort := getEnv("SERVER_PORT")
-
-	interval, err := time.ParseDuration(collectionInterval)
-	if err != nil {
-		log.Printf("Invalid collection interval '%s', using default 30s", collectionInterval)
-		interval = 30 * time.Second
-	}
+Commands:
+  serve                      Run migrations, collect periodically and serve the API (default)
+  migrate up|down|to|status  Manage database migrations
+  collect once               Collect one reading from the sensors and exit
+  export                     Export readings as CSV, NDJSON or Parquet
+  import                     Import historical readings from CSV or NDJSON
+  sensors list               List configured sensors with their latest reading
+  apikeys <action>           Create, list, rotate or revoke API keys
+  openapi print|check        Print the OpenAPI document or check the API against it
+  oidc-issuer                Run a stand-in OIDC issuer for local development
 
-	// init func should be separated.... but.. 미래의 제가 해주겠죠?
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
+Run "main <command> -h" for the flags of a command.
 
-	db, err := database.NewDatabase(dsn)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
-	}
-	defer db.Close()
+The configuration is read from -config or CONFIG_FILE (YAML, optional);
+environment variables such as DB_HOST override the file.
+`
 
-	log.Println("Connected to database successfully")
+func main() {
+	if err := godotenv.Load(); err != nil {
+		slog.Info("No .env file found, using system environment variables")
+	}
 
-	// Run database migrations
-	migrationManager := database.NewMigrationManager(db)
-	migrationsDir := "/app/migrations" // Docker container 내부 경로
+	flag.StringVar(&configPath, "config", os.Getenv("CONFIG_FILE"), "YAML configuration file")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
 
-	if err := migrationManager.RunMigrations(migrationsDir); err != nil {
-		log.Fatalf("Failed to run database migrations: %v", err)
+	// No command keeps the original container behaviour (CMD ["./main"])
+	command := "serve"
+	var args []string
+	if flag.NArg() > 0 {
+		command, args = flag.Arg(0), flag.Args()[1:]
 	}
 
-	log.Println("Database migrations completed successfully")
-
-	sensorURL := fmt.Sprintf("http://%s:%s%s", sensorHost, sensorPort, sensorPath)
-	acOutletSensorURL := fmt.Sprintf("http://%s:%s%s", acOutletSensorHost, acOutletSensorPort, acOutletSensorPath)
-	collector := service.NewTempSensorDataCollector(sensorURL, acOutletSensorURL, db)
+	switch command {
+	case "serve":
+		runServe(args)
+	case "migrate":
+		runMigrate(args)
+	case "collect":
+		runCollect(args)
+	case "export":
+		runExport(args)
+	case "import":
+		runImport(args)
+	case "sensors":
+		runSensors(args)
+	case "apikeys":
+		runAPIKeys(args)
+	case "openapi":
+		runOpenAPI(args)
+	case "oidc-issuer":
+		runOIDCIssuer(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+}
 
-	log.Printf("Starting periodic data collection from %s & %s every %v", sensorURL, acOutletSensorURL, interval)
-	collector.StartPeriodicTempCollection(interval)
+// configPath is the YAML config file (-config or CONFIG_FILE); empty uses
+// defaults and environment variables only
+var configPath string
 
-	if err := collector.CollectTempData(); err != nil {
-		log.Printf("Initial data collection failed: %v", err)
+// loadConfig loads and validates the configuration or exits with every problem found,
+// and installs the configured logger
+func loadConfig() *config.Config {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fatal("Failed to load configuration", "error", err)
+	}
+	if err := logging.Setup(os.Stderr, cfg.Logging.Format, cfg.Logging.Level); err != nil {
+		fatal("Failed to set up logging", "error", err)
+	}
+	if configPath != "" {
+		slog.Info("Loaded configuration", "path", configPath)
 	}
+	return cfg
+}
 
-	router := api.SetupRoutes(db)
-	log.Printf("Starting server on port %s", serverPort)
+// fatal logs msg at error level and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
 
-	if err := router.Run(":" + serverPort); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+// connectDatabase connects using the database section of the configuration
+func connectDatabase(cfg config.DatabaseConfig) *database.Database {
+	db, err := database.NewDatabase(cfg.DSN())
+	if err != nil {
+		fatal("Failed to connect to database", "host", cfg.Host, "database", cfg.Name, "error", err)
 	}
+	db.SetQueryTimeouts(cfg.Timeouts.QueryTimeouts())
+	db.ConfigurePool(cfg.Pool.PoolConfig())
+
+	slog.Info("Connected to database successfully", "host", cfg.Host, "database", cfg.Name)
+	return db
 }
 
-func getEnv(key string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
-	}
-	log.Fatalf("Environment variable %s is not set", key)
-	return ""
+// sensorURLs returns the main and AC outlet sensor URLs; the AC outlet URL is
+// empty when that sensor is not configured
+func sensorURLs(cfg *config.Config) (string, string) {
+	mainSensor, _ := cfg.Sensor(database.SensorMain)
+	acOutletSensor, _ := cfg.Sensor(database.SensorACOutlet)
+	return mainSensor.URL, acOutletSensor.URL
 }
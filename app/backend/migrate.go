@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"knet_management/config"
+	"knet_management/database"
+)
+
+// runMigrate manages migrations without starting the server
+//
+//	main migrate up|status [-dir ./database/migrations]
+//	main migrate down [N] [-confirm-data-loss] [-dir ./database/migrations]
+//	main migrate to <version> [-confirm-data-loss] [-dir ./database/migrations]
+//
+// Without -dir the migrations embedded in the binary are used. Reverting a
+// migration that drops tables or columns needs -confirm-data-loss.
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fatal("Usage: main migrate up|down [N]|to <version>|status [-dir <migrations dir>]")
+	}
+
+	action, args := args[0], args[1:]
+
+	// Optional positional argument before the flags (down N, to <version>)
+	var positional string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		positional, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("migrate "+action, flag.ExitOnError)
+	migrationsDir := fs.String("dir", "", "Directory containing migration SQL files (default: embedded migrations)")
+	confirmDataLoss := fs.Bool("confirm-data-loss", false, "Allow down migrations that drop tables or columns or delete rows")
+	fs.Parse(args)
+
+	cfg := loadConfig()
+	db := connectDatabase(cfg.Database)
+	defer db.Close()
+
+	migrationManager := newMigrationManager(db, cfg.Server, *migrationsDir)
+	migrationManager.SetAllowDataLoss(*confirmDataLoss)
+
+	switch action {
+	case "up":
+		if err := migrationManager.RunMigrations(); err != nil {
+			fatal("Failed to run database migrations", "error", err)
+		}
+	case "down":
+		steps := 1
+		if positional != "" {
+			n, err := strconv.Atoi(positional)
+			if err != nil || n <= 0 {
+				fatal("Invalid number of migrations to revert", "steps", positional)
+			}
+			steps = n
+		}
+		if err := migrationManager.MigrateDown(steps); err != nil {
+			if errors.Is(err, database.ErrDataLoss) {
+				fatal("Refusing to delete data without -confirm-data-loss", "error", err)
+			}
+			fatal("Failed to revert database migrations", "steps", steps, "error", err)
+		}
+	case "to":
+		if positional == "" {
+			fatal("Usage: main migrate to <version> (e.g. 003 or 0 to revert everything)")
+		}
+		if err := migrationManager.MigrateTo(positional); err != nil {
+			if errors.Is(err, database.ErrDataLoss) {
+				fatal("Refusing to delete data without -confirm-data-loss", "error", err)
+			}
+			fatal("Failed to migrate", "version", positional, "error", err)
+		}
+	case "status":
+		status, err := migrationManager.GetMigrationStatus(context.Background())
+		if err != nil {
+			fatal("Failed to get migration status", "error", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tKIND\tAPPLIED\tAPPLIED AT\tDRIFT\tDESCRIPTION")
+		for _, s := range status {
+			appliedAt := "-"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			drift := "-"
+			switch {
+			case s.Missing:
+				drift = "file missing"
+			case s.Drift:
+				drift = "modified"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%v\t%s\t%s\t%s\n", s.Version, s.Kind, s.Applied, appliedAt, drift, s.Description)
+		}
+		w.Flush()
+	default:
+		fatal("Unknown migrate action. Use: up, down, to, status", "action", action)
+	}
+}
+
+// newMigrationManager creates a migration manager with the configured drift
+// policy (warn by default, fail refuses to migrate). The embedded migrations
+// are used unless dir or server.migrations_dir names a directory.
+func newMigrationManager(db *database.Database, cfg config.ServerConfig, dir string) *database.MigrationManager {
+	policy, err := database.ParseDriftPolicy(cfg.MigrationDriftPolicy)
+	if err != nil {
+		fatal("Invalid migration drift policy", "error", err)
+	}
+
+	migrationManager := database.NewMigrationManager(db)
+	migrationManager.SetDriftPolicy(policy)
+
+	if dir == "" {
+		dir = cfg.MigrationsDir
+	}
+	if dir != "" {
+		migrationManager.SetMigrationsDir(dir)
+	}
+	return migrationManager
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+
+	"knet_management/auth/oidctest"
+)
+
+// runOIDCIssuer serves a local stand-in OIDC issuer for development, so bearer
+// token authentication can be tried without the real SSO:
+//
+//	main oidc-issuer -addr :38380 -url http://localhost:38380
+//	OIDC_ISSUER_URL=http://localhost:38380 main serve
+//	curl "http://localhost:38380/token?sub=alice&roles=operator"
+func runOIDCIssuer(args []string) {
+	fs := flag.NewFlagSet("oidc-issuer", flag.ExitOnError)
+	addr := fs.String("addr", ":38380", "Listen address")
+	url := fs.String("url", "http://localhost:38380", "Issuer URL as seen by the backend (iss claim)")
+	fs.Parse(args)
+
+	issuer, err := oidctest.NewIssuer(*url)
+	if err != nil {
+		fatal("Failed to create issuer", "error", err)
+	}
+
+	slog.Info("Stand-in OIDC issuer listening (development only)", "issuer", issuer.URL(), "addr", *addr)
+	slog.Info("Get a token with curl", "url", issuer.URL()+"/token?sub=alice&roles=operator")
+	if err := http.ListenAndServe(*addr, issuer); err != nil {
+		fatal("Issuer stopped", "error", err)
+	}
+}
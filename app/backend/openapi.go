@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	"knet_management/api"
+	"knet_management/database"
+)
+
+// runOpenAPI prints the OpenAPI document or checks the API against it
+//
+//	main openapi print   Write /openapi.json to stdout
+//	main openapi check   Call the API in-process against the configured
+//	                     database and validate every response; exits 1 on drift
+func runOpenAPI(args []string) {
+	if len(args) != 1 {
+		fatal("Usage: main openapi print|check")
+	}
+
+	switch args[0] {
+	case "print":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(api.Spec()); err != nil {
+			fatal("Failed to encode the OpenAPI document", "error", err)
+		}
+	case "check":
+		if !checkContract(os.Stdout) {
+			os.Exit(1)
+		}
+	default:
+		fatal("Unknown openapi action. Use: print, check", "action", args[0])
+	}
+}
+
+// contractCase is one request of the contract check. Only requests that do
+// not change data are sent: reads, dry runs and rejected writes.
+type contractCase struct {
+	method      string
+	route       string // gin route the request matches
+	query       string
+	contentType string
+	body        string
+}
+
+func contractCases() []contractCase {
+	end := time.Now().UTC().Truncate(time.Second)
+	start := end.Add(-time.Hour)
+	timeRange := "start_time=" + start.Format(time.RFC3339) + "&end_time=" + end.Format(time.RFC3339)
+	importCSV := "timestamp,temperature,humidity\n" + start.Format(time.RFC3339) + ",23.5,50.1\nnot-a-time,1,2\n"
+
+	cases := []contractCase{
+		{method: http.MethodGet, route: "/health"},
+		{method: http.MethodGet, route: "/livez"},
+		{method: http.MethodGet, route: "/readyz"},
+		{method: http.MethodGet, route: "/openapi.json"},
+	}
+	for _, prefix := range []string{"/api", "/api/v2"} {
+		cases = append(cases,
+			contractCase{method: http.MethodGet, route: prefix + "/temp/latest"},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/history"},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/history", query: "limit=5&offset=1"},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/history", query: "limit=5&term=2"},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/history", query: "limit=5&pagination=cursor"},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/history", query: "limit=20&time_period=1d&include_aggregates=true"},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/history", query: "limit=10&fill=linear&" + timeRange},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/history", query: "limit=24&time_period=yesterday&timezone=Asia/Seoul"},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/history", query: "limit=12&time_period=1d&aggregate=mean,p95,count,twa&aggregate_metrics=temperature,ac_outlet_dew_point"},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/history", query: "time_period=1w&downsample=lttb&downsample_metric=ac_outlet_temperature"},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/history", query: "time_period=1w&downsample=minmax&aggregate=max"},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/history", query: "time_period=2x"},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/stats"},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/stats", query: "period=weekly&time_period=last_month&timezone=Asia/Seoul"},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/stats", query: "period=monthly"},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/export", query: "format=ndjson&" + timeRange},
+			contractCase{method: http.MethodGet, route: prefix + "/temp/export", query: "format=xml&" + timeRange},
+			contractCase{method: http.MethodPost, route: prefix + "/temp/import", query: "dry_run=true", contentType: "text/csv", body: importCSV},
+			contractCase{method: http.MethodPost, route: prefix + "/temp/import", query: "format=xml", contentType: "text/csv"},
+			contractCase{method: http.MethodGet, route: prefix + "/migrations/status"},
+			contractCase{method: http.MethodPost, route: prefix + "/quality/redetect"},
+			contractCase{method: http.MethodGet, route: prefix + "/calibrations"},
+			contractCase{method: http.MethodPost, route: prefix + "/calibrations", contentType: "application/json", body: "{}"},
+			contractCase{method: http.MethodPost, route: prefix + "/calibrations/recompute", query: "start_time=yesterday"},
+			contractCase{method: http.MethodGet, route: prefix + "/keys"},
+			contractCase{method: http.MethodPost, route: prefix + "/keys", contentType: "application/json", body: `{"name": "contract", "role": "root"}`},
+			contractCase{method: http.MethodPost, route: prefix + "/keys/:id/rotate"},
+			contractCase{method: http.MethodDelete, route: prefix + "/keys/:id"},
+		)
+	}
+	return cases
+}
+
+// checkContract sends every contract case through the router as an anonymous
+// admin and validates the responses against api.Spec. It reports whether all
+// responses matched.
+func checkContract(out io.Writer) bool {
+	cfg := loadConfig()
+	db := connectDatabase(cfg.Database)
+	defer db.Close()
+
+	routerCfg := routerConfig(cfg, nil)
+	routerCfg.Auth = api.AuthConfig{AnonymousRole: database.RoleAdmin}
+	routerCfg.RateLimit = api.RateLimitConfig{}
+
+	router, err := api.SetupRoutes(db, newMigrationManager(db, cfg.Server, ""), routerCfg)
+	if err != nil {
+		fmt.Fprintf(out, "FAIL %v\n", err)
+		return false
+	}
+	spec := api.Spec()
+
+	passed := true
+	checked := make(map[string]bool)
+	for _, tc := range contractCases() {
+		// Key id 0 never exists, so rotate and revoke answer 404 without changing anything
+		path := strings.ReplaceAll(tc.route, ":id", "0")
+		if tc.query != "" {
+			path += "?" + tc.query
+		}
+
+		request := httptest.NewRequest(tc.method, path, strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			request.Header.Set("Content-Type", tc.contentType)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		status := recorder.Code
+		problems := spec.ValidateResponse(tc.method, tc.route, status, recorder.Header().Get("Content-Type"), recorder.Body.Bytes())
+		checked[fmt.Sprintf("%s %s %d", tc.method, tc.route, status)] = true
+
+		if len(problems) == 0 {
+			fmt.Fprintf(out, "ok   %s %s -> %d\n", tc.method, path, status)
+			continue
+		}
+		passed = false
+		fmt.Fprintf(out, "FAIL %s %s -> %d\n", tc.method, path, status)
+		for _, problem := range problems {
+			fmt.Fprintf(out, "       %s\n", problem)
+		}
+	}
+
+	// Success responses of writes are not exercised; list them so a reader
+	// knows what the check does not cover
+	for _, route := range spec.Routes() {
+		op, _ := spec.Operation(route.Method, route.Path)
+		for status := range op.Responses {
+			key := fmt.Sprintf("%s %s %s", route.Method, route.Path, status)
+			if status != "default" && !checked[key] {
+				fmt.Fprintf(out, "skip %s (not exercised)\n", key)
+			}
+		}
+	}
+
+	if !passed {
+		fmt.Fprintln(out, "Responses drifted from the OpenAPI document")
+	}
+	return passed
+}
@@ -0,0 +1,170 @@
+// Package openapi builds OpenAPI 3 documents whose schemas are generated from
+// the Go response types, and validates JSON responses against them.
+package openapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps a lower case HTTP method to its operation
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"` // By status code or "default"
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // query or path
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`                   // apiKey or http
+	Name         string `json:"name,omitempty"`         // Header name of apiKey schemes
+	In           string `json:"in,omitempty"`           // header
+	Scheme       string `json:"scheme,omitempty"`       // bearer
+	BearerFormat string `json:"bearerFormat,omitempty"` // JWT
+	Description  string `json:"description,omitempty"`
+}
+
+// SecurityRequirement maps a security scheme name to its scopes
+type SecurityRequirement map[string][]string
+
+// Schema is the subset of the OpenAPI 3.0 schema object used by this API
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"` // false or *Schema
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+}
+
+// Route is one documented method and path, with gin style parameters (:id)
+type Route struct {
+	Method string
+	Path   string
+}
+
+// Routes lists every documented operation, sorted
+func (d *Document) Routes() []Route {
+	var routes []Route
+	for path, item := range d.Paths {
+		for method := range item {
+			routes = append(routes, Route{Method: strings.ToUpper(method), Path: ginPath(path)})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// Operation returns the operation of a gin route (e.g. /api/keys/:id)
+func (d *Document) Operation(method, ginRoute string) (*Operation, bool) {
+	item, ok := d.Paths[openAPIPath(ginRoute)]
+	if !ok {
+		return nil, false
+	}
+	op, ok := item[strings.ToLower(method)]
+	return op, ok
+}
+
+// CompareRoutes reports every route that is served but not documented and
+// every documented route that is not served
+func (d *Document) CompareRoutes(served []Route) []string {
+	documented := make(map[Route]bool)
+	for _, route := range d.Routes() {
+		documented[route] = true
+	}
+
+	var problems []string
+	for _, route := range served {
+		if !documented[route] {
+			problems = append(problems, fmt.Sprintf("%s %s is served but not in the OpenAPI document", route.Method, route.Path))
+		}
+		delete(documented, route)
+	}
+	for _, route := range d.Routes() {
+		if documented[route] {
+			problems = append(problems, fmt.Sprintf("%s %s is in the OpenAPI document but not served", route.Method, route.Path))
+		}
+	}
+	return problems
+}
+
+// openAPIPath turns /api/keys/:id into /api/keys/{id}
+func openAPIPath(ginRoute string) string {
+	segments := strings.Split(ginRoute, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func ginPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segments[i] = ":" + segment[1:len(segment)-1]
+		}
+	}
+	return strings.Join(segments, "/")
+}
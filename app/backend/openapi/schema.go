@@ -0,0 +1,199 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	rawJSONType   = reflect.TypeOf(json.RawMessage{})
+	interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// Generator derives schemas from Go types the way encoding/json marshals
+// them. Named struct types become components and are referenced by $ref.
+//
+//   - Fields without omitempty are required; with omitempty they are optional.
+//   - Pointers, slices and maps without omitempty may be null, and so may
+//     pointer items and map values.
+//   - Structs do not allow other properties, so a field added to a response
+//     type without updating the document is reported as drift.
+type Generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func NewGenerator() *Generator {
+	return &Generator{schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// Schemas returns the components generated so far
+func (g *Generator) Schemas() map[string]*Schema {
+	return g.schemas
+}
+
+// Define adds a hand-written component, e.g. for responses built from maps
+func (g *Generator) Define(name string, schema *Schema) *Schema {
+	g.schemas[name] = schema
+	return Ref(name)
+}
+
+// Named generates the schema of v's type as component name
+func (g *Generator) Named(name string, v interface{}) *Schema {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	g.names[t] = name
+	return g.schemaOf(t)
+}
+
+// SchemaOf returns the schema of v's type; named structs are referenced
+func (g *Generator) SchemaOf(v interface{}) *Schema {
+	return g.schemaOf(reflect.TypeOf(v))
+}
+
+func (g *Generator) schemaOf(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawJSONType || t == interfaceType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaOf(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.elementSchema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.elementSchema(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = exportedName(t.Name())
+			g.names[t] = name
+		}
+		if _, done := g.schemas[name]; !done {
+			g.schemas[name] = &Schema{} // Placeholder for recursive types
+			g.schemas[name] = g.structSchema(t)
+		}
+		return Ref(name)
+	}
+	return &Schema{}
+}
+
+// elementSchema is the schema of slice items and map values; nil pointers
+// marshal as null
+func (g *Generator) elementSchema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		return Nullable(g.schemaOf(t))
+	}
+	return g.schemaOf(t)
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false}
+	g.addFields(schema, t)
+	return schema
+}
+
+// addFields adds the JSON fields of t, flattening embedded structs like encoding/json
+func (g *Generator) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		omitEmpty := strings.Contains(","+options+",", ",omitempty,")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schemaOf(field.Type)
+		if !omitEmpty {
+			schema.Required = append(schema.Required, name)
+			switch field.Type.Kind() {
+			case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+				property = Nullable(property)
+			}
+		}
+		schema.Properties[name] = property
+	}
+}
+
+func exportedName(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// Ref references a component schema
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Nullable allows null in addition to schema. A $ref cannot carry siblings in
+// OpenAPI 3.0, so it is wrapped in anyOf.
+func Nullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return &Schema{AnyOf: []*Schema{schema}, Nullable: true}
+	}
+	copied := *schema
+	copied.Nullable = true
+	return &copied
+}
+
+// Object builds an object schema that allows only properties; required lists
+// the properties that must be present
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required, AdditionalProperties: false}
+}
+
+// ArrayOf is an array of items
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// Enum is a string schema limited to values
+func Enum(values ...string) *Schema {
+	schema := &Schema{Type: "string"}
+	for _, value := range values {
+		schema.Enum = append(schema.Enum, value)
+	}
+	return schema
+}
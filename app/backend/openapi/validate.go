@@ -0,0 +1,208 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidateResponse checks a response of the gin route against the document:
+// the status must be documented (or covered by "default"), the content type
+// must be listed and a JSON body must match the schema. It returns one message
+// per mismatch.
+func (d *Document) ValidateResponse(method, ginRoute string, status int, contentType string, body []byte) []string {
+	op, ok := d.Operation(method, ginRoute)
+	if !ok {
+		return []string{fmt.Sprintf("%s %s is not documented", method, ginRoute)}
+	}
+
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if response, ok = op.Responses["default"]; !ok || status < 400 {
+			return []string{fmt.Sprintf("status %d is not documented", status)}
+		}
+	}
+	if len(response.Content) == 0 {
+		if len(body) > 0 {
+			return []string{fmt.Sprintf("status %d is documented without a body, got %d bytes", status, len(body))}
+		}
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid Content-Type %q", contentType)}
+	}
+	media, ok := response.Content[mediaType]
+	if !ok {
+		return []string{fmt.Sprintf("Content-Type %s is not documented for status %d", mediaType, status)}
+	}
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil // Files (CSV, Parquet, ...) are not checked
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []string{fmt.Sprintf("body is not JSON: %v", err)}
+	}
+	return d.Validate(media.Schema, value)
+}
+
+// Validate checks a decoded JSON value (as from json.Unmarshal into an
+// interface{}) against schema
+func (d *Document) Validate(schema *Schema, value interface{}) []string {
+	var problems []string
+	d.validate(schema, value, "$", &problems)
+	return problems
+}
+
+func (d *Document) validate(schema *Schema, value interface{}, path string, problems *[]string) {
+	add := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if schema.Ref != "" {
+		resolved, ok := d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			add("unknown schema %s", schema.Ref)
+			return
+		}
+		d.validate(resolved, value, path, problems)
+		return
+	}
+
+	if value == nil {
+		if !schema.Nullable && (schema.Type != "" || len(schema.AnyOf) > 0) {
+			add("is null")
+		}
+		return
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		add("%v is not one of %v", value, schema.Enum)
+		return
+	}
+
+	if len(schema.AnyOf) > 0 {
+		var first []string
+		for i, option := range schema.AnyOf {
+			var optionProblems []string
+			d.validate(option, value, path, &optionProblems)
+			if len(optionProblems) == 0 {
+				return
+			}
+			if i == 0 {
+				first = optionProblems
+			}
+		}
+		add("matches none of %d schemas; first: %s", len(schema.AnyOf), strings.Join(first, "; "))
+		return
+	}
+
+	switch schema.Type {
+	case "":
+		return // Any value
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			add("expected object, got %s", jsonType(value))
+			return
+		}
+		for _, name := range schema.Required {
+			if _, present := object[name]; !present {
+				add("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, known := schema.Properties[name]
+			switch {
+			case known:
+				d.validate(property, object[name], path+"."+name, problems)
+			case schema.AdditionalProperties == false:
+				add("unexpected property %q", name)
+			case schema.AdditionalProperties != nil:
+				if additional, ok := schema.AdditionalProperties.(*Schema); ok {
+					d.validate(additional, object[name], path+"."+name, problems)
+				}
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			add("expected array, got %s", jsonType(value))
+			return
+		}
+		if schema.Items != nil {
+			for i, item := range array {
+				d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			add("expected string, got %s", jsonType(value))
+			return
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				add("%q is not an RFC 3339 date-time", s)
+			}
+		}
+	case "number", "integer":
+		n, ok := value.(float64)
+		if !ok {
+			add("expected %s, got %s", schema.Type, jsonType(value))
+			return
+		}
+		if schema.Type == "integer" && n != math.Trunc(n) {
+			add("expected integer, got %v", n)
+		}
+		if schema.Minimum != nil && n < *schema.Minimum {
+			add("%v is below the minimum %v", n, *schema.Minimum)
+		}
+		if schema.Maximum != nil && n > *schema.Maximum {
+			add("%v is above the maximum %v", n, *schema.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			add("expected boolean, got %s", jsonType(value))
+		}
+	}
+}
+
+// inEnum compares scalars; enums never list objects or arrays
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if allowed == value {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"knet_management/database"
+)
+
+// runSensors prints the configured sensors with their latest reading
+//
+//	main sensors list
+func runSensors(args []string) {
+	if len(args) == 0 || args[0] != "list" {
+		fatal("Usage: main sensors list")
+	}
+
+	cfg := loadConfig()
+	sensorURL, acOutletSensorURL := sensorURLs(cfg)
+
+	db := connectDatabase(cfg.Database)
+	defer db.Close()
+
+	latest, err := db.GetLatestTempSensorData(context.Background())
+	if err != nil && err != sql.ErrNoRows {
+		fatal("Failed to get latest data", "error", err)
+	}
+
+	calibrations, err := db.GetSensorCalibrations(context.Background(), "")
+	if err != nil {
+		fatal("Failed to get calibrations", "error", err)
+	}
+	calibrationCount := make(map[string]int)
+	for _, cal := range calibrations {
+		calibrationCount[cal.Sensor]++
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SENSOR\tURL\tLAST READING\tTEMPERATURE\tHUMIDITY\tCALIBRATIONS")
+
+	lastReading := func(temperature, humidity *float64) string {
+		if latest == nil || temperature == nil || humidity == nil {
+			return "-\t-\t-"
+		}
+		return fmt.Sprintf("%s\t%.2f\t%.2f", latest.Timestamp.Format(time.RFC3339), *temperature, *humidity)
+	}
+
+	var mainTemperature, mainHumidity *float64
+	var acTemperature, acHumidity *float64
+	if latest != nil {
+		mainTemperature, mainHumidity = &latest.Temperature, &latest.Humidity
+		acTemperature, acHumidity = latest.ACOutletTemperature, latest.ACOutletHumidity
+	}
+
+	orNone := func(url string) string {
+		if url == "" {
+			return "(not configured)"
+		}
+		return url
+	}
+
+	fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", database.SensorMain, orNone(sensorURL), lastReading(mainTemperature, mainHumidity), calibrationCount[database.SensorMain])
+	fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", database.SensorACOutlet, orNone(acOutletSensorURL), lastReading(acTemperature, acHumidity), calibrationCount[database.SensorACOutlet])
+	w.Flush()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"knet_management/api"
+	"knet_management/auth"
+	"knet_management/config"
+	"knet_management/logging"
+	"knet_management/service"
+)
+
+// configPollInterval is how often the config file is checked for changes
+const configPollInterval = 5 * time.Second
+
+// runServe connects, migrates, starts periodic collection and serves the API
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	migrationsDir := fs.String("migrations-dir", "", "Directory containing migration SQL files (default: embedded migrations)")
+	fs.Parse(args)
+
+	cfg := loadConfig()
+	if err := cfg.RequireSensors(); err != nil {
+		fatal("Failed to load configuration", "error", err)
+	}
+
+	// init func should be separated.... but.. 미래의 제가 해주겠죠?
+	db := connectDatabase(cfg.Database)
+	defer db.Close()
+
+	// Run database migrations
+	migrationManager := newMigrationManager(db, cfg.Server, *migrationsDir)
+
+	if err := migrationManager.RunMigrations(); err != nil {
+		fatal("Failed to run database migrations", "error", err)
+	}
+
+	slog.Info("Database migrations completed successfully")
+
+	sensorURL, acOutletSensorURL := sensorURLs(cfg)
+	collector := service.NewTempSensorDataCollector(sensorURL, acOutletSensorURL, db)
+	alerts := service.NewAlertEvaluator(cfg.Alerts)
+	collector.SetAlertEvaluator(alerts)
+
+	interval := cfg.Collection.Interval.Duration()
+	slog.Info("Starting periodic data collection", "main_url", sensorURL, "ac_outlet_url", acOutletSensorURL, "interval", interval.String())
+	collector.StartPeriodicTempCollection(interval)
+
+	collector.CollectTempData() // Logs its own failures
+
+	retention := service.NewRetentionJob(db, cfg.Retention.RawData.Duration())
+	retention.Start()
+
+	// Sensors, interval, alert rules and retention follow the config file
+	// without a restart; SIGHUP forces a reload, of the environment only when
+	// there is no config file
+	running := cfg
+	config.Watch(configPath, configPollInterval, func(newCfg *config.Config) {
+		if err := newCfg.RequireSensors(); err != nil {
+			slog.Error("Config reload rejected, keeping the running configuration", "error", err)
+			return
+		}
+		collector.UpdateSensors(sensorURLs(newCfg))
+		collector.SetInterval(newCfg.Collection.Interval.Duration())
+		alerts.SetRules(newCfg.Alerts)
+		retention.SetPeriod(newCfg.Retention.RawData.Duration())
+		logging.SetLevel(newCfg.Logging.Level) // Validated by Load
+		logRestartRequired(running, newCfg)
+	})
+
+	router, err := api.SetupRoutes(db, migrationManager, routerConfig(cfg, collector))
+	if err != nil {
+		fatal("Failed to set up routes", "error", err)
+	}
+	slog.Info("Starting server", "port", cfg.Server.Port)
+
+	if err := router.Run(":" + cfg.Server.Port); err != nil {
+		fatal("Failed to start server", "error", err)
+	}
+}
+
+// logRestartRequired warns about reloaded settings that only apply on restart
+func logRestartRequired(running, reloaded *config.Config) {
+	sections := map[string][2]interface{}{
+		"database": {running.Database, reloaded.Database},
+		"server":   {running.Server, reloaded.Server},
+		"auth":     {running.Auth, reloaded.Auth},
+		"http":     {running.HTTP, reloaded.HTTP},
+		"health":   {running.Health, reloaded.Health},
+		"stats":    {running.Stats, reloaded.Stats},
+
+		"logging.format": {running.Logging.Format, reloaded.Logging.Format},
+	}
+	for name, values := range sections {
+		if !reflect.DeepEqual(values[0], values[1]) {
+			slog.Warn("Config section changed; restart the server to apply it", "section", name)
+		}
+	}
+}
+
+// routerConfig converts the configuration to the API settings
+func routerConfig(cfg *config.Config, collector *service.TempSensorDataCollector) api.Config {
+	return api.Config{
+		Auth: api.AuthConfig{AnonymousRole: cfg.Auth.AnonymousRole, OIDC: oidcVerifier(cfg.Auth.OIDC)},
+		CORS: api.CORSConfig{
+			AllowOrigins:     cfg.HTTP.CORS.AllowOrigins,
+			AllowMethods:     cfg.HTTP.CORS.AllowMethods,
+			AllowHeaders:     cfg.HTTP.CORS.AllowHeaders,
+			ExposeHeaders:    cfg.HTTP.CORS.ExposeHeaders,
+			AllowCredentials: cfg.HTTP.CORS.AllowCredentials,
+			MaxAge:           cfg.HTTP.CORS.MaxAge.Duration(),
+		},
+		RateLimit: api.RateLimitConfig{
+			RequestsPerSecond: cfg.HTTP.RateLimit.RequestsPerSecond,
+			Burst:             cfg.HTTP.RateLimit.Burst,
+		},
+		Health: api.HealthConfig{
+			Collector:        collector,
+			MaxCollectionAge: cfg.Health.MaxCollectionAge.Duration(),
+			DBPingTimeout:    cfg.Health.DBPingTimeout.Duration(),
+		},
+		Stats: api.StatsConfig{
+			Thresholds: cfg.Stats.StatsThresholds(),
+			Interval:   cfg.Collection.Interval.Duration(),
+			Collector:  collector,
+		},
+		TrustedProxies:     cfg.HTTP.TrustedProxies,
+		MaxBodyBytes:       cfg.HTTP.MaxBodyBytes,
+		MaxImportBodyBytes: cfg.HTTP.MaxImportBodyBytes,
+	}
+}
+
+// oidcVerifier validates SSO bearer tokens when an issuer is configured
+func oidcVerifier(cfg config.OIDCConfig) *auth.Verifier {
+	if cfg.IssuerURL == "" {
+		return nil
+	}
+
+	verifier, err := auth.NewVerifier(auth.OIDCConfig{
+		IssuerURL:   cfg.IssuerURL,
+		Audience:    cfg.Audience,
+		JWKSURL:     cfg.JWKSURL,
+		RoleClaim:   cfg.RoleClaim,
+		RoleMapping: cfg.RoleMapping,
+		JWKSRefresh: cfg.JWKSRefresh.Duration(),
+	})
+	if err != nil {
+		fatal("Invalid OIDC configuration", "error", err)
+	}
+
+	slog.Info("Accepting bearer tokens from OIDC issuer", "issuer", cfg.IssuerURL)
+	return verifier
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"knet_management/config"
+	"knet_management/database"
+)
+
+// AlertEvaluator checks every collected reading against the configured alert
+// rules and logs when a rule starts and stops firing. A rule fires once its
+// condition has held for the rule's For duration.
+type AlertEvaluator struct {
+	mu     sync.Mutex
+	rules  []config.AlertRule
+	states map[string]*alertState // By rule name
+}
+
+type alertState struct {
+	since  time.Time // When the condition started to hold
+	firing bool
+}
+
+func NewAlertEvaluator(rules []config.AlertRule) *AlertEvaluator {
+	e := &AlertEvaluator{states: make(map[string]*alertState)}
+	e.SetRules(rules)
+	return e
+}
+
+// SetRules replaces the rules. State is kept for rules whose name still
+// exists, so reloading the config does not re-fire active alerts.
+func (e *AlertEvaluator) SetRules(rules []config.AlertRule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	names := make(map[string]bool)
+	for _, rule := range rules {
+		names[rule.Name] = true
+	}
+	for name := range e.states {
+		if !names[name] {
+			delete(e.states, name)
+		}
+	}
+	e.rules = rules
+}
+
+// Evaluate checks data against every rule
+func (e *AlertEvaluator) Evaluate(data *database.TempSensorData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, rule := range e.rules {
+		value, ok := alertMetricValue(data, rule.Sensor, rule.Metric)
+		if !ok {
+			continue // Sensor did not report; keep the previous state
+		}
+
+		state, found := e.states[rule.Name]
+		if !found {
+			state = &alertState{}
+			e.states[rule.Name] = state
+		}
+
+		if !alertConditionHolds(rule, value) {
+			if state.firing {
+				slog.Info("Alert resolved", "alert", rule.Name, "sensor_id", rule.Sensor, "metric", rule.Metric, "value", value)
+			}
+			state.since, state.firing = time.Time{}, false
+			continue
+		}
+
+		if state.since.IsZero() {
+			state.since = data.Timestamp
+		}
+		if !state.firing && data.Timestamp.Sub(state.since) >= rule.For.Duration() {
+			state.firing = true
+			slog.Warn("Alert firing", "alert", rule.Name, "sensor_id", rule.Sensor, "metric", rule.Metric, "value", value,
+				"threshold", alertThreshold(rule), "since", state.since)
+		}
+	}
+}
+
+func alertConditionHolds(rule config.AlertRule, value float64) bool {
+	return (rule.Above != nil && value > *rule.Above) || (rule.Below != nil && value < *rule.Below)
+}
+
+func alertThreshold(rule config.AlertRule) string {
+	switch {
+	case rule.Above != nil && rule.Below != nil:
+		return fmt.Sprintf("outside %.2f..%.2f", *rule.Below, *rule.Above)
+	case rule.Above != nil:
+		return fmt.Sprintf("above %.2f", *rule.Above)
+	default:
+		return fmt.Sprintf("below %.2f", *rule.Below)
+	}
+}
+
+// alertMetricValue returns a measured or derived metric of sensor; false if the
+// sensor has no value in data
+func alertMetricValue(data *database.TempSensorData, sensor, metric string) (float64, bool) {
+	var temperature, humidity float64
+	switch sensor {
+	case database.SensorMain:
+		temperature, humidity = data.Temperature, data.Humidity
+	case database.SensorACOutlet:
+		if data.ACOutletTemperature == nil || data.ACOutletHumidity == nil {
+			return 0, false
+		}
+		temperature, humidity = *data.ACOutletTemperature, *data.ACOutletHumidity
+	default:
+		return 0, false
+	}
+
+	switch metric {
+	case "temperature":
+		return temperature, true
+	case "humidity":
+		return humidity, true
+	}
+
+	derived := database.CalculateDerivedMetrics(temperature, humidity)
+	if derived == nil {
+		return 0, false
+	}
+	switch metric {
+	case "dew_point":
+		return derived.DewPoint, true
+	case "absolute_humidity":
+		return derived.AbsoluteHumidity, true
+	case "heat_index":
+		return derived.HeatIndex, true
+	case "humidex":
+		return derived.Humidex, true
+	}
+	return 0, false
+}
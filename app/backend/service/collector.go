@@ -1,20 +1,53 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"knet_management/database"
 )
 
 type TempSensorDataCollector struct {
+	db     *database.Database
+	client *http.Client
+	alerts *AlertEvaluator
+
+	// Sensor URLs can change on config reload while a collection runs
+	mu                sync.RWMutex
 	sensorURL         string
-	acOutletSensorURL string
-	db                *database.Database
-	client            *http.Client
+	acOutletSensorURL string // Empty when the AC outlet sensor is not configured
+
+	intervalChanges chan time.Duration
+	cycle           atomic.Int64 // Numbers collection cycles for the cycle_id log field
+
+	healthMu    sync.Mutex
+	startedAt   time.Time
+	interval    time.Duration
+	lastSuccess time.Time // Last reading stored
+	sensors     map[string]*SensorHealth
+}
+
+// SensorHealth is the collection state of one sensor
+type SensorHealth struct {
+	URL                 string     `json:"url"`
+	LastSuccess         *time.Time `json:"last_success"`
+	LastFailure         *time.Time `json:"last_failure,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+}
+
+// CollectorHealth is a snapshot of the periodic collection for readiness checks
+type CollectorHealth struct {
+	StartedAt   time.Time // Zero before StartPeriodicTempCollection
+	Interval    time.Duration
+	LastSuccess time.Time               // Zero until the first reading is stored
+	Sensors     map[string]SensorHealth // By sensor ID; only configured sensors
 }
 
 func NewTempSensorDataCollector(sensorURL string, acOutletSensorURL string, db *database.Database) *TempSensorDataCollector {
@@ -23,28 +56,113 @@ func NewTempSensorDataCollector(sensorURL string, acOutletSensorURL string, db *
 		acOutletSensorURL: acOutletSensorURL,
 		db:                db,
 		client:            &http.Client{Timeout: 10 * time.Second},
+		intervalChanges:   make(chan time.Duration, 1),
+		sensors:           make(map[string]*SensorHealth),
+	}
+}
+
+// Health returns the collection state of the collector and its sensors
+func (c *TempSensorDataCollector) Health() CollectorHealth {
+	sensorURL, acOutletSensorURL := c.sensorURLs()
+
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+
+	health := CollectorHealth{
+		StartedAt:   c.startedAt,
+		Interval:    c.interval,
+		LastSuccess: c.lastSuccess,
+		Sensors:     make(map[string]SensorHealth),
+	}
+	for id, url := range map[string]string{database.SensorMain: sensorURL, database.SensorACOutlet: acOutletSensorURL} {
+		if url == "" {
+			continue
+		}
+		sensor := SensorHealth{}
+		if recorded, ok := c.sensors[id]; ok {
+			sensor = *recorded
+		}
+		sensor.URL = url
+		health.Sensors[id] = sensor
+	}
+	return health
+}
+
+// recordSensorResult updates the health of sensor after a read
+func (c *TempSensorDataCollector) recordSensorResult(sensorID string, err error) {
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+
+	sensor, ok := c.sensors[sensorID]
+	if !ok {
+		sensor = &SensorHealth{}
+		c.sensors[sensorID] = sensor
+	}
+
+	now := time.Now()
+	if err != nil {
+		sensor.LastFailure = &now
+		sensor.LastError = err.Error()
+		sensor.ConsecutiveFailures++
+		return
 	}
+	sensor.LastSuccess = &now
+	sensor.ConsecutiveFailures = 0
 }
 
+// SetAlertEvaluator checks every collected reading against alert rules
+func (c *TempSensorDataCollector) SetAlertEvaluator(alerts *AlertEvaluator) {
+	c.alerts = alerts
+}
+
+// UpdateSensors switches the sensor URLs; the next collection uses them
+func (c *TempSensorDataCollector) UpdateSensors(sensorURL, acOutletSensorURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if sensorURL != c.sensorURL || acOutletSensorURL != c.acOutletSensorURL {
+		slog.Info("Sensors changed", "main_url", sensorURL, "ac_outlet_url", acOutletSensorURL)
+	}
+	c.sensorURL, c.acOutletSensorURL = sensorURL, acOutletSensorURL
+}
+
+func (c *TempSensorDataCollector) sensorURLs() (string, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sensorURL, c.acOutletSensorURL
+}
+
+// CollectTempData reads every sensor and stores one reading. Failures are
+// logged with the cycle_id and sensor_id fields and returned.
 func (c *TempSensorDataCollector) CollectTempData() error {
+	sensorURL, acOutletSensorURL := c.sensorURLs()
+	logger := slog.With("cycle_id", c.cycle.Add(1))
+
 	// Collect data from main temperature sensor
-	mainSensorData, err := c.collectFromSensor(c.sensorURL, "main temperature sensor")
+	mainSensorData, err := c.collectFromSensor(sensorURL, "main temperature sensor")
+	c.recordSensorResult(database.SensorMain, err)
 	if err != nil {
+		logger.Error("Failed to collect from sensor", "sensor_id", database.SensorMain, "error", err)
 		return fmt.Errorf("failed to collect from main sensor: %w", err)
 	}
+	logger.Debug("Sensor read", "sensor_id", database.SensorMain,
+		"temperature", mainSensorData.Temperature, "humidity", mainSensorData.Humidity)
 
 	// Collect data from AC outlet sensor
-	acOutletData, err := c.collectFromSensor(c.acOutletSensorURL, "AC outlet sensor")
 	var acOutletTemp, acOutletHum *float64
 
-	if err != nil {
-		log.Printf("Warning: failed to collect from AC outlet sensor: %v", err)
-		// AC outlet sensor data will be null
-		acOutletTemp = nil
-		acOutletHum = nil
-	} else {
-		acOutletTemp = &acOutletData.Temperature
-		acOutletHum = &acOutletData.Humidity
+	if acOutletSensorURL != "" {
+		acOutletData, err := c.collectFromSensor(acOutletSensorURL, "AC outlet sensor")
+		c.recordSensorResult(database.SensorACOutlet, err)
+		if err != nil {
+			logger.Warn("Failed to collect from sensor", "sensor_id", database.SensorACOutlet, "error", err)
+			// AC outlet sensor data will be null
+		} else {
+			acOutletTemp = &acOutletData.Temperature
+			acOutletHum = &acOutletData.Humidity
+			logger.Debug("Sensor read", "sensor_id", database.SensorACOutlet,
+				"temperature", acOutletData.Temperature, "humidity", acOutletData.Humidity)
+		}
 	}
 
 	// Combine both sensor data
@@ -56,17 +174,26 @@ func (c *TempSensorDataCollector) CollectTempData() error {
 		Timestamp:           time.Now(),
 	}
 
-	if err := c.db.InsertTempSensorData(sensorData); err != nil {
+	if err := c.db.InsertTempSensorData(context.Background(), sensorData); err != nil {
+		logger.Error("Failed to insert sensor data", "error", err)
 		return fmt.Errorf("failed to insert sensor data: %w", err)
 	}
 
+	c.healthMu.Lock()
+	c.lastSuccess = time.Now()
+	c.healthMu.Unlock()
+
+	attrs := []any{"id", sensorData.ID, "temperature", sensorData.Temperature, "humidity", sensorData.Humidity}
 	if sensorData.ACOutletTemperature != nil && sensorData.ACOutletHumidity != nil {
-		log.Printf("Collected sensor data: Main(T=%.2f, H=%.2f), AC(T=%.2f, H=%.2f)",
-			sensorData.Temperature, sensorData.Humidity,
-			*sensorData.ACOutletTemperature, *sensorData.ACOutletHumidity)
-	} else {
-		log.Printf("Collected sensor data: Main(T=%.2f, H=%.2f), AC(no data)",
-			sensorData.Temperature, sensorData.Humidity)
+		attrs = append(attrs, "ac_outlet_temperature", *sensorData.ACOutletTemperature, "ac_outlet_humidity", *sensorData.ACOutletHumidity)
+	}
+	if len(sensorData.QualityFlags) > 0 {
+		attrs = append(attrs, "quality_flags", sensorData.QualityFlags)
+	}
+	logger.Info("Collected sensor data", attrs...)
+
+	if c.alerts != nil {
+		c.alerts.Evaluate(sensorData)
 	}
 	return nil
 }
@@ -90,12 +217,43 @@ func (c *TempSensorDataCollector) collectFromSensor(sensorURL, sensorName string
 	return &apiResp, nil
 }
 
+// SetInterval changes the interval of the periodic collection started with
+// StartPeriodicTempCollection
+func (c *TempSensorDataCollector) SetInterval(interval time.Duration) {
+	// Only the newest interval matters; drop one that was not picked up yet
+	select {
+	case <-c.intervalChanges:
+	default:
+	}
+	c.intervalChanges <- interval
+}
+
+// setInterval records the running interval for Health
+func (c *TempSensorDataCollector) setInterval(interval time.Duration) {
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+	c.interval = interval
+}
+
 func (c *TempSensorDataCollector) StartPeriodicTempCollection(interval time.Duration) {
+	c.setInterval(interval)
+	c.healthMu.Lock()
+	c.startedAt = time.Now()
+	c.healthMu.Unlock()
+
 	ticker := time.NewTicker(interval)
 	go func() {
-		for range ticker.C {
-			if err := c.CollectTempData(); err != nil {
-				log.Printf("Error collecting sensor data: %v", err)
+		for {
+			select {
+			case <-ticker.C:
+				c.CollectTempData() // Logs its own failures
+			case newInterval := <-c.intervalChanges:
+				if newInterval != interval {
+					slog.Info("Collection interval changed", "from", interval.String(), "to", newInterval.String())
+					interval = newInterval
+					ticker.Reset(interval)
+					c.setInterval(interval)
+				}
 			}
 		}
 	}()
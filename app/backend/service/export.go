@@ -0,0 +1,428 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"knet_management/database"
+)
+
+// Export formats
+const (
+	ExportFormatCSV     = "csv"
+	ExportFormatNDJSON  = "ndjson"
+	ExportFormatParquet = "parquet"
+)
+
+var (
+	exportSensors = []string{database.SensorMain, database.SensorACOutlet}
+	exportMetrics = []string{
+		database.MetricTemperature, database.MetricHumidity,
+		database.MetricDewPoint, database.MetricAbsoluteHumidity, database.MetricHeatIndex, database.MetricHumidex,
+	}
+	exportResolutions = map[string]time.Duration{
+		"raw": 0,
+		"1m":  time.Minute,
+		"5m":  5 * time.Minute,
+		"15m": 15 * time.Minute,
+		"1h":  time.Hour,
+		"1d":  24 * time.Hour,
+	}
+)
+
+// ExportOptions selects what ExportTempSensorData writes
+type ExportOptions struct {
+	StartTime  time.Time
+	EndTime    time.Time
+	Format     string
+	Sensors    []string
+	Metrics    []string
+	Resolution time.Duration  // 0 exports raw readings, otherwise rollup bucket size
+	Location   *time.Location // Rollup buckets start at local midnight here; nil truncates in UTC
+	Filter     database.QualityFilter
+}
+
+// ExportRecord is one value of one metric of one sensor (long format).
+// Raw exports fill ReadingID/Value/QualityFlags, rollups fill Value (mean)/Min/Max/Count.
+type ExportRecord struct {
+	Timestamp    time.Time
+	ReadingID    int
+	Sensor       string
+	Metric       string
+	Value        float64
+	Min          float64
+	Max          float64
+	Count        int64
+	QualityFlags []string
+}
+
+// ParseExportFormat validates the format parameter, defaulting to CSV
+func ParseExportFormat(value string) (string, error) {
+	switch value {
+	case "":
+		return ExportFormatCSV, nil
+	case ExportFormatCSV, ExportFormatNDJSON, ExportFormatParquet:
+		return value, nil
+	}
+	return "", fmt.Errorf("unknown export format %q", value)
+}
+
+// ParseExportResolution parses "raw" or a rollup bucket size (1m, 5m, 15m, 1h, 1d)
+func ParseExportResolution(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	resolution, ok := exportResolutions[value]
+	if !ok {
+		return 0, fmt.Errorf("unknown resolution %q", value)
+	}
+	return resolution, nil
+}
+
+// ParseExportSensors parses a comma separated sensor list, defaulting to all sensors
+func ParseExportSensors(value string) ([]string, error) {
+	return parseExportList(value, exportSensors, "sensor")
+}
+
+// ParseExportMetrics parses a comma separated metric list, defaulting to all metrics
+func ParseExportMetrics(value string) ([]string, error) {
+	return parseExportList(value, exportMetrics, "metric")
+}
+
+func parseExportList(value string, allowed []string, kind string) ([]string, error) {
+	if value == "" {
+		return allowed, nil
+	}
+
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		valid := false
+		for _, a := range allowed {
+			if item == a {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown %s %q", kind, item)
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// ExportContentType returns the HTTP content type of an export format
+func ExportContentType(format string) string {
+	switch format {
+	case ExportFormatNDJSON:
+		return "application/x-ndjson"
+	case ExportFormatParquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv"
+}
+
+type TempSensorDataExporter struct {
+	db *database.Database
+}
+
+func NewTempSensorDataExporter(db *database.Database) *TempSensorDataExporter {
+	return &TempSensorDataExporter{db: db}
+}
+
+// Export streams the selected readings to w and returns the number of records written.
+// Readings come from a server-side cursor and rollups are emitted bucket by bucket,
+// so memory use does not grow with the time range.
+func (e *TempSensorDataExporter) Export(ctx context.Context, w io.Writer, opts ExportOptions) (int64, error) {
+	rollup := opts.Resolution > 0
+
+	writer, err := newExportRecordWriter(w, opts.Format, rollup)
+	if err != nil {
+		return 0, err
+	}
+
+	var written int64
+	emit := func(record *ExportRecord) error {
+		written++
+		return writer.WriteRecord(record)
+	}
+
+	var aggregator *rollupAggregator
+	if rollup {
+		aggregator = newRollupAggregator(opts, emit)
+	}
+
+	err = e.db.StreamTempSensorData(ctx, opts.StartTime, opts.EndTime, opts.Filter, func(data *database.TempSensorData) error {
+		if rollup {
+			return aggregator.add(data)
+		}
+
+		for _, sensor := range opts.Sensors {
+			for _, metric := range opts.Metrics {
+				value, ok := database.MetricValue(data, sensor, metric)
+				if !ok {
+					continue
+				}
+				if err := emit(&ExportRecord{
+					Timestamp:    data.Timestamp,
+					ReadingID:    data.ID,
+					Sensor:       sensor,
+					Metric:       metric,
+					Value:        value,
+					QualityFlags: data.QualityFlags,
+				}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return written, err
+	}
+
+	if rollup {
+		if err := aggregator.flush(); err != nil {
+			return written, err
+		}
+	}
+
+	return written, writer.Close()
+}
+
+// rollupAggregator accumulates readings of the current bucket and emits
+// one record per sensor/metric when the stream moves to the next bucket
+type rollupAggregator struct {
+	opts   ExportOptions
+	emit   func(*ExportRecord) error
+	bucket time.Time
+	stats  map[string]*ExportRecord
+	active bool
+}
+
+func newRollupAggregator(opts ExportOptions, emit func(*ExportRecord) error) *rollupAggregator {
+	return &rollupAggregator{opts: opts, emit: emit, stats: make(map[string]*ExportRecord)}
+}
+
+func (a *rollupAggregator) add(data *database.TempSensorData) error {
+	bucket := a.bucketOf(data.Timestamp)
+	if a.active && !bucket.Equal(a.bucket) {
+		if err := a.flush(); err != nil {
+			return err
+		}
+	}
+	a.bucket = bucket
+	a.active = true
+
+	for _, sensor := range a.opts.Sensors {
+		for _, metric := range a.opts.Metrics {
+			value, ok := database.MetricValue(data, sensor, metric)
+			if !ok {
+				continue
+			}
+			key := sensor + "/" + metric
+			record, exists := a.stats[key]
+			if !exists {
+				a.stats[key] = &ExportRecord{Timestamp: bucket, Sensor: sensor, Metric: metric, Value: value, Min: value, Max: value, Count: 1}
+				continue
+			}
+			// Value holds the running sum until flush
+			record.Value += value
+			if value < record.Min {
+				record.Min = value
+			}
+			if value > record.Max {
+				record.Max = value
+			}
+			record.Count++
+		}
+	}
+	return nil
+}
+
+// bucketOf returns the start of t's rollup bucket. With a location, buckets
+// restart at every local midnight, so daily buckets follow daylight saving.
+func (a *rollupAggregator) bucketOf(t time.Time) time.Time {
+	if a.opts.Location == nil {
+		return t.Truncate(a.opts.Resolution)
+	}
+	local := t.In(a.opts.Location)
+	year, month, day := local.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, a.opts.Location)
+	if a.opts.Resolution >= 24*time.Hour {
+		return midnight
+	}
+	return midnight.Add(local.Sub(midnight).Truncate(a.opts.Resolution))
+}
+
+func (a *rollupAggregator) flush() error {
+	if !a.active {
+		return nil
+	}
+	for _, sensor := range a.opts.Sensors {
+		for _, metric := range a.opts.Metrics {
+			record, ok := a.stats[sensor+"/"+metric]
+			if !ok {
+				continue
+			}
+			record.Value /= float64(record.Count)
+			if err := a.emit(record); err != nil {
+				return err
+			}
+		}
+	}
+	a.stats = make(map[string]*ExportRecord)
+	a.active = false
+	return nil
+}
+
+type exportRecordWriter interface {
+	WriteRecord(record *ExportRecord) error
+	Close() error
+}
+
+func newExportRecordWriter(w io.Writer, format string, rollup bool) (exportRecordWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		return newCSVRecordWriter(w, rollup)
+	case ExportFormatNDJSON:
+		return &ndjsonRecordWriter{enc: json.NewEncoder(w), rollup: rollup}, nil
+	case ExportFormatParquet:
+		return newParquetRecordWriter(w, rollup)
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+type csvRecordWriter struct {
+	w      *csv.Writer
+	rollup bool
+}
+
+func newCSVRecordWriter(w io.Writer, rollup bool) (*csvRecordWriter, error) {
+	cw := &csvRecordWriter{w: csv.NewWriter(w), rollup: rollup}
+	header := []string{"timestamp", "reading_id", "sensor", "metric", "value", "quality_flags"}
+	if rollup {
+		header = []string{"timestamp", "sensor", "metric", "mean", "min", "max", "count"}
+	}
+	return cw, cw.w.Write(header)
+}
+
+func (cw *csvRecordWriter) WriteRecord(r *ExportRecord) error {
+	formatFloat := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	if cw.rollup {
+		return cw.w.Write([]string{
+			r.Timestamp.Format(time.RFC3339), r.Sensor, r.Metric,
+			formatFloat(r.Value), formatFloat(r.Min), formatFloat(r.Max), strconv.FormatInt(r.Count, 10),
+		})
+	}
+	return cw.w.Write([]string{
+		r.Timestamp.Format(time.RFC3339Nano), strconv.Itoa(r.ReadingID), r.Sensor, r.Metric,
+		formatFloat(r.Value), strings.Join(r.QualityFlags, ";"),
+	})
+}
+
+func (cw *csvRecordWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonRecordWriter struct {
+	enc    *json.Encoder
+	rollup bool
+}
+
+type ndjsonRawRecord struct {
+	Timestamp    time.Time `json:"timestamp"`
+	ReadingID    int       `json:"reading_id"`
+	Sensor       string    `json:"sensor"`
+	Metric       string    `json:"metric"`
+	Value        float64   `json:"value"`
+	QualityFlags []string  `json:"quality_flags"`
+}
+
+type ndjsonRollupRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Sensor    string    `json:"sensor"`
+	Metric    string    `json:"metric"`
+	Mean      float64   `json:"mean"`
+	Min       float64   `json:"min"`
+	Max       float64   `json:"max"`
+	Count     int64     `json:"count"`
+}
+
+func (nw *ndjsonRecordWriter) WriteRecord(r *ExportRecord) error {
+	if nw.rollup {
+		return nw.enc.Encode(ndjsonRollupRecord{
+			Timestamp: r.Timestamp, Sensor: r.Sensor, Metric: r.Metric,
+			Mean: r.Value, Min: r.Min, Max: r.Max, Count: r.Count,
+		})
+	}
+	flags := r.QualityFlags
+	if flags == nil {
+		flags = []string{}
+	}
+	return nw.enc.Encode(ndjsonRawRecord{
+		Timestamp: r.Timestamp, ReadingID: r.ReadingID, Sensor: r.Sensor, Metric: r.Metric,
+		Value: r.Value, QualityFlags: flags,
+	})
+}
+
+func (nw *ndjsonRecordWriter) Close() error {
+	return nil
+}
+
+type parquetRecordWriter struct {
+	pw     *parquetWriter
+	rollup bool
+}
+
+func newParquetRecordWriter(w io.Writer, rollup bool) (*parquetRecordWriter, error) {
+	utf8 := parquetConvertedUTF8
+	timestamp := parquetConvertedTimestampMicros
+
+	fields := []parquetField{
+		{Name: "timestamp", Type: parquetInt64, ConvertedType: &timestamp},
+		{Name: "reading_id", Type: parquetInt64},
+		{Name: "sensor", Type: parquetByteArray, ConvertedType: &utf8},
+		{Name: "metric", Type: parquetByteArray, ConvertedType: &utf8},
+		{Name: "value", Type: parquetDouble},
+		{Name: "quality_flags", Type: parquetByteArray, ConvertedType: &utf8},
+	}
+	if rollup {
+		fields = []parquetField{
+			{Name: "timestamp", Type: parquetInt64, ConvertedType: &timestamp},
+			{Name: "sensor", Type: parquetByteArray, ConvertedType: &utf8},
+			{Name: "metric", Type: parquetByteArray, ConvertedType: &utf8},
+			{Name: "mean", Type: parquetDouble},
+			{Name: "min", Type: parquetDouble},
+			{Name: "max", Type: parquetDouble},
+			{Name: "count", Type: parquetInt64},
+		}
+	}
+
+	pw, err := newParquetWriter(w, fields)
+	if err != nil {
+		return nil, err
+	}
+	return &parquetRecordWriter{pw: pw, rollup: rollup}, nil
+}
+
+func (pr *parquetRecordWriter) WriteRecord(r *ExportRecord) error {
+	if pr.rollup {
+		return pr.pw.WriteRow(r.Timestamp.UnixMicro(), r.Sensor, r.Metric, r.Value, r.Min, r.Max, r.Count)
+	}
+	return pr.pw.WriteRow(r.Timestamp.UnixMicro(), int64(r.ReadingID), r.Sensor, r.Metric, r.Value, strings.Join(r.QualityFlags, ";"))
+}
+
+func (pr *parquetRecordWriter) Close() error {
+	return pr.pw.Close()
+}
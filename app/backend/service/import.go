@@ -0,0 +1,375 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"knet_management/database"
+)
+
+// Import formats
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+)
+
+// Import target fields; the column mapping maps these to source columns/keys
+const (
+	importFieldTimestamp           = "timestamp"
+	importFieldTemperature         = "temperature"
+	importFieldHumidity            = "humidity"
+	importFieldACOutletTemperature = "ac_outlet_temperature"
+	importFieldACOutletHumidity    = "ac_outlet_humidity"
+)
+
+const (
+	defaultImportBatchSize = 500
+	maxImportReportErrors  = 100
+)
+
+var importFields = []string{
+	importFieldTimestamp, importFieldTemperature, importFieldHumidity,
+	importFieldACOutletTemperature, importFieldACOutletHumidity,
+}
+
+// Timestamp layouts tried in order when no explicit layout is given.
+// Layouts without a zone are read in ImportOptions.Location.
+var importTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006.01.02 15:04:05",
+}
+
+// ImportOptions controls how an import source is read
+type ImportOptions struct {
+	Format          string
+	ColumnMapping   map[string]string // target field -> source column (CSV header) or key (NDJSON)
+	Location        *time.Location    // For timestamps without a zone; defaults to UTC
+	TimestampLayout string            // Optional Go time layout; "unix" for epoch seconds
+	BatchSize       int
+	DryRun          bool // Validate only, nothing is written
+}
+
+// ImportRowError describes a rejected source row
+type ImportRowError struct {
+	Row     int    `json:"row"` // 1-based data row (CSV header excluded)
+	Message string `json:"message"`
+}
+
+// ImportReport is the validation and load summary of an import
+type ImportReport struct {
+	TotalRows  int              `json:"total_rows"`
+	Valid      int              `json:"valid"`
+	Invalid    int              `json:"invalid"`
+	Inserted   int              `json:"inserted"`
+	Duplicates int              `json:"duplicates"`
+	DryRun     bool             `json:"dry_run"`
+	Errors     []ImportRowError `json:"errors"`
+	Truncated  bool             `json:"errors_truncated"` // More errors than listed
+}
+
+func (r *ImportReport) addError(row int, format string, args ...interface{}) {
+	r.Invalid++
+	if len(r.Errors) >= maxImportReportErrors {
+		r.Truncated = true
+		return
+	}
+	r.Errors = append(r.Errors, ImportRowError{Row: row, Message: fmt.Sprintf(format, args...)})
+}
+
+// ParseImportFormat validates the format parameter, defaulting to CSV
+func ParseImportFormat(value string) (string, error) {
+	switch value {
+	case "":
+		return ImportFormatCSV, nil
+	case ImportFormatCSV, ImportFormatNDJSON:
+		return value, nil
+	}
+	return "", fmt.Errorf("unknown import format %q", value)
+}
+
+// ParseColumnMapping parses "timestamp=Time,temperature=Temp (C)" into a mapping.
+// Fields that are not mentioned map to a source column of the same name.
+func ParseColumnMapping(value string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, field := range importFields {
+		mapping[field] = field
+	}
+	if value == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid mapping %q, expected field=column", pair)
+		}
+		field := strings.TrimSpace(parts[0])
+		if _, ok := mapping[field]; !ok {
+			return nil, fmt.Errorf("unknown mapping field %q", field)
+		}
+		mapping[field] = strings.TrimSpace(parts[1])
+	}
+	return mapping, nil
+}
+
+type TempSensorDataImporter struct {
+	db *database.Database
+}
+
+func NewTempSensorDataImporter(db *database.Database) *TempSensorDataImporter {
+	return &TempSensorDataImporter{db: db}
+}
+
+// Import reads r row by row, validates every row and loads valid readings in
+// batches. Invalid rows are reported and skipped; they do not abort the import.
+func (i *TempSensorDataImporter) Import(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultImportBatchSize
+	}
+	if opts.ColumnMapping == nil {
+		opts.ColumnMapping, _ = ParseColumnMapping("")
+	}
+
+	report := &ImportReport{DryRun: opts.DryRun, Errors: []ImportRowError{}}
+
+	var calibrations []database.SensorCalibration
+	if !opts.DryRun {
+		var err error
+		calibrations, err = i.db.GetSensorCalibrations(ctx, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load calibrations: %w", err)
+		}
+	}
+
+	batch := make([]database.TempSensorData, 0, opts.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 || opts.DryRun {
+			batch = batch[:0]
+			return nil
+		}
+		inserted, duplicates, err := i.db.ImportTempSensorDataBatch(ctx, batch, calibrations)
+		if err != nil {
+			return fmt.Errorf("failed to load batch ending at row %d: %w", report.TotalRows, err)
+		}
+		report.Inserted += inserted
+		report.Duplicates += duplicates
+		batch = batch[:0]
+		return nil
+	}
+
+	handleRow := func(row int, get func(field string) (string, bool), rowErr error) error {
+		report.TotalRows++
+		if rowErr != nil {
+			report.addError(row, "%v", rowErr)
+			return nil
+		}
+		data, err := parseImportRow(get, opts)
+		if err != nil {
+			report.addError(row, "%v", err)
+			return nil
+		}
+		report.Valid++
+		batch = append(batch, *data)
+		if len(batch) >= opts.BatchSize {
+			return flush()
+		}
+		return nil
+	}
+
+	var err error
+	switch opts.Format {
+	case ImportFormatCSV:
+		err = readCSVRows(r, opts.ColumnMapping, handleRow)
+	case ImportFormatNDJSON:
+		err = readNDJSONRows(r, opts.ColumnMapping, handleRow)
+	default:
+		err = fmt.Errorf("unknown import format %q", opts.Format)
+	}
+	if err != nil {
+		return report, err
+	}
+
+	return report, flush()
+}
+
+// importRowHandler receives one source row; rowErr is set when the row could not be decoded
+type importRowHandler func(row int, get func(field string) (string, bool), rowErr error) error
+
+func readCSVRows(r io.Reader, mapping map[string]string, handle importRowHandler) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for idx, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = idx
+	}
+	for _, field := range []string{importFieldTimestamp, importFieldTemperature, importFieldHumidity} {
+		if _, ok := columns[mapping[field]]; !ok {
+			return fmt.Errorf("CSV header has no column %q for %s", mapping[field], field)
+		}
+	}
+
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read CSV row %d: %w", row, err)
+		}
+
+		get := func(field string) (string, bool) {
+			idx, ok := columns[mapping[field]]
+			if !ok || idx >= len(record) {
+				return "", false
+			}
+			value := strings.TrimSpace(record[idx])
+			return value, value != ""
+		}
+		if err := handle(row, get, nil); err != nil {
+			return err
+		}
+	}
+}
+
+func readNDJSONRows(r io.Reader, mapping map[string]string, handle importRowHandler) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	row := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		row++
+
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &object); err != nil {
+			if err := handle(row, nil, fmt.Errorf("invalid JSON: %v", err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		get := func(field string) (string, bool) {
+			value, ok := object[mapping[field]]
+			if !ok || value == nil {
+				return "", false
+			}
+			switch v := value.(type) {
+			case string:
+				return strings.TrimSpace(v), strings.TrimSpace(v) != ""
+			case float64:
+				return strconv.FormatFloat(v, 'f', -1, 64), true
+			}
+			return fmt.Sprint(value), true
+		}
+		if err := handle(row, get, nil); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// parseImportRow validates one source row and converts it to a reading
+func parseImportRow(get func(field string) (string, bool), opts ImportOptions) (*database.TempSensorData, error) {
+	rawTimestamp, ok := get(importFieldTimestamp)
+	if !ok {
+		return nil, fmt.Errorf("missing %s", importFieldTimestamp)
+	}
+	timestamp, err := parseImportTimestamp(rawTimestamp, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	parseValue := func(field string, required bool, min, max float64) (*float64, error) {
+		raw, ok := get(field)
+		if !ok {
+			if required {
+				return nil, fmt.Errorf("missing %s", field)
+			}
+			return nil, nil
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", field, raw)
+		}
+		if value < min || value > max {
+			return nil, fmt.Errorf("%s %v out of range [%v, %v]", field, value, min, max)
+		}
+		return &value, nil
+	}
+
+	// DHT22 operating range: -40..80°C, 0..100%
+	temperature, err := parseValue(importFieldTemperature, true, -40, 80)
+	if err != nil {
+		return nil, err
+	}
+	humidity, err := parseValue(importFieldHumidity, true, 0, 100)
+	if err != nil {
+		return nil, err
+	}
+	acOutletTemperature, err := parseValue(importFieldACOutletTemperature, false, -40, 80)
+	if err != nil {
+		return nil, err
+	}
+	acOutletHumidity, err := parseValue(importFieldACOutletHumidity, false, 0, 100)
+	if err != nil {
+		return nil, err
+	}
+	if (acOutletTemperature == nil) != (acOutletHumidity == nil) {
+		return nil, fmt.Errorf("%s and %s must be given together", importFieldACOutletTemperature, importFieldACOutletHumidity)
+	}
+
+	return &database.TempSensorData{
+		Temperature:         *temperature,
+		Humidity:            *humidity,
+		ACOutletTemperature: acOutletTemperature,
+		ACOutletHumidity:    acOutletHumidity,
+		// Stored like collector timestamps (local wall clock of the server)
+		Timestamp: timestamp.In(time.Local),
+	}, nil
+}
+
+func parseImportTimestamp(value string, opts ImportOptions) (time.Time, error) {
+	if opts.TimestampLayout == "unix" {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid unix timestamp %q", value)
+		}
+		return time.Unix(0, int64(seconds*float64(time.Second))), nil
+	}
+
+	layouts := importTimestampLayouts
+	if opts.TimestampLayout != "" {
+		layouts = []string{opts.TimestampLayout}
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, opts.Location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Minimal Apache Parquet writer: flat schema of required columns, PLAIN
+// encoding, uncompressed v1 data pages, one page per column per row group.
+// Rows are buffered per row group only, so memory stays bounded while streaming.
+
+// Parquet physical types
+const (
+	parquetInt64     int32 = 2
+	parquetDouble    int32 = 5
+	parquetByteArray int32 = 6
+)
+
+// Parquet converted (logical) types
+const (
+	parquetConvertedUTF8            int32 = 0
+	parquetConvertedTimestampMicros int32 = 10
+)
+
+const (
+	parquetMagic           = "PAR1"
+	parquetDefaultRowGroup = 10000
+)
+
+type parquetField struct {
+	Name          string
+	Type          int32
+	ConvertedType *int32
+}
+
+type parquetColumn struct {
+	field parquetField
+	buf   bytes.Buffer // PLAIN encoded values of the current row group
+}
+
+type parquetChunkMeta struct {
+	offset int64
+	size   int64
+}
+
+type parquetRowGroupMeta struct {
+	numRows   int64
+	totalSize int64
+	chunks    []parquetChunkMeta
+}
+
+type parquetWriter struct {
+	w            *countingWriter
+	columns      []*parquetColumn
+	rows         int64 // rows in the current row group
+	totalRows    int64
+	rowGroups    []parquetRowGroupMeta
+	rowGroupSize int64
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+func newParquetWriter(w io.Writer, fields []parquetField) (*parquetWriter, error) {
+	pw := &parquetWriter{
+		w:            &countingWriter{w: w},
+		rowGroupSize: parquetDefaultRowGroup,
+	}
+	for _, field := range fields {
+		pw.columns = append(pw.columns, &parquetColumn{field: field})
+	}
+
+	if _, err := io.WriteString(pw.w, parquetMagic); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// WriteRow appends one row; values must match the schema (int64, float64, string)
+func (pw *parquetWriter) WriteRow(values ...interface{}) error {
+	if len(values) != len(pw.columns) {
+		return fmt.Errorf("parquet: expected %d values, got %d", len(pw.columns), len(values))
+	}
+
+	for i, value := range values {
+		col := pw.columns[i]
+		switch v := value.(type) {
+		case int64:
+			binary.Write(&col.buf, binary.LittleEndian, v)
+		case float64:
+			binary.Write(&col.buf, binary.LittleEndian, math.Float64bits(v))
+		case string:
+			binary.Write(&col.buf, binary.LittleEndian, uint32(len(v)))
+			col.buf.WriteString(v)
+		default:
+			return fmt.Errorf("parquet: unsupported value type %T for column %s", value, col.field.Name)
+		}
+	}
+
+	pw.rows++
+	if pw.rows >= pw.rowGroupSize {
+		return pw.flushRowGroup()
+	}
+	return nil
+}
+
+func (pw *parquetWriter) flushRowGroup() error {
+	if pw.rows == 0 {
+		return nil
+	}
+
+	group := parquetRowGroupMeta{numRows: pw.rows}
+	for _, col := range pw.columns {
+		header := &thriftWriter{}
+		header.i32(1, 0) // type: DATA_PAGE
+		header.i32(2, int32(col.buf.Len()))
+		header.i32(3, int32(col.buf.Len()))
+		header.structBegin(5) // data_page_header
+		header.i32(1, int32(pw.rows))
+		header.i32(2, 0) // encoding: PLAIN
+		header.i32(3, 3) // definition_level_encoding: RLE
+		header.i32(4, 3) // repetition_level_encoding: RLE
+		header.structEnd()
+		header.stop()
+
+		offset := pw.w.n
+		if _, err := pw.w.Write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if _, err := pw.w.Write(col.buf.Bytes()); err != nil {
+			return err
+		}
+
+		size := pw.w.n - offset
+		group.chunks = append(group.chunks, parquetChunkMeta{offset: offset, size: size})
+		group.totalSize += size
+		col.buf.Reset()
+	}
+
+	pw.rowGroups = append(pw.rowGroups, group)
+	pw.totalRows += pw.rows
+	pw.rows = 0
+	return nil
+}
+
+// Close flushes the last row group and writes the footer
+func (pw *parquetWriter) Close() error {
+	if err := pw.flushRowGroup(); err != nil {
+		return err
+	}
+
+	meta := &thriftWriter{}
+	meta.i32(1, 1) // version
+
+	meta.listBegin(2, thriftStruct, len(pw.columns)+1) // schema
+	meta.elemStructBegin()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(pw.columns)))
+	meta.structEnd()
+	for _, col := range pw.columns {
+		meta.elemStructBegin()
+		meta.i32(1, col.field.Type)
+		meta.i32(3, 0) // repetition_type: REQUIRED
+		meta.binary(4, col.field.Name)
+		if col.field.ConvertedType != nil {
+			meta.i32(6, *col.field.ConvertedType)
+		}
+		meta.structEnd()
+	}
+
+	meta.i64(3, pw.totalRows)
+
+	meta.listBegin(4, thriftStruct, len(pw.rowGroups)) // row_groups
+	for _, group := range pw.rowGroups {
+		meta.elemStructBegin()
+		meta.listBegin(1, thriftStruct, len(group.chunks)) // columns
+		for i, chunk := range group.chunks {
+			col := pw.columns[i]
+			meta.elemStructBegin()
+			meta.i64(2, chunk.offset) // file_offset
+			meta.structBegin(3)       // meta_data
+			meta.i32(1, col.field.Type)
+			meta.listBegin(2, thriftI32, 1)
+			meta.elemI32(0) // PLAIN
+			meta.listBegin(3, thriftBinary, 1)
+			meta.elemBinary(col.field.Name)
+			meta.i32(4, 0) // codec: UNCOMPRESSED
+			meta.i64(5, group.numRows)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset) // data_page_offset
+			meta.structEnd()
+			meta.structEnd()
+		}
+		meta.i64(2, group.totalSize)
+		meta.i64(3, group.numRows)
+		meta.structEnd()
+	}
+
+	meta.binary(6, "knet_management")
+	meta.stop()
+
+	if _, err := pw.w.Write(meta.buf.Bytes()); err != nil {
+		return err
+	}
+	if err := binary.Write(pw.w, binary.LittleEndian, uint32(meta.buf.Len())); err != nil {
+		return err
+	}
+	_, err := io.WriteString(pw.w, parquetMagic)
+	return err
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    byte = 5
+	thriftI64    byte = 6
+	thriftBinary byte = 8
+	thriftList   byte = 9
+	thriftStruct byte = 12
+)
+
+// thriftWriter encodes the subset of the Thrift compact protocol used by Parquet metadata
+type thriftWriter struct {
+	buf       bytes.Buffer
+	lastField []int16
+	current   int16
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	t.buf.Write(tmp[:n])
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	delta := id - t.current
+	if delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.zigzag(int64(id))
+	}
+	t.current = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.elemBinary(s)
+}
+
+func (t *thriftWriter) listBegin(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xF0 | elemType)
+		t.varint(uint64(size))
+	}
+}
+
+func (t *thriftWriter) elemI32(v int32) {
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) elemBinary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+// structBegin starts a struct-typed field
+func (t *thriftWriter) structBegin(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.elemStructBegin()
+}
+
+// elemStructBegin starts a struct inside a list
+func (t *thriftWriter) elemStructBegin() {
+	t.lastField = append(t.lastField, t.current)
+	t.current = 0
+}
+
+func (t *thriftWriter) structEnd() {
+	t.stop()
+	t.current = t.lastField[len(t.lastField)-1]
+	t.lastField = t.lastField[:len(t.lastField)-1]
+}
+
+// stop terminates the top-level struct
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"knet_management/database"
+)
+
+// RetentionJob deletes readings older than the configured retention period
+// once an hour. A zero period keeps everything.
+type RetentionJob struct {
+	db *database.Database
+
+	mu     sync.Mutex
+	period time.Duration
+}
+
+func NewRetentionJob(db *database.Database, period time.Duration) *RetentionJob {
+	return &RetentionJob{db: db, period: period}
+}
+
+// SetPeriod changes the retention period; it applies from the next run
+func (j *RetentionJob) SetPeriod(period time.Duration) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.period = period
+}
+
+// RunOnce deletes the readings that are older than the period
+func (j *RetentionJob) RunOnce() {
+	j.mu.Lock()
+	period := j.period
+	j.mu.Unlock()
+
+	if period <= 0 {
+		return
+	}
+
+	cutoff := time.Now().Add(-period)
+	deleted, err := j.db.DeleteTempSensorDataBefore(context.Background(), cutoff)
+	if err != nil {
+		slog.Error("Retention: failed to delete old readings", "before", cutoff, "error", err)
+		return
+	}
+	if deleted > 0 {
+		slog.Info("Retention: deleted old readings", "count", deleted, "before", cutoff)
+	}
+}
+
+func (j *RetentionJob) Start() {
+	ticker := time.NewTicker(time.Hour)
+	go func() {
+		j.RunOnce()
+		for range ticker.C {
+			j.RunOnce()
+		}
+	}()
+}
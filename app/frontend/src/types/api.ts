@@ -5,11 +5,47 @@ export interface TempSensorData {
   ac_outlet_temperature?: number;
   ac_outlet_humidity?: number;
   timestamp: string;
+  raw_temperature?: number;
+  raw_humidity?: number;
+  raw_ac_outlet_temperature?: number;
+  raw_ac_outlet_humidity?: number;
   is_outlier: boolean;
+  gap?: boolean; // Sampled slot without a reading (id is 0 in v1 responses)
+  quality_flags?: QualityFlag[];
+  derived?: DerivedMetrics;
+  ac_outlet_derived?: DerivedMetrics;
   default_aggregated?: DefaultAggregatedValues;
   aggregated?: AggregatedValues;
 }
 
+export type QualityFlag = 'outlier' | 'interpolated' | 'late' | 'spooled' | 'manual_edit';
+
+// History point of version=2 responses: gaps are nulls instead of zeros
+export interface NullableTempSensorData {
+  id: number | null;
+  temperature: number | null;
+  humidity: number | null;
+  ac_outlet_temperature: number | null;
+  ac_outlet_humidity: number | null;
+  timestamp: string;
+  gap: boolean;
+  is_outlier: boolean;
+  quality_flags: QualityFlag[];
+  derived: DerivedMetrics | null;
+  ac_outlet_derived: DerivedMetrics | null;
+  default_aggregated?: DefaultAggregatedValues;
+  aggregated?: AggregatedValues;
+}
+
+export type FillMode = 'null' | 'previous' | 'linear';
+
+export interface DerivedMetrics {
+  dew_point: number;         // °C
+  absolute_humidity: number; // g/m³
+  heat_index: number;        // °C
+  humidex: number;           // °C
+}
+
 export interface DefaultAggregatedValues {
   temperature: number; // Average with ±3 window
   humidity: number;    // Average with ±3 window
@@ -18,6 +54,10 @@ export interface DefaultAggregatedValues {
 export interface AggregatedValues {
   temperature?: TemperatureAggregates;
   humidity?: HumidityAggregates;
+  dew_point?: MetricAggregates;
+  absolute_humidity?: MetricAggregates;
+  heat_index?: MetricAggregates;
+  humidex?: MetricAggregates;
 }
 
 export interface TemperatureAggregates {
@@ -34,6 +74,13 @@ export interface HumidityAggregates {
   count: number; // Number of data points used for calculation
 }
 
+export interface MetricAggregates {
+  average: number;
+  maximum: number;
+  minimum: number;
+  count: number;
+}
+
 export interface LatestDataResponse extends TempSensorData {}
 
 // Enhanced to support aggregation parameters
@@ -57,6 +104,10 @@ export interface HistoryDataResponse {
   end_time?: string;
   total_count?: number;
   returned_count?: number;
+  // Cursor pagination fields (pagination=cursor)
+  pagination?: 'cursor';
+  next_cursor?: string | null;
+  prev_cursor?: string | null;
   // New aggregation metadata
   aggregation?: AggregationMetadata;
 }